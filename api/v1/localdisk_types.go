@@ -0,0 +1,94 @@
+/*
+ Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LocalDisk phase
+const (
+	// 磁盘未加入任何设备组
+	LocalDiskUnclaimed = "Unclaimed"
+	// 磁盘已声明加入设备组，等待carina-node处理
+	LocalDiskClaimed = "Claimed"
+	// 磁盘已是设备组中的pv
+	LocalDiskInUse = "InUse"
+	// 磁盘无法加入设备组、磁盘故障或者已从节点上消失
+	LocalDiskFailed = "Failed"
+	// 磁盘正在移出设备组
+	LocalDiskRemoving = "Removing"
+)
+
+// LocalDiskSpec defines the desired state of LocalDisk
+type LocalDiskSpec struct {
+	NodeName string `json:"nodeName"`
+	// 磁盘所属设备组，例如carina-vg-hdd，为空表示不使用该磁盘
+	DeviceGroup string `json:"deviceGroup,omitempty"`
+}
+
+// LocalDiskStatus defines the observed state of LocalDisk
+type LocalDiskStatus struct {
+	Phase      string `json:"phase,omitempty"`
+	DevicePath string `json:"devicePath,omitempty"`
+	// 磁盘当前所在的vg卷组
+	DeviceGroup string `json:"deviceGroup,omitempty"`
+	Size        uint64 `json:"size,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Rotational  string `json:"rotational,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Serial      string `json:"serial,omitempty"`
+	Transport   string `json:"transport,omitempty"`
+	// multipath设备的WWID及可用路径数量
+	WWID      string `json:"wwid,omitempty"`
+	PathCount int    `json:"pathCount,omitempty"`
+	Health    string `json:"health,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=ld
+// +kubebuilder:printcolumn:name="NODE",type="string",JSONPath=".spec.nodeName"
+// +kubebuilder:printcolumn:name="DEVICE",type="string",JSONPath=".status.devicePath"
+// +kubebuilder:printcolumn:name="GROUP",type="string",JSONPath=".spec.deviceGroup"
+// +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="SIZE",type="integer",priority=1,JSONPath=".status.size"
+// +kubebuilder:printcolumn:name="HEALTH",type="string",priority=1,JSONPath=".status.health"
+// +kubebuilder:printcolumn:name="MESSAGE",type="string",priority=1,JSONPath=".status.message"
+
+// LocalDisk is the Schema for the localdisks API
+type LocalDisk struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LocalDiskSpec   `json:"spec,omitempty"`
+	Status LocalDiskStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// LocalDiskList contains a list of LocalDisk
+type LocalDiskList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LocalDisk `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LocalDisk{}, &LocalDiskList{})
+}
@@ -0,0 +1,125 @@
+/*
+ Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeStorageSpec defines the desired state of NodeStorage
+type NodeStorageSpec struct {
+	NodeName string `json:"nodeName"`
+}
+
+// ThinPoolStorage thin pool usage
+type ThinPoolStorage struct {
+	Name        string  `json:"name"`
+	Size        uint64  `json:"size"`
+	DataPercent float64 `json:"dataPercent"`
+	ThinCount   uint64  `json:"thinCount"`
+}
+
+// RaidArrayStorage md raid array under a device group
+type RaidArrayStorage struct {
+	Name          string `json:"name"`
+	Level         string `json:"level"`
+	Health        string `json:"health"`
+	State         string `json:"state,omitempty"`
+	RaidDevices   int    `json:"raidDevices"`
+	ActiveDevices int    `json:"activeDevices"`
+	// 重建进度百分比
+	Rebuild float64 `json:"rebuild,omitempty"`
+}
+
+// DeviceGroupIOStats io load of a device group sampled from /proc/diskstats
+type DeviceGroupIOStats struct {
+	// 设备忙碌时间占比 0-100，取设备组内最繁忙的磁盘
+	Utilization float64 `json:"utilization"`
+	QueueDepth  float64 `json:"queueDepth"`
+	// 平均每个IO的耗时(毫秒)
+	Latency float64 `json:"latency"`
+	IOPS    float64 `json:"iops"`
+}
+
+// DeviceGroupStorage capacity of a device group, all size in bytes
+type DeviceGroupStorage struct {
+	Name        string `json:"name"`
+	Total       uint64 `json:"total"`
+	Allocatable uint64 `json:"allocatable"`
+	Used        uint64 `json:"used"`
+	Reserved    uint64 `json:"reserved"`
+	PVCount     uint64 `json:"pvCount"`
+	VolumeCount uint64 `json:"volumeCount"`
+	SnapCount   uint64 `json:"snapCount"`
+	// 设备组降级原因，为空表示未降级
+	Degraded  string            `json:"degraded,omitempty"`
+	ThinPools []ThinPoolStorage `json:"thinPools,omitempty"`
+	// 设备组使用md raid时的阵列状态
+	RaidArrays []RaidArrayStorage `json:"raidArrays,omitempty"`
+	// 最近一个采样周期的IO负载，关闭IO采样时为空
+	IOStats *DeviceGroupIOStats `json:"ioStats,omitempty"`
+}
+
+// NodeStorageStatus defines the observed state of NodeStorage
+type NodeStorageStatus struct {
+	DeviceGroups []DeviceGroupStorage `json:"deviceGroups,omitempty"`
+	VolumeCount  uint64               `json:"volumeCount"`
+	Version      string               `json:"version,omitempty"`
+	// carina-node开启的功能
+	Features       []string    `json:"features,omitempty"`
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=nst
+// +kubebuilder:printcolumn:name="NODE",type="string",JSONPath=".spec.nodeName"
+// +kubebuilder:printcolumn:name="VOLUMES",type="integer",JSONPath=".status.volumeCount"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.version"
+// +kubebuilder:printcolumn:name="UPDATED",type="date",JSONPath=".status.lastUpdateTime"
+
+// NodeStorage is the Schema for the nodestorages API
+type NodeStorage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeStorageSpec   `json:"spec,omitempty"`
+	Status NodeStorageStatus `json:"status,omitempty"`
+}
+
+// DeviceGroup returns the storage of device group
+func (ns *NodeStorage) DeviceGroup(name string) (DeviceGroupStorage, bool) {
+	for _, g := range ns.Status.DeviceGroups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return DeviceGroupStorage{}, false
+}
+
+// +kubebuilder:object:root=true
+
+// NodeStorageList contains a list of NodeStorage
+type NodeStorageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeStorage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeStorage{}, &NodeStorageList{})
+}
@@ -24,6 +24,140 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceGroupStorage) DeepCopyInto(out *DeviceGroupStorage) {
+	*out = *in
+	if in.ThinPools != nil {
+		in, out := &in.ThinPools, &out.ThinPools
+		*out = make([]ThinPoolStorage, len(*in))
+		copy(*out, *in)
+	}
+	if in.RaidArrays != nil {
+		in, out := &in.RaidArrays, &out.RaidArrays
+		*out = make([]RaidArrayStorage, len(*in))
+		copy(*out, *in)
+	}
+	if in.IOStats != nil {
+		in, out := &in.IOStats, &out.IOStats
+		*out = new(DeviceGroupIOStats)
+		**out = **in
+	}
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceGroupIOStats) DeepCopyInto(out *DeviceGroupIOStats) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceGroupIOStats.
+func (in *DeviceGroupIOStats) DeepCopy() *DeviceGroupIOStats {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceGroupIOStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceGroupStorage.
+func (in *DeviceGroupStorage) DeepCopy() *DeviceGroupStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceGroupStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalDisk) DeepCopyInto(out *LocalDisk) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalDisk.
+func (in *LocalDisk) DeepCopy() *LocalDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LocalDisk) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalDiskList) DeepCopyInto(out *LocalDiskList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LocalDisk, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalDiskList.
+func (in *LocalDiskList) DeepCopy() *LocalDiskList {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalDiskList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LocalDiskList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalDiskSpec) DeepCopyInto(out *LocalDiskSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalDiskSpec.
+func (in *LocalDiskSpec) DeepCopy() *LocalDiskSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalDiskSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalDiskStatus) DeepCopyInto(out *LocalDiskStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalDiskStatus.
+func (in *LocalDiskStatus) DeepCopy() *LocalDiskStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalDiskStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LogicVolume) DeepCopyInto(out *LogicVolume) {
 	*out = *in
@@ -118,3 +252,135 @@ func (in *LogicVolumeStatus) DeepCopy() *LogicVolumeStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeStorage) DeepCopyInto(out *NodeStorage) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeStorage.
+func (in *NodeStorage) DeepCopy() *NodeStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeStorage) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeStorageList) DeepCopyInto(out *NodeStorageList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NodeStorage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeStorageList.
+func (in *NodeStorageList) DeepCopy() *NodeStorageList {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeStorageList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeStorageList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeStorageSpec) DeepCopyInto(out *NodeStorageSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeStorageSpec.
+func (in *NodeStorageSpec) DeepCopy() *NodeStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeStorageStatus) DeepCopyInto(out *NodeStorageStatus) {
+	*out = *in
+	if in.DeviceGroups != nil {
+		in, out := &in.DeviceGroups, &out.DeviceGroups
+		*out = make([]DeviceGroupStorage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Features != nil {
+		in, out := &in.Features, &out.Features
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeStorageStatus.
+func (in *NodeStorageStatus) DeepCopy() *NodeStorageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeStorageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RaidArrayStorage) DeepCopyInto(out *RaidArrayStorage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RaidArrayStorage.
+func (in *RaidArrayStorage) DeepCopy() *RaidArrayStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(RaidArrayStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolStorage) DeepCopyInto(out *ThinPoolStorage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolStorage.
+func (in *ThinPoolStorage) DeepCopy() *ThinPoolStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolStorage)
+	in.DeepCopyInto(out)
+	return out
+}
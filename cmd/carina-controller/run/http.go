@@ -50,6 +50,7 @@ func newHttpServer(c cache.Cache, stopChan <-chan struct{}) *eHttpServer {
 	e := echo.New()
 	e.GET("/devicegroup", vgList)
 	e.GET("/volume", volumeList)
+	e.GET("/disk", diskList)
 
 	return &eHttpServer{
 		e:        e,
@@ -131,6 +132,37 @@ func volumeList(c echo.Context) error {
 	return c.JSON(http.StatusOK, result)
 }
 
+func diskList(c echo.Context) error {
+	endpoints, err := getEndpoints()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	result := map[string][]types.DiskInventory{}
+	for _, ep := range endpoints {
+		resp, err := http.Get(fmt.Sprintf("http://%s:%d/disk", ep.Ip, ep.Port))
+		if err != nil {
+			log.Infof("error %s", err.Error())
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			continue
+		}
+		r := []types.DiskInventory{}
+		err = json.Unmarshal(body, &r)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		result[ep.NodeName] = r
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
 func getEndpoints() ([]carinaNode, error) {
 	result := []carinaNode{}
 	endpoints := corev1.Endpoints{}
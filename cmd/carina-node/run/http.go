@@ -16,6 +16,7 @@
 package run
 
 import (
+	deviceManager "github.com/carina-io/carina/pkg/devicemanager"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
 	"github.com/labstack/echo/v4"
 	"net/http"
@@ -23,6 +24,7 @@ import (
 
 var (
 	volumeManager volume.LocalVolume
+	dm            *deviceManager.DeviceManager
 )
 
 type eHttpServer struct {
@@ -30,11 +32,20 @@ type eHttpServer struct {
 	stopChan <-chan struct{}
 }
 
-func newHttpServer(v volume.LocalVolume, stopChan <-chan struct{}) *eHttpServer {
+func newHttpServer(v volume.LocalVolume, d *deviceManager.DeviceManager, stopChan <-chan struct{}) *eHttpServer {
 	volumeManager = v
+	dm = d
 	e := echo.New()
 	e.GET("/devicegroup", vgList)
 	e.GET("/volume", volumeList)
+	e.GET("/disk", diskList)
+	e.GET("/diskhealth", diskHealthList)
+	e.GET("/raid", raidList)
+	e.GET("/diskplan", diskPlan)
+	e.GET("/rejecteddisk", rejectedDisk)
+	e.GET("/decommission", decommissionList)
+	e.POST("/decommission", decommission)
+	e.DELETE("/decommission", forgetDecommission)
 
 	return &eHttpServer{
 		e:        e,
@@ -70,3 +81,56 @@ func volumeList(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, lvList)
 }
+
+func diskList(c echo.Context) error {
+	diskList, err := dm.DiskInventory()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, diskList)
+}
+
+func diskHealthList(c echo.Context) error {
+	return c.JSON(http.StatusOK, dm.DiskHealthList())
+}
+
+func raidList(c echo.Context) error {
+	return c.JSON(http.StatusOK, dm.RaidArrayList())
+}
+
+func diskPlan(c echo.Context) error {
+	plan := dm.DiskPlan()
+	if plan == nil {
+		return c.JSON(http.StatusNotFound, "disk plan not ready")
+	}
+	return c.JSON(http.StatusOK, plan)
+}
+
+func decommissionList(c echo.Context) error {
+	return c.JSON(http.StatusOK, dm.DecommissionList())
+}
+
+// POST /decommission?device=/dev/sdb
+func decommission(c echo.Context) error {
+	device := c.QueryParam("device")
+	if device == "" {
+		return c.JSON(http.StatusBadRequest, "device cannot be empty")
+	}
+	d, err := dm.Decommission(device)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, d)
+}
+
+func forgetDecommission(c echo.Context) error {
+	device := c.QueryParam("device")
+	if err := dm.ForgetDecommission(device); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, device)
+}
+
+func rejectedDisk(c echo.Context) error {
+	return c.JSON(http.StatusOK, dm.RejectedDisks())
+}
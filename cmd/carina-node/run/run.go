@@ -33,6 +33,7 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
 )
@@ -76,7 +77,12 @@ func subMain() error {
 	// 初始化磁盘管理服务
 	stopChan := make(chan struct{})
 	defer close(stopChan)
-	dm := deviceManager.NewDeviceManager(nodeName, mgr.GetCache(), stopChan)
+	// 磁盘扫描在manager启动前执行，LocalDisk对象不能通过缓存读取
+	directClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+	dm := deviceManager.NewDeviceManager(nodeName, mgr.GetCache(), directClient, mgr.GetEventRecorderFor("carina-node"), stopChan)
 
 	podController := controllers.PodReconciler{
 		Client:   mgr.GetClient(),
@@ -102,12 +108,22 @@ func subMain() error {
 		setupLog.Error(err, "unable to create controller", "controller", "LogicalVolume")
 		return err
 	}
+
+	ldController := controllers.LocalDiskReconciler{
+		Client:   mgr.GetClient(),
+		NodeName: nodeName,
+		DiskScan: dm.TriggerDiskScan,
+	}
+	if err := ldController.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LocalDisk")
+		return err
+	}
 	// +kubebuilder:scaffold:builder
 
 	// Add metrics exporter to manager.
 	// Note that grpc.ClientConn can be shared with multiple stubs/services.
 	// https://github.com/grpc/grpc-go/tree/master/examples/features/multiplex
-	if err := mgr.Add(runners.NewMetricsExporter(nodeName, dm.VolumeManager)); err != nil {
+	if err := mgr.Add(runners.NewMetricsExporter(nodeName, dm.VolumeManager, dm)); err != nil {
 		return err
 	}
 
@@ -131,10 +147,20 @@ func subMain() error {
 	dm.DeviceCheckTask()
 	// 启动volume一致性检查
 	dm.VolumeConsistencyCheck()
+	// 启动磁盘健康检查
+	dm.DiskHealthTask()
+	// 启动磁盘下线任务
+	dm.DecommissionTask()
+	// 启动raid阵列巡检
+	dm.RaidTask()
+	// 启动设备组IO负载采样
+	dm.IOStatsTask()
+	// 启动节点存储状态同步，需要在设备插件之前注册容量变更通知
+	dm.NodeStorageTask()
 	// 启动设备插件
 	go deviceplugin.Run(dm.VolumeManager, stopChan)
 	// http server
-	e := newHttpServer(dm.VolumeManager, stopChan)
+	e := newHttpServer(dm.VolumeManager, dm, stopChan)
 	go e.start()
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -0,0 +1,70 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+	carinav1 "github.com/carina-io/carina/api/v1"
+	"github.com/carina-io/carina/utils/log"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// LocalDiskReconciler reconciles a LocalDisk object
+type LocalDiskReconciler struct {
+	client.Client
+	NodeName string
+	// 触发磁盘扫描，由磁盘管理服务根据LocalDisk对象加入或移出磁盘
+	DiskScan func()
+}
+
+// +kubebuilder:rbac:groups=carina.storage.io,resources=localdisks,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=carina.storage.io,resources=localdisks/status,verbs=get;update;patch
+
+// Reconcile trigger disk scan
+func (r *LocalDiskReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log.Infof("localdisk %s spec changed, trigger disk scan", req.Name)
+	r.DiskScan()
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up Reconciler with Manager.
+func (r *LocalDiskReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// 只关心管理员对本节点磁盘spec的修改，carina-node自己创建对象和更新状态不触发扫描
+	pred := predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		DeleteFunc: func(event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldLd, ok := e.ObjectOld.(*carinav1.LocalDisk)
+			if !ok {
+				return false
+			}
+			newLd, ok := e.ObjectNew.(*carinav1.LocalDisk)
+			if !ok {
+				return false
+			}
+			return newLd.Spec.NodeName == r.NodeName && oldLd.Spec != newLd.Spec
+		},
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		WithEventFilter(pred).
+		For(&carinav1.LocalDisk{}).
+		Complete(r)
+}
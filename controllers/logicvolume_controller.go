@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"github.com/carina-io/carina/pkg/devicemanager/lvmd"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
 	"github.com/carina-io/carina/utils"
 	"github.com/carina-io/carina/utils/log"
@@ -31,6 +32,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sort"
 	"time"
 
 	carinav1 "github.com/carina-io/carina/api/v1"
@@ -146,6 +148,29 @@ func (r *LogicVolumeReconciler) removeLVIfExists(ctx context.Context, lv *carina
 	return nil
 }
 
+// 本节点同一设备组中与lv属于同一反亲和组的卷，创建时需要分配在不同的磁盘上
+func (r *LogicVolumeReconciler) antiAffinityVolumes(ctx context.Context, lv *carinav1.LogicVolume) ([]string, error) {
+	group := lv.Annotations[utils.AntiAffinityGroupKey]
+	if group == "" {
+		return nil, nil
+	}
+	lvList := new(carinav1.LogicVolumeList)
+	if err := r.List(ctx, lvList); err != nil {
+		return nil, err
+	}
+	apart := []string{}
+	for _, item := range lvList.Items {
+		if item.Name == lv.Name || item.Spec.NodeName != lv.Spec.NodeName || item.Spec.DeviceGroup != lv.Spec.DeviceGroup {
+			continue
+		}
+		if item.Annotations[utils.AntiAffinityGroupKey] == group && item.DeletionTimestamp == nil {
+			apart = append(apart, item.Name)
+		}
+	}
+	sort.Strings(apart)
+	return apart, nil
+}
+
 func (r *LogicVolumeReconciler) createLV(ctx context.Context, lv *carinav1.LogicVolume) error {
 	// When lv.Status.Code is not codes.OK (== 0), CreateLV has already failed.
 	// LogicalVolume CRD will be deleted soon by the controller.
@@ -155,12 +180,16 @@ func (r *LogicVolumeReconciler) createLV(ctx context.Context, lv *carinav1.Logic
 
 	reqBytes := lv.Spec.Size.Value()
 
-	err := utils.UntilMaxRetry(func() error {
-		return r.volume.CreateVolume(lv.Name, lv.Spec.DeviceGroup, uint64(reqBytes), 1)
+	apart, err := r.antiAffinityVolumes(ctx, lv)
+	if err != nil {
+		return err
+	}
+	err = utils.UntilMaxRetry(func() error {
+		return r.volume.CreateVolume(lv.Name, lv.Spec.DeviceGroup, uint64(reqBytes), 1, apart)
 	}, 5, 12*time.Second)
 
 	if err != nil {
-		lv.Status.Code = codes.Internal
+		lv.Status.Code = lvmd.GRPCCode(err)
 		lv.Status.Message = err.Error()
 		lv.Status.Status = "Failed"
 		r.Recorder.Event(lv, corev1.EventTypeWarning, "CreateVolumeFailed", fmt.Sprintf("create volume failed node: %s, time: %s, error: %s", r.nodeName, time.Now().Format("2006-01-02T15:04:05.000Z"), err.Error()))
@@ -211,11 +240,15 @@ func (r *LogicVolumeReconciler) expandLV(ctx context.Context, lv *carinav1.Logic
 	origBytes := (*lv.Status.CurrentSize).Value()
 	reqBytes := lv.Spec.Size.Value()
 
-	err := utils.UntilMaxRetry(func() error {
-		return r.volume.ResizeVolume(lv.Name, lv.Spec.DeviceGroup, uint64(reqBytes), 1)
+	apart, err := r.antiAffinityVolumes(ctx, lv)
+	if err != nil {
+		return err
+	}
+	err = utils.UntilMaxRetry(func() error {
+		return r.volume.ResizeVolume(lv.Name, lv.Spec.DeviceGroup, uint64(reqBytes), 1, apart)
 	}, 10, 12*time.Second)
 	if err != nil {
-		lv.Status.Code = codes.Internal
+		lv.Status.Code = lvmd.GRPCCode(err)
 		lv.Status.Message = err.Error()
 		lv.Status.Status = "Failed"
 		r.Recorder.Event(lv, corev1.EventTypeWarning, "ExpandVolumeFailed", fmt.Sprintf("expand volume failed node: %s, time: %s, error: %s", r.nodeName, time.Now().Format("2006-01-02T15:04:05.000Z"), err.Error()))
@@ -28,7 +28,7 @@ var stopChan chan struct{}
 func init() {
 	stopChan = make(chan struct{})
 
-	dm = deviceManager.NewDeviceManager("localhost", nil, stopChan)
+	dm = deviceManager.NewDeviceManager("localhost", nil, nil, nil, stopChan)
 }
 
 func Start(c echo.Context) error {
@@ -46,7 +46,7 @@ func CreateVolume(c echo.Context) error {
 	vgName := c.FormValue("vg_name")
 	size := c.FormValue("size")
 	req, _ := strconv.ParseUint(size, 10, 64)
-	err := dm.VolumeManager.CreateVolume(lvName, vgName, req, 1)
+	err := dm.VolumeManager.CreateVolume(lvName, vgName, req, 1, nil)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -58,7 +58,7 @@ func ResizeVolume(c echo.Context) error {
 	vgName := c.FormValue("vg_name")
 	size := c.FormValue("size")
 	req, _ := strconv.ParseUint(size, 10, 64)
-	err := dm.VolumeManager.ResizeVolume(lvName, vgName, req, 1)
+	err := dm.VolumeManager.ResizeVolume(lvName, vgName, req, 1, nil)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
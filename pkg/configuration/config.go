@@ -16,6 +16,7 @@
 package configuration
 
 import (
+	"github.com/carina-io/carina/pkg/devicemanager/types"
 	"github.com/carina-io/carina/utils"
 	"github.com/carina-io/carina/utils/log"
 	"github.com/fsnotify/fsnotify"
@@ -29,7 +30,9 @@ const (
 	configPath        = "/etc/carina/"
 	SchedulerBinpack  = "binpack"
 	SchedulerSpradout = "spradout"
-	diskGroupType     = "type"
+	// 磁盘分组策略
+	DiskGroupPolicyType   = "type"
+	DiskGroupPolicyCustom = "custom"
 )
 
 var TestAssistDiskSelector []string
@@ -107,12 +110,126 @@ func DiskScanInterval() int64 {
 	return diskScanInterval
 }
 
-// 磁盘分组策略，目前只支持根据磁盘类型分组
+// 磁盘扫描预演，只生成磁盘变更计划不做任何变更
+func DiskScanDryRun() bool {
+	return GlobalConfig.GetBool("diskScanDryRun")
+}
+
+// 自动审批移除磁盘，关闭时需要在Node上添加审批注解才会移除不再匹配的磁盘
+func AutoApproveDiskRemoval() bool {
+	return GlobalConfig.GetBool("autoApproveDiskRemoval")
+}
+
+// 磁盘SMART健康检查时间间隔(秒)，0表示关闭，最小600s
+func SmartCheckInterval() int64 {
+	smartCheckInterval := GlobalConfig.GetInt64("smartCheckInterval")
+	if smartCheckInterval <= 0 {
+		return 0
+	}
+	if smartCheckInterval < 600 {
+		smartCheckInterval = 600
+	}
+	return smartCheckInterval
+}
+
+// 设备组IO负载采样时间间隔(秒)，0表示关闭，最小10s
+func IOStatsInterval() int64 {
+	ioStatsInterval := GlobalConfig.GetInt64("ioStatsInterval")
+	if ioStatsInterval <= 0 {
+		return 0
+	}
+	if ioStatsInterval < 10 {
+		ioStatsInterval = 10
+	}
+	return ioStatsInterval
+}
+
+// 磁盘SMART检查失败时是否将其所在设备组标记为降级，降级后不再分配新卷
+func SmartDegradeGroup() bool {
+	return GlobalConfig.GetBool("smartDegradeGroup")
+}
+
+// 磁盘分组策略，type根据磁盘类型分组(nvme/ssd/hdd)，custom根据diskGroups配置分组，默认为type
 func DiskGroupPolicy() string {
-	diskGroupPolicy := GlobalConfig.GetString("diskGroupPolicy")
-	diskGroupPolicy = "type"
+	diskGroupPolicy := strings.ToLower(GlobalConfig.GetString("diskGroupPolicy"))
+	if diskGroupPolicy != DiskGroupPolicyCustom {
+		diskGroupPolicy = DiskGroupPolicyType
+	}
 	return diskGroupPolicy
+}
 
+// 磁盘分组，磁盘按照配置顺序匹配，加入第一个满足条件的分组
+func DiskGroups() []types.DiskGroup {
+	if DiskGroupPolicy() == DiskGroupPolicyType {
+		// nvme同样是ROTA=0，需要在ssd之前匹配
+		return []types.DiskGroup{
+			{Name: "nvme", Transport: []string{types.TransportNVMe}},
+			{Name: "ssd", Rotational: "0"},
+			{Name: "hdd", Rotational: "1"},
+		}
+	}
+
+	diskGroups := []types.DiskGroup{}
+	if err := GlobalConfig.UnmarshalKey("diskGroups", &diskGroups); err != nil {
+		log.Errorf("parse disk groups failed %s", err.Error())
+		return []types.DiskGroup{}
+	}
+	result := []types.DiskGroup{}
+	names := []string{}
+	for _, g := range diskGroups {
+		g.Name = strings.ToLower(g.Name)
+		if err := g.Validate(); err != nil {
+			log.Warnf("ignore disk group: %s", err.Error())
+			continue
+		}
+		if utils.ContainsString(names, g.Name) {
+			log.Warnf("ignore duplicate disk group %s", g.Name)
+			continue
+		}
+		names = append(names, g.Name)
+		result = append(result, g)
+	}
+	if len(result) == 0 {
+		log.Warn("No device group is configured because disk groups is empty")
+	}
+	return result
+}
+
+// 设备组预留容量，支持绝对值(10Gi)或者设备组总容量的百分比(5%)
+// 查找顺序为nodeReservedSpace中本节点的设备组配置、本节点的default配置，reservedSpace中的设备组配置、default配置
+// 设备组可以写作hdd或者carina-vg-hdd，均未配置时预留10Gi
+func ReservedSpace(vgName string, vgSize uint64) uint64 {
+	groups := []string{vgName, strings.TrimPrefix(vgName, types.DeviceGroupPrefix), "default"}
+	candidates := []string{}
+	if nodeName := strings.ToLower(os.Getenv("NODE_NAME")); nodeName != "" {
+		node := GlobalConfig.GetStringMapString("nodeReservedSpace." + nodeName)
+		for _, g := range groups {
+			if v, ok := node[g]; ok {
+				candidates = append(candidates, v)
+			}
+		}
+	}
+	// 兼容只配置一个值的写法
+	if v, ok := GlobalConfig.Get("reservedSpace").(string); ok {
+		candidates = append(candidates, v)
+	} else {
+		global := GlobalConfig.GetStringMapString("reservedSpace")
+		for _, g := range groups {
+			if v, ok := global[g]; ok {
+				candidates = append(candidates, v)
+			}
+		}
+	}
+
+	for _, c := range candidates {
+		reserved, err := types.ReservedSpace(c).Bytes(vgSize)
+		if err != nil {
+			log.Warnf("ignore reserved space of %s: %s", vgName, err.Error())
+			continue
+		}
+		return reserved
+	}
+	return utils.DefaultReservedSpace
 }
 
 // pv调度策略binpac/spradout，默认为binpac
@@ -137,11 +137,23 @@ func (s controllerService) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		return s.CreateBcacheVolume(ctx, req, node, requestGb)
 	}
 
+	policy, err := s.placementPolicy(ctx, req.GetParameters(), namespace, pvcName)
+	if err != nil {
+		return nil, err
+	}
+
 	// sc parameter未设置device group
+	// 优先使用调度器为pod的所有pvc整体计算的分配，避免逐个选择导致后创建的卷没有足够的空间
 	if node != "" && deviceGroup == "" {
-		group, err := s.nodeService.SelectDeviceGroup(ctx, requestGb, node)
+		group, err := s.nodeService.AssignedDeviceGroup(ctx, namespace, pvcName, node)
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to get device group %v", err)
+			return nil, status.Errorf(codes.Internal, "failed to get assigned device group %v", err)
+		}
+		if group == "" {
+			group, err = s.nodeService.SelectDeviceGroup(ctx, requestGb, node)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to get device group %v", err)
+			}
 		}
 		if group == "" {
 			return nil, status.Errorf(codes.Internal, "can not find any device group")
@@ -156,7 +168,7 @@ func (s controllerService) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		// - https://github.com/container-storage-interface/spec/blob/release-1.1/spec.md#createvolume
 		// - https://github.com/kubernetes-csi/csi-test/blob/6738ab2206eac88874f0a3ede59b40f680f59f43/pkg/sanity/controller.go#L404-L428
 		log.Info("decide node because accessibility_requirements not found")
-		nodeName, group, segmentsTmp, err := s.nodeService.SelectVolumeNode(ctx, requestGb, deviceGroup, requirements)
+		nodeName, group, segmentsTmp, err := s.nodeService.SelectVolumeNode(ctx, requestGb, deviceGroup, requirements, policy)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "failed to get max capacity node %v", err)
 		}
@@ -171,7 +183,7 @@ func (s controllerService) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		deviceGroup = group
 	}
 
-	volumeID, deviceMajor, deviceMinor, err := s.lvService.CreateVolume(ctx, namespace, pvcName, node, deviceGroup, name, requestGb, metav1.OwnerReference{}, map[string]string{})
+	volumeID, deviceMajor, deviceMinor, err := s.lvService.CreateVolume(ctx, namespace, pvcName, node, deviceGroup, name, requestGb, metav1.OwnerReference{}, policy.AntiAffinityAnnotations())
 	if err != nil {
 		_, ok := status.FromError(err)
 		if !ok {
@@ -187,6 +199,9 @@ func (s controllerService) CreateVolume(ctx context.Context, req *csi.CreateVolu
 	volumeContext[utils.VolumeDeviceNode] = node
 	volumeContext[utils.VolumeDeviceMajor] = fmt.Sprintf("%d", deviceMajor)
 	volumeContext[utils.VolumeDeviceMinor] = fmt.Sprintf("%d", deviceMinor)
+	if policy.AntiAffinityGroup != "" {
+		volumeContext[utils.AntiAffinityGroupKey] = policy.AntiAffinityGroup
+	}
 
 	// pv nodeAffinity
 	segments[utils.TopologyNodeKey] = node
@@ -364,7 +379,7 @@ func (s controllerService) ControllerExpandVolume(ctx context.Context, req *csi.
 	}
 
 	if capacity < (requestGb - currentGb) {
-		return nil, status.Error(codes.Internal, "not enough space")
+		return nil, status.Error(codes.ResourceExhausted, "not enough space")
 	}
 
 	err = s.lvService.ExpandVolume(ctx, volumeID, requestGb)
@@ -429,6 +444,25 @@ func convertRequestCapacity(requestBytes, limitBytes int64) (int64, error) {
 	return (requestBytes-1)>>30 + 1, nil
 }
 
+// 解析storageclass中的放置策略，设置了卷反亲和时根据pvc确定所属的反亲和组
+func (s controllerService) placementPolicy(ctx context.Context, parameters map[string]string, namespace, pvcName string) (k8s.PlacementPolicy, error) {
+	policy, err := k8s.ParsePlacementPolicy(parameters)
+	if err != nil {
+		return policy, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if policy.AntiAffinity == "" {
+		return policy, nil
+	}
+	policy.AntiAffinityGroup, err = s.nodeService.AntiAffinityGroup(ctx, namespace, pvcName)
+	if err != nil {
+		return policy, status.Errorf(codes.Internal, "can not find pvc %s %s", namespace, pvcName)
+	}
+	if policy.AntiAffinityGroup == "" {
+		log.Warnf("pvc %s/%s has no anti-affinity group, %s ignored", namespace, pvcName, utils.VolumeAntiAffinityKey)
+	}
+	return policy, nil
+}
+
 func (s controllerService) CreateBcacheVolume(ctx context.Context, req *csi.CreateVolumeRequest, node string, requestGb int64) (*csi.CreateVolumeResponse, error) {
 	source := req.GetVolumeContentSource()
 	name := req.GetName()
@@ -490,10 +524,15 @@ func (s controllerService) CreateBcacheVolume(ctx context.Context, req *csi.Crea
 	namespace := req.Parameters["csi.storage.k8s.io/pvc/namespace"]
 	segments := map[string]string{}
 
+	policy, err := s.placementPolicy(ctx, req.GetParameters(), namespace, pvcName)
+	if err != nil {
+		return nil, err
+	}
+
 	if node == "" {
 		// xxxx
 		log.Info("decide node because accessibility_requirements not found")
-		nodeName, segmentsTmp, err := s.nodeService.SelectMultiVolumeNode(ctx, backendDiskType, cacheDiskType, backendRequestGb, cacheRequestGb, requirements)
+		nodeName, segmentsTmp, err := s.nodeService.SelectMultiVolumeNode(ctx, backendDiskType, cacheDiskType, backendRequestGb, cacheRequestGb, requirements, policy)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "failed to get max capacity node %v", err)
 		}
@@ -504,9 +543,8 @@ func (s controllerService) CreateBcacheVolume(ctx context.Context, req *csi.Crea
 		segments = segmentsTmp
 	}
 
-	annotation := map[string]string{
-		utils.VolumeCacheDiskRatio: cacheDiskRatio,
-	}
+	annotation := policy.AntiAffinityAnnotations()
+	annotation[utils.VolumeCacheDiskRatio] = cacheDiskRatio
 
 	backendDiskVolumeID, backendDiskDeviceMajor, backendDiskDeviceMinor, err := s.lvService.CreateVolume(ctx, namespace, pvcName, node, backendDiskType, backendVolumeName, backendRequestGb, metav1.OwnerReference{}, annotation)
 	if err != nil {
@@ -561,6 +599,9 @@ func (s controllerService) CreateBcacheVolume(ctx context.Context, req *csi.Crea
 	volumeContext[utils.VolumeCachePolicy] = cachepolicy
 	volumeContext[utils.VolumeCacheDiskRatio] = cacheDiskRatio
 	volumeContext[utils.VolumeCacheId] = cacheDiskVolumeID
+	if policy.AntiAffinityGroup != "" {
+		volumeContext[utils.AntiAffinityGroupKey] = policy.AntiAffinityGroup
+	}
 
 	// pv nodeAffinity
 	segments[utils.TopologyNodeKey] = node
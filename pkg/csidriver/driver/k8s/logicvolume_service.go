@@ -225,6 +225,12 @@ func (s *LogicVolumeService) ExpandVolume(ctx context.Context, volumeID string,
 			log.Error(err, " failed to get LogicVolume name ", lv.Name)
 			return err
 		}
+		// 扩容失败时容量不会变化，需先检查失败状态
+		if changedLV.Status.Code != codes.OK {
+			log.Infof("volume expand failed %s", volumeID)
+			return status.Error(changedLV.Status.Code, changedLV.Status.Message)
+		}
+
 		if changedLV.Status.CurrentSize == nil {
 			return errors.New("status.currentSize should not be nil")
 		}
@@ -233,11 +239,6 @@ func (s *LogicVolumeService) ExpandVolume(ctx context.Context, volumeID string,
 			continue
 		}
 
-		if changedLV.Status.Code != codes.OK {
-			log.Infof("volume expand success %s", volumeID)
-			return status.Error(changedLV.Status.Code, changedLV.Status.Message)
-		}
-
 		return nil
 	}
 }
@@ -19,11 +19,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	carinav1 "github.com/carina-io/carina/api/v1"
 	"github.com/carina-io/carina/pkg/configuration"
 	"github.com/carina-io/carina/pkg/csidriver/csi"
 	"github.com/carina-io/carina/utils"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -39,15 +41,20 @@ const annAlphaSelectedNode = "volume.alpha.kubernetes.io/selected-node"
 type nodeService interface {
 	getNodes(ctx context.Context) (*corev1.NodeList, error)
 	// 支持 volume size 及 topology match
-	SelectVolumeNode(ctx context.Context, request int64, deviceGroup string, requirement *csi.TopologyRequirement) (string, string, map[string]string, error)
+	SelectVolumeNode(ctx context.Context, request int64, deviceGroup string, requirement *csi.TopologyRequirement, policy PlacementPolicy) (string, string, map[string]string, error)
 	GetCapacityByNodeName(ctx context.Context, nodeName, deviceGroup string) (int64, error)
 	GetTotalCapacity(ctx context.Context, deviceGroup string, topology *csi.Topology) (int64, error)
 	SelectDeviceGroup(ctx context.Context, request int64, nodeName string) (string, error)
 	// sc WaitForConsumer
 	HaveSelectedNode(ctx context.Context, namespace, name string) (string, error)
+	// 调度器为pvc分配的设备组
+	AssignedDeviceGroup(ctx context.Context, namespace, name, nodeName string) (string, error)
+
+	// pvc所属的卷反亲和组
+	AntiAffinityGroup(ctx context.Context, namespace, name string) (string, error)
 
 	// multi volume node select
-	SelectMultiVolumeNode(ctx context.Context, backendDeviceGroup, cacheDeviceGroup string, backendRequestGb, cacheRequestGb int64, requirement *csi.TopologyRequirement) (string, map[string]string, error)
+	SelectMultiVolumeNode(ctx context.Context, backendDeviceGroup, cacheDeviceGroup string, backendRequestGb, cacheRequestGb int64, requirement *csi.TopologyRequirement, policy PlacementPolicy) (string, map[string]string, error)
 }
 
 // ErrNodeNotFound represents the error that node is not found.
@@ -72,23 +79,17 @@ func (s NodeService) getNodes(ctx context.Context) (*corev1.NodeList, error) {
 	return nl, nil
 }
 
-func (s NodeService) SelectVolumeNode(ctx context.Context, requestGb int64, deviceGroup string, requirement *csi.TopologyRequirement) (string, string, map[string]string, error) {
+func (s NodeService) SelectVolumeNode(ctx context.Context, requestGb int64, deviceGroup string, requirement *csi.TopologyRequirement, policy PlacementPolicy) (string, string, map[string]string, error) {
 	// 在并发场景下，兼顾调度效率与调度公平，将pv分配到不同时间段
 	time.Sleep(time.Duration(rand.Int63nRange(1, 30)) * time.Second)
 
-	var nodeName, selectDeviceGroup string
 	segments := map[string]string{}
 	nl, err := s.getNodes(ctx)
 	if err != nil {
 		return "", "", segments, err
 	}
 
-	type paris struct {
-		Key   string
-		Value int64
-	}
-
-	preselectNode := []paris{}
+	preselectNode := []candidate{}
 
 	for _, node := range nl.Items {
 
@@ -120,9 +121,10 @@ func (s NodeService) SelectVolumeNode(ctx context.Context, requestGb int64, devi
 				if value.Value() < requestGb {
 					continue
 				}
-				preselectNode = append(preselectNode, paris{
-					Key:   node.Name + "-*-" + string(key),
-					Value: value.Value(),
+				preselectNode = append(preselectNode, candidate{
+					node:  node.Name,
+					group: strings.TrimPrefix(string(key), utils.DeviceCapacityKeyPrefix),
+					free:  value.Value(),
 				})
 			}
 		}
@@ -131,20 +133,12 @@ func (s NodeService) SelectVolumeNode(ctx context.Context, requestGb int64, devi
 		return "", "", segments, ErrNodeNotFound
 	}
 
-	sort.Slice(preselectNode, func(i, j int) bool {
-		return preselectNode[i].Value < preselectNode[j].Value
-	})
-
-	// 根据配置文件中设置算法进行节点选择
-	if configuration.SchedulerStrategy() == configuration.SchedulerBinpack {
-		nodeName = strings.Split(preselectNode[0].Key, "-*-")[0]
-		selectDeviceGroup = strings.Split(preselectNode[0].Key, "/")[1]
-	} else if configuration.SchedulerStrategy() == configuration.SchedulerSpradout {
-		nodeName = strings.Split(preselectNode[len(preselectNode)-1].Key, "-*-")[0]
-		selectDeviceGroup = strings.Split(preselectNode[len(preselectNode)-1].Key, "/")[1]
-	} else {
-		return "", "", segments, errors.New(fmt.Sprintf("no support scheduler strategy %s", configuration.SchedulerStrategy()))
+	// 根据storageclass中设置的放置策略进行节点选择
+	selected, err := s.selectCandidate(ctx, preselectNode, requestGb, policy)
+	if err != nil {
+		return "", "", segments, err
 	}
+	nodeName, selectDeviceGroup := selected.node, selected.group
 
 	// 获取选择节点的label
 	for _, node := range nl.Items {
@@ -251,6 +245,14 @@ func (s NodeService) SelectDeviceGroup(ctx context.Context, request int64, nodeN
 	return selectDeviceGroup, nil
 }
 
+func (s NodeService) AntiAffinityGroup(ctx context.Context, namespace, name string) (string, error) {
+	pvc := new(corev1.PersistentVolumeClaim)
+	if err := s.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, pvc); err != nil {
+		return "", err
+	}
+	return VolumeAntiAffinityGroup(pvc), nil
+}
+
 func (s NodeService) HaveSelectedNode(ctx context.Context, namespace, name string) (string, error) {
 	node := ""
 	pvc := new(corev1.PersistentVolumeClaim)
@@ -266,23 +268,29 @@ func (s NodeService) HaveSelectedNode(ctx context.Context, namespace, name strin
 	return node, nil
 }
 
-func (s NodeService) SelectMultiVolumeNode(ctx context.Context, backendDeviceGroup, cacheDeviceGroup string, backendRequestGb, cacheRequestGb int64, requirement *csi.TopologyRequirement) (string, map[string]string, error) {
+// 调度器在pod选定节点时为sc中未设置设备组的pvc整体计算了设备组分配，分配的节点与选定节点不一致时忽略
+func (s NodeService) AssignedDeviceGroup(ctx context.Context, namespace, name, nodeName string) (string, error) {
+	pvc := new(corev1.PersistentVolumeClaim)
+	if err := s.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, pvc); err != nil {
+		return "", err
+	}
+	if pvc.Annotations[utils.AnnAssignedNode] != nodeName {
+		return "", nil
+	}
+	return pvc.Annotations[utils.AnnAssignedDeviceGroup], nil
+}
+
+func (s NodeService) SelectMultiVolumeNode(ctx context.Context, backendDeviceGroup, cacheDeviceGroup string, backendRequestGb, cacheRequestGb int64, requirement *csi.TopologyRequirement, policy PlacementPolicy) (string, map[string]string, error) {
 	// 在并发场景下，兼顾调度效率与调度公平，将pv分配到不同时间段
 	time.Sleep(time.Duration(rand.Int63nRange(1, 30)) * time.Second)
 
-	var nodeName string
 	segments := map[string]string{}
 	nl, err := s.getNodes(ctx)
 	if err != nil {
 		return "", segments, err
 	}
 
-	type paris struct {
-		Key   string
-		Value int64
-	}
-
-	preselectNode := []paris{}
+	preselectNode := []candidate{}
 
 	for _, node := range nl.Items {
 
@@ -324,9 +332,10 @@ func (s NodeService) SelectMultiVolumeNode(ctx context.Context, backendDeviceGro
 		}
 
 		if backendFilter > 0 && cacheFileter >= 0 {
-			preselectNode = append(preselectNode, paris{
-				Key:   node.Name,
-				Value: backendFilter,
+			preselectNode = append(preselectNode, candidate{
+				node:  node.Name,
+				group: backendDeviceGroup,
+				free:  backendFilter,
 			})
 		}
 	}
@@ -334,18 +343,12 @@ func (s NodeService) SelectMultiVolumeNode(ctx context.Context, backendDeviceGro
 		return "", segments, ErrNodeNotFound
 	}
 
-	sort.Slice(preselectNode, func(i, j int) bool {
-		return preselectNode[i].Value < preselectNode[j].Value
-	})
-
-	// 根据配置文件中设置算法进行节点选择
-	if configuration.SchedulerStrategy() == configuration.SchedulerBinpack {
-		nodeName = preselectNode[0].Key
-	} else if configuration.SchedulerStrategy() == configuration.SchedulerSpradout {
-		nodeName = preselectNode[len(preselectNode)-1].Key
-	} else {
-		return "", segments, errors.New(fmt.Sprintf("no support scheduler strategy %s", configuration.SchedulerStrategy()))
+	// 根据storageclass中设置的放置策略进行节点选择
+	selected, err := s.selectCandidate(ctx, preselectNode, backendRequestGb, policy)
+	if err != nil {
+		return "", segments, err
 	}
+	nodeName := selected.node
 
 	// 获取选择节点的label
 	for _, node := range nl.Items {
@@ -360,3 +363,131 @@ func (s NodeService) SelectMultiVolumeNode(ctx context.Context, backendDeviceGro
 
 	return nodeName, segments, nil
 }
+
+// 候选的节点及设备组
+type candidate struct {
+	node  string
+	group string
+	free  int64
+}
+
+// 按放置策略选出分数最高的候选，分数相同时binpack选剩余容量最小的，spradout选剩余容量最大的
+func (s NodeService) selectCandidate(ctx context.Context, candidates []candidate, requestGb int64, policy PlacementPolicy) (candidate, error) {
+	if policy.Strategy != configuration.SchedulerBinpack && policy.Strategy != configuration.SchedulerSpradout {
+		return candidate{}, errors.New(fmt.Sprintf("no support scheduler strategy %s", policy.Strategy))
+	}
+	if policy.AntiAffinity == utils.AntiAffinityNode && policy.AntiAffinityGroup != "" {
+		nodes, err := s.antiAffinityNodes(ctx, policy.AntiAffinityGroup)
+		if err != nil {
+			return candidate{}, err
+		}
+		candidates = excludeNodes(candidates, nodes)
+		if len(candidates) == 0 {
+			return candidate{}, fmt.Errorf("all nodes have volumes of anti-affinity group %s", policy.AntiAffinityGroup)
+		}
+	}
+	volumes := map[string]int64{}
+	if policy.VolumeWeight > 0 {
+		var err error
+		volumes, err = s.nodeVolumes(ctx)
+		if err != nil {
+			return candidate{}, err
+		}
+	}
+	ioLoads := map[string]map[string]int64{}
+	if policy.IOLoadWeight > 0 {
+		var err error
+		ioLoads, err = s.nodeIOLoads(ctx)
+		if err != nil {
+			return candidate{}, err
+		}
+	}
+	return bestCandidate(candidates, requestGb, policy, volumes, ioLoads), nil
+}
+
+func bestCandidate(candidates []candidate, requestGb int64, policy PlacementPolicy, volumes map[string]int64, ioLoads map[string]map[string]int64) candidate {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].free < candidates[j].free
+	})
+	if policy.Strategy == configuration.SchedulerSpradout {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+	best := candidates[0]
+	bestScore := int64(-1)
+	for _, c := range candidates {
+		nodeVolumes := int64(unknownMetric)
+		if policy.VolumeWeight > 0 {
+			nodeVolumes = volumes[c.node]
+		}
+		ioLoad := int64(unknownMetric)
+		if load, ok := ioLoads[c.node][c.group]; ok && policy.IOLoadWeight > 0 {
+			ioLoad = load
+		}
+		score := policy.Score(capacityScore(c.free, requestGb, policy.Strategy), nodeVolumes, ioLoad)
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+// 已有同一反亲和组卷的节点
+func (s NodeService) antiAffinityNodes(ctx context.Context, group string) (map[string]bool, error) {
+	lvList := new(carinav1.LogicVolumeList)
+	if err := s.List(ctx, lvList); err != nil {
+		return nil, err
+	}
+	nodes := map[string]bool{}
+	for _, lv := range lvList.Items {
+		if lv.Annotations[utils.AntiAffinityGroupKey] == group {
+			nodes[lv.Spec.NodeName] = true
+		}
+	}
+	return nodes, nil
+}
+
+func excludeNodes(candidates []candidate, nodes map[string]bool) []candidate {
+	result := []candidate{}
+	for _, c := range candidates {
+		if !nodes[c.node] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// 各节点上的卷数量
+func (s NodeService) nodeVolumes(ctx context.Context) (map[string]int64, error) {
+	lvList := new(carinav1.LogicVolumeList)
+	if err := s.List(ctx, lvList); err != nil {
+		return nil, err
+	}
+	volumes := map[string]int64{}
+	for _, lv := range lvList.Items {
+		volumes[lv.Spec.NodeName]++
+	}
+	return volumes, nil
+}
+
+// 各节点设备组的IO利用率(0-100)，来自carina-node上报的NodeStorage，未开启IO采样的设备组不存在
+func (s NodeService) nodeIOLoads(ctx context.Context) (map[string]map[string]int64, error) {
+	nsList := new(carinav1.NodeStorageList)
+	if err := s.List(ctx, nsList); err != nil {
+		return nil, err
+	}
+	loads := map[string]map[string]int64{}
+	for _, ns := range nsList.Items {
+		for _, g := range ns.Status.DeviceGroups {
+			if g.IOStats == nil {
+				continue
+			}
+			if _, ok := loads[ns.Name]; !ok {
+				loads[ns.Name] = map[string]int64{}
+			}
+			loads[ns.Name][g.Name] = int64(math.Round(g.IOStats.Utilization))
+		}
+	}
+	return loads, nil
+}
@@ -0,0 +1,197 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package k8s
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/utils"
+	corev1 "k8s.io/api/core/v1"
+	"strconv"
+	"strings"
+)
+
+// 节点指标未知时的取值，该项不参与评分
+const unknownMetric = -1
+
+// PlacementPolicy 卷放置策略，由storageclass参数指定，与carina-scheduler的评分模型保持一致
+type PlacementPolicy struct {
+	// binpack|spradout
+	Strategy string
+	// 剩余容量、节点卷数量、磁盘IO负载的评分权重
+	CapacityWeight int64
+	VolumeWeight   int64
+	IOLoadWeight   int64
+	// 卷反亲和级别node|disk，为空表示不限制
+	AntiAffinity string
+	// pvc所属的反亲和组，namespace/group，由创建卷时根据pvc设置
+	AntiAffinityGroup string
+}
+
+// DefaultPlacementPolicy 未设置storageclass参数时只按剩余容量评分
+func DefaultPlacementPolicy() PlacementPolicy {
+	return PlacementPolicy{
+		Strategy:       configuration.SchedulerStrategy(),
+		CapacityWeight: 1,
+	}
+}
+
+// ParsePlacementPolicy 解析storageclass中的放置策略参数
+func ParsePlacementPolicy(parameters map[string]string) (PlacementPolicy, error) {
+	policy := DefaultPlacementPolicy()
+	if strategy, ok := parameters[utils.PlacementStrategyKey]; ok && strategy != "" {
+		switch strings.ToLower(strategy) {
+		case configuration.SchedulerBinpack:
+			policy.Strategy = configuration.SchedulerBinpack
+		case configuration.SchedulerSpradout, "spreadout":
+			policy.Strategy = configuration.SchedulerSpradout
+		default:
+			return policy, fmt.Errorf("%s %s, should be binpack or spradout", utils.PlacementStrategyKey, strategy)
+		}
+	}
+
+	if level, ok := parameters[utils.VolumeAntiAffinityKey]; ok && level != "" {
+		switch strings.ToLower(level) {
+		case utils.AntiAffinityNode, utils.AntiAffinityDisk:
+			policy.AntiAffinity = strings.ToLower(level)
+		default:
+			return policy, fmt.Errorf("%s %s, should be node or disk", utils.VolumeAntiAffinityKey, level)
+		}
+	}
+
+	weights, ok := parameters[utils.PlacementWeightsKey]
+	if !ok || weights == "" {
+		return policy, nil
+	}
+	policy.CapacityWeight = 0
+	for _, item := range strings.Split(weights, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return policy, fmt.Errorf("%s %s, should be like capacity=1,volume=1,ioload=1", utils.PlacementWeightsKey, weights)
+		}
+		weight, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil || weight < 0 {
+			return policy, fmt.Errorf("%s %s, weight should be a non-negative integer", utils.PlacementWeightsKey, weights)
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "capacity":
+			policy.CapacityWeight = weight
+		case "volume":
+			policy.VolumeWeight = weight
+		case "ioload":
+			policy.IOLoadWeight = weight
+		default:
+			return policy, fmt.Errorf("%s %s, unknown factor %s", utils.PlacementWeightsKey, weights, kv[0])
+		}
+	}
+	if policy.CapacityWeight+policy.VolumeWeight+policy.IOLoadWeight == 0 {
+		return policy, fmt.Errorf("%s %s, at least one weight should be greater than 0", utils.PlacementWeightsKey, weights)
+	}
+	return policy, nil
+}
+
+// VolumeAntiAffinityGroup pvc所属的反亲和组，无法确定时为空
+// 优先使用pvc标签，否则StatefulSet的pvc(<模板名>-<StatefulSet名>-<序号>)以去掉序号后的名称为组
+func VolumeAntiAffinityGroup(pvc *corev1.PersistentVolumeClaim) string {
+	if group := pvc.Labels[utils.AntiAffinityGroupKey]; group != "" {
+		return pvc.Namespace + "/" + group
+	}
+	index := strings.LastIndex(pvc.Name, "-")
+	if index <= 0 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(pvc.Name[index+1:], 10, 64); err != nil {
+		return ""
+	}
+	return pvc.Namespace + "/" + pvc.Name[:index]
+}
+
+// AntiAffinityAnnotations 记录在LogicVolume上的反亲和组，节点据此将同组的卷分配到不同的磁盘
+func (p PlacementPolicy) AntiAffinityAnnotations() map[string]string {
+	if p.AntiAffinity == "" || p.AntiAffinityGroup == "" {
+		return map[string]string{}
+	}
+	return map[string]string{
+		utils.VolumeAntiAffinityKey: p.AntiAffinity,
+		utils.AntiAffinityGroupKey:  p.AntiAffinityGroup,
+	}
+}
+
+// Score 按权重合并各项分数，结果范围0-100
+// volumes为节点上的卷数量，ioLoad为磁盘IO使用率(0-100)，未知时为-1不参与计算
+func (p PlacementPolicy) Score(capacityScore, volumes, ioLoad int64) int64 {
+	weighted := p.CapacityWeight * capacityScore
+	total := p.CapacityWeight
+	if volumes != unknownMetric {
+		weighted += p.VolumeWeight * volumeScore(volumes, p.Strategy)
+		total += p.VolumeWeight
+	}
+	if ioLoad != unknownMetric {
+		weighted += p.IOLoadWeight * ioLoadScore(ioLoad)
+		total += p.IOLoadWeight
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted / total
+}
+
+// 剩余容量与请求容量比值的分数，spradout倾向剩余容量多的节点，binpack相反
+func capacityScore(free, request int64, strategy string) int64 {
+	if request <= 0 {
+		request = 1
+	}
+	score := reasonableScore(free / request)
+	if strategy == configuration.SchedulerBinpack {
+		score = 6 - score
+	}
+	return score * 20
+}
+
+// 节点卷数量的分数，spradout倾向卷少的节点，binpack相反
+func volumeScore(volumes int64, strategy string) int64 {
+	if volumes > 8 {
+		volumes = 8
+	}
+	score := 5 - volumes/2
+	if strategy == configuration.SchedulerBinpack {
+		score = 6 - score
+	}
+	return score * 20
+}
+
+// IO负载越低分数越高，与策略无关
+func ioLoadScore(ioLoad int64) int64 {
+	if ioLoad > 100 {
+		ioLoad = 100
+	}
+	if ioLoad < 0 {
+		ioLoad = 0
+	}
+	return 100 - ioLoad
+}
+
+// 分值范围为1-5，与carina-scheduler保持一致
+// 对于申请用量与现存容量差距巨大，则配置文件中选节点策略可以忽略
+func reasonableScore(ratio int64) int64 {
+	if ratio > 10 {
+		return 5
+	}
+	if ratio < 2 {
+		return 1
+	}
+	return ratio / 2
+}
@@ -0,0 +1,103 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package k8s
+
+import (
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestParsePlacementPolicy(t *testing.T) {
+	table := []struct {
+		parameters map[string]string
+		policy     PlacementPolicy
+		err        bool
+	}{
+		{parameters: map[string]string{utils.PlacementStrategyKey: "binpack"}, policy: PlacementPolicy{Strategy: configuration.SchedulerBinpack, CapacityWeight: 1}},
+		{parameters: map[string]string{utils.PlacementStrategyKey: "Spreadout", utils.PlacementWeightsKey: "capacity=2, volume=1"}, policy: PlacementPolicy{Strategy: configuration.SchedulerSpradout, CapacityWeight: 2, VolumeWeight: 1}},
+		{parameters: map[string]string{utils.PlacementStrategyKey: "binpack", utils.PlacementWeightsKey: "ioload=3"}, policy: PlacementPolicy{Strategy: configuration.SchedulerBinpack, IOLoadWeight: 3}},
+		{parameters: map[string]string{utils.PlacementStrategyKey: "binpack", utils.VolumeAntiAffinityKey: "Disk"}, policy: PlacementPolicy{Strategy: configuration.SchedulerBinpack, CapacityWeight: 1, AntiAffinity: utils.AntiAffinityDisk}},
+		{parameters: map[string]string{utils.PlacementStrategyKey: "random"}, err: true},
+		{parameters: map[string]string{utils.VolumeAntiAffinityKey: "rack"}, err: true},
+		{parameters: map[string]string{utils.PlacementWeightsKey: "capacity=-1"}, err: true},
+		{parameters: map[string]string{utils.PlacementWeightsKey: "disk=1"}, err: true},
+		{parameters: map[string]string{utils.PlacementWeightsKey: "capacity=0"}, err: true},
+	}
+	for _, e := range table {
+		policy, err := ParsePlacementPolicy(e.parameters)
+		if e.err {
+			if err == nil {
+				t.Errorf("ParsePlacementPolicy %v expect error", e.parameters)
+			}
+			continue
+		}
+		if err != nil || policy != e.policy {
+			t.Errorf("ParsePlacementPolicy %v got %+v %v, want %+v", e.parameters, policy, err, e.policy)
+		}
+	}
+}
+
+func TestBestCandidate(t *testing.T) {
+	candidates := func() []candidate {
+		return []candidate{
+			{node: "node-1", group: "carina-vg-hdd", free: 100},
+			{node: "node-2", group: "carina-vg-hdd", free: 30},
+			{node: "node-3", group: "carina-vg-hdd", free: 200},
+		}
+	}
+	table := []struct {
+		policy  PlacementPolicy
+		volumes map[string]int64
+		ioLoads map[string]map[string]int64
+		node    string
+	}{
+		// 只按容量评分时与原有的最小/最大剩余容量选择一致
+		{policy: PlacementPolicy{Strategy: configuration.SchedulerBinpack, CapacityWeight: 1}, node: "node-2"},
+		{policy: PlacementPolicy{Strategy: configuration.SchedulerSpradout, CapacityWeight: 1}, node: "node-3"},
+		// 卷数量权重更高时选择卷少的节点
+		{policy: PlacementPolicy{Strategy: configuration.SchedulerSpradout, CapacityWeight: 1, VolumeWeight: 3}, volumes: map[string]int64{"node-1": 0, "node-2": 6, "node-3": 8}, node: "node-1"},
+		// IO负载权重更高时避开繁忙的节点，未上报IO负载的节点只按容量评分
+		{policy: PlacementPolicy{Strategy: configuration.SchedulerSpradout, CapacityWeight: 1, IOLoadWeight: 3}, ioLoads: map[string]map[string]int64{"node-1": {"carina-vg-hdd": 10}, "node-3": {"carina-vg-hdd": 90}}, node: "node-1"},
+	}
+	for _, e := range table {
+		if c := bestCandidate(candidates(), 10, e.policy, e.volumes, e.ioLoads); c.node != e.node {
+			t.Errorf("bestCandidate %+v got %s, want %s", e.policy, c.node, e.node)
+		}
+	}
+}
+
+func TestVolumeAntiAffinityGroup(t *testing.T) {
+	table := []struct {
+		name   string
+		labels map[string]string
+		group  string
+	}{
+		{name: "data-mysql-0", group: "default/data-mysql"},
+		{name: "data-mysql-12", group: "default/data-mysql"},
+		{name: "data-mysql-0", labels: map[string]string{utils.AntiAffinityGroupKey: "mysql"}, group: "default/mysql"},
+		{name: "data-mysql", group: ""},
+		{name: "0", group: ""},
+	}
+	for _, e := range table {
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: e.name, Labels: e.labels}}
+		if group := VolumeAntiAffinityGroup(pvc); group != e.group {
+			t.Errorf("VolumeAntiAffinityGroup %s %v got %s, want %s", e.name, e.labels, group, e.group)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/carina-io/carina/pkg/csidriver/csi"
 	"github.com/carina-io/carina/pkg/csidriver/driver/k8s"
 	"github.com/carina-io/carina/pkg/csidriver/filesystem"
+	"github.com/carina-io/carina/pkg/devicemanager/lvmd"
 	"github.com/carina-io/carina/pkg/devicemanager/types"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
 	"github.com/carina-io/carina/utils"
@@ -591,7 +592,7 @@ func (s *nodeService) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoReque
 func (s *nodeService) getLvFromContext(deviceGroup, volumeID string) (*types.LvInfo, error) {
 	lvs, err := s.volumeManager.VolumeList(volumeID, deviceGroup)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list lv :%v", err)
+		return nil, status.Errorf(lvmd.GRPCCode(err), "failed to list lv :%v", err)
 	}
 
 	for _, v := range lvs {
@@ -600,13 +601,18 @@ func (s *nodeService) getLvFromContext(deviceGroup, volumeID string) (*types.LvI
 		}
 	}
 
-	return nil, errors.New("not found")
+	// 由调用方返回NotFound
+	return nil, nil
 }
 
 func (s *nodeService) getBcacheDevice(volumeID string) (*types.BcacheDeviceInfo, error) {
 
-	for _, d := range []string{utils.DeviceVGHDD, utils.DeviceVGSSD} {
-		devicePath := filepath.Join("/dev", d, volumeID)
+	vgs, err := s.volumeManager.GetCurrentVgStruct()
+	if err != nil {
+		return nil, err
+	}
+	for _, vg := range vgs {
+		devicePath := filepath.Join("/dev", vg.VGName, volumeID)
 		_, err := os.Stat(devicePath)
 		if err == nil {
 			info, err := s.volumeManager.BcacheDeviceInfo(devicePath)
@@ -17,6 +17,7 @@ package runners
 
 import (
 	"context"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
 	"strings"
 	"time"
@@ -42,20 +43,48 @@ type VolumeMetrics struct {
 	UsedBytes  float64
 }
 
+// 磁盘及raid阵列健康信息
+type DiskHealthProvider interface {
+	DiskHealthList() []types.DiskHealth
+	RaidArrayList() []types.RaidArray
+}
+
 type metricsExporter struct {
 	nodeName         string
 	volume           volume.LocalVolume
+	diskHealth       DiskHealthProvider
 	vgFreeBytes      *prometheus.GaugeVec
 	vgTotalBytes     *prometheus.GaugeVec
+	vgDegraded       *prometheus.GaugeVec
 	volumeTotalBytes *prometheus.GaugeVec
 	volumeUsedBytes  *prometheus.GaugeVec
+	diskStatus       *prometheus.GaugeVec
+	diskSmart        *prometheus.GaugeVec
+	raidStatus       *prometheus.GaugeVec
+	raidRebuild      *prometheus.GaugeVec
+}
+
+// 磁盘健康状态指标取值
+var diskStatusValue = map[string]float64{
+	types.DiskHealthy: 0,
+	types.DiskWarning: 1,
+	types.DiskFailing: 2,
+	types.DiskUnknown: 3,
+}
+
+// raid阵列状态指标取值
+var raidStatusValue = map[string]float64{
+	types.RaidHealthy:    0,
+	types.RaidRebuilding: 1,
+	types.RaidDegraded:   2,
+	types.RaidFailed:     3,
 }
 
 var _ manager.LeaderElectionRunnable = &metricsExporter{}
 
 // NewMetricsExporter creates controller-runtime's manager.Runnable to run
 // a metrics exporter for a node.
-func NewMetricsExporter(nodeName string, volume volume.LocalVolume) manager.Runnable {
+func NewMetricsExporter(nodeName string, volume volume.LocalVolume, diskHealth DiskHealthProvider) manager.Runnable {
 	vgFreeBytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   metricsNamespace,
 		Subsystem:   "devicegroup",
@@ -72,6 +101,46 @@ func NewMetricsExporter(nodeName string, volume volume.LocalVolume) manager.Runn
 		ConstLabels: prometheus.Labels{"node": nodeName},
 	}, []string{"device_group"})
 
+	vgDegraded := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "devicegroup",
+		Name:        "degraded",
+		Help:        "1 if the device group is degraded and no new volume is placed on it",
+		ConstLabels: prometheus.Labels{"node": nodeName},
+	}, []string{"device_group"})
+
+	diskStatus := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "disk",
+		Name:        "health_status",
+		Help:        "Disk SMART health status, 0 healthy 1 warning 2 failing 3 unknown",
+		ConstLabels: prometheus.Labels{"node": nodeName},
+	}, []string{"device", "reason"})
+
+	diskSmart := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "disk",
+		Name:        "smart_attribute",
+		Help:        "Disk SMART attributes: reallocated_sectors pending_sectors media_errors percentage_used temperature_celsius",
+		ConstLabels: prometheus.Labels{"node": nodeName},
+	}, []string{"device", "attribute"})
+
+	raidStatus := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "raid",
+		Name:        "status",
+		Help:        "Raid array status, 0 healthy 1 rebuilding 2 degraded 3 failed",
+		ConstLabels: prometheus.Labels{"node": nodeName},
+	}, []string{"array", "device_group", "level"})
+
+	raidRebuild := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Subsystem:   "raid",
+		Name:        "rebuild_percent",
+		Help:        "Raid array rebuild progress in percent",
+		ConstLabels: prometheus.Labels{"node": nodeName},
+	}, []string{"array"})
+
 	volumeTotalBytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   metricsNamespace,
 		Subsystem:   "volume",
@@ -92,14 +161,25 @@ func NewMetricsExporter(nodeName string, volume volume.LocalVolume) manager.Runn
 	metrics.Registry.MustRegister(vgFreeBytes)
 	metrics.Registry.MustRegister(volumeTotalBytes)
 	metrics.Registry.MustRegister(volumeUsedBytes)
+	metrics.Registry.MustRegister(vgDegraded)
+	metrics.Registry.MustRegister(diskStatus)
+	metrics.Registry.MustRegister(diskSmart)
+	metrics.Registry.MustRegister(raidStatus)
+	metrics.Registry.MustRegister(raidRebuild)
 
 	return &metricsExporter{
 		nodeName:         nodeName,
 		volume:           volume,
+		diskHealth:       diskHealth,
 		vgFreeBytes:      vgFreeBytes,
 		vgTotalBytes:     vgTotalBytes,
+		vgDegraded:       vgDegraded,
 		volumeTotalBytes: volumeTotalBytes,
 		volumeUsedBytes:  volumeUsedBytes,
+		diskStatus:       diskStatus,
+		diskSmart:        diskSmart,
+		raidStatus:       raidStatus,
+		raidRebuild:      raidRebuild,
 	}
 }
 
@@ -107,6 +187,8 @@ func NewMetricsExporter(nodeName string, volume volume.LocalVolume) manager.Runn
 func (m *metricsExporter) Start(ctx context.Context) error {
 	metricsCh := make(chan DeviceMetrics)
 	volumeCh := make(chan VolumeMetrics)
+	healthCh := make(chan []types.DiskHealth)
+	raidCh := make(chan []types.RaidArray)
 	go func() {
 		for {
 			select {
@@ -115,9 +197,36 @@ func (m *metricsExporter) Start(ctx context.Context) error {
 			case met := <-metricsCh:
 				m.vgTotalBytes.WithLabelValues(met.DeviceGroup).Set(float64(met.TotalBytes))
 				m.vgFreeBytes.WithLabelValues(met.DeviceGroup).Set(float64(met.FreeBytes))
+				degraded := float64(0)
+				if _, ok := m.volume.DeviceGroupDegraded(met.DeviceGroup); ok {
+					degraded = 1
+				}
+				m.vgDegraded.WithLabelValues(met.DeviceGroup).Set(degraded)
 			case vc := <-volumeCh:
 				m.volumeTotalBytes.WithLabelValues(vc.Volume).Set(float64(vc.TotalBytes))
 				m.volumeUsedBytes.WithLabelValues(vc.Volume).Set(vc.UsedBytes)
+			case hl := <-healthCh:
+				// 移除的磁盘不再上报
+				m.diskStatus.Reset()
+				m.diskSmart.Reset()
+				for _, h := range hl {
+					m.diskStatus.WithLabelValues(h.Device, h.Reason).Set(diskStatusValue[h.Status])
+					if h.Status == types.DiskUnknown {
+						continue
+					}
+					m.diskSmart.WithLabelValues(h.Device, "reallocated_sectors").Set(float64(h.ReallocatedSectors))
+					m.diskSmart.WithLabelValues(h.Device, "pending_sectors").Set(float64(h.PendingSectors))
+					m.diskSmart.WithLabelValues(h.Device, "media_errors").Set(float64(h.MediaErrors))
+					m.diskSmart.WithLabelValues(h.Device, "percentage_used").Set(float64(h.PercentageUsed))
+					m.diskSmart.WithLabelValues(h.Device, "temperature_celsius").Set(float64(h.Temperature))
+				}
+			case rl := <-raidCh:
+				m.raidStatus.Reset()
+				m.raidRebuild.Reset()
+				for _, a := range rl {
+					m.raidStatus.WithLabelValues(a.Name, a.VGName, a.Level).Set(raidStatusValue[a.Health()])
+					m.raidRebuild.WithLabelValues(a.Name).Set(a.Rebuild)
+				}
 			}
 		}
 	}()
@@ -149,6 +258,11 @@ func (m *metricsExporter) Start(ctx context.Context) error {
 				}
 			}
 		}
+
+		if m.diskHealth != nil {
+			healthCh <- m.diskHealth.DiskHealthList()
+			raidCh <- m.diskHealth.RaidArrayList()
+		}
 	}
 	return nil
 }
@@ -0,0 +1,453 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	carinav1 "github.com/carina-io/carina/api/v1"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/pkg/devicemanager/volume"
+	"github.com/carina-io/carina/utils"
+	"github.com/carina-io/carina/utils/log"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+const decommissionInterval = 30 * time.Second
+
+// 磁盘下线状态文件，需要挂载宿主机目录
+var decommissionStateFile = "/var/lib/carina/decommission.json"
+
+// 开始下线磁盘，先将pv设置为不可分配，再由后台任务迁移数据、移出vg卷组并清除磁盘
+func (dm *DeviceManager) Decommission(device string) (*types.Decommission, error) {
+	dm.decommissionLock.Lock()
+	defer dm.decommissionLock.Unlock()
+
+	if d, ok := dm.decommission[device]; ok && d.Phase != types.DecommissionCompleted && d.Phase != types.DecommissionFailed {
+		return d, nil
+	}
+
+	pv, err := dm.LvmManager.PVDisplay(device)
+	if err != nil || pv == nil {
+		return nil, fmt.Errorf("pv %s not found", device)
+	}
+	if !strings.HasPrefix(pv.VGName, types.KEYWORD) {
+		return nil, fmt.Errorf("pv %s is not managed by carina", device)
+	}
+
+	// vg卷组中其他pv的剩余空间需要容纳该pv上已分配的空间
+	vgs, err := dm.VolumeManager.GetCurrentVgStruct()
+	if err != nil {
+		return nil, err
+	}
+	otherFree := uint64(0)
+	otherCount := 0
+	for _, vg := range vgs {
+		if vg.VGName != pv.VGName {
+			continue
+		}
+		for _, p := range vg.PVS {
+			if p.PVName == device || strings.Contains(p.PVName, "unknown") {
+				continue
+			}
+			otherFree += p.PVFree
+			otherCount++
+		}
+	}
+	used := pv.PVSize - pv.PVFree
+	if used > 0 && otherCount == 0 {
+		return nil, fmt.Errorf("pv %s is the only pv of %s and still has allocated extents", device, pv.VGName)
+	}
+	if used > otherFree {
+		return nil, fmt.Errorf("not enough space in %s to move %d bytes from %s, free %d bytes", pv.VGName, used, device, otherFree)
+	}
+	if used > 0 {
+		if _, err := dm.decommissionTargets(device, pv.VGName, used); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := dm.LvmManager.PVChangeAllocatable(device, false); err != nil {
+		return nil, fmt.Errorf("set pv %s not allocatable failed %s", device, err.Error())
+	}
+
+	d := &types.Decommission{
+		Device:     device,
+		VGName:     pv.VGName,
+		Phase:      types.DecommissionPending,
+		StartTime:  time.Now(),
+		UpdateTime: time.Now(),
+	}
+	if disks, err := dm.DiskManager.ListDevicesDetail(device); err == nil {
+		for _, disk := range disks {
+			if disk.Name == device {
+				d.Serial = disk.Serial
+			}
+		}
+	}
+	dm.decommission[device] = d
+	dm.saveDecommission()
+	log.Infof("start decommission disk %s in %s", device, pv.VGName)
+	dm.recordNodeEvent(corev1.EventTypeNormal, "DiskDecommission", "start decommission disk %s in %s", device, pv.VGName)
+
+	select {
+	case dm.decommissionChan <- struct{}{}:
+	default:
+	}
+	return d, nil
+}
+
+// 删除已完成或失败的下线记录，已下线的磁盘可以再次被发现
+func (dm *DeviceManager) ForgetDecommission(device string) error {
+	dm.decommissionLock.Lock()
+	defer dm.decommissionLock.Unlock()
+
+	d, ok := dm.decommission[device]
+	if !ok {
+		return fmt.Errorf("decommission %s not found", device)
+	}
+	if d.Phase != types.DecommissionCompleted && d.Phase != types.DecommissionFailed {
+		return fmt.Errorf("decommission %s is %s", device, d.Phase)
+	}
+	delete(dm.decommission, device)
+	dm.saveDecommission()
+	return nil
+}
+
+// 磁盘下线状态，按设备名称排序
+func (dm *DeviceManager) DecommissionList() []types.Decommission {
+	dm.decommissionLock.Lock()
+	defer dm.decommissionLock.Unlock()
+	result := []types.Decommission{}
+	for _, d := range dm.decommission {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Device < result[j].Device
+	})
+	return result
+}
+
+// 磁盘是否正在下线或者已经下线，已下线的磁盘不会被再次加入vg卷组，更换磁盘后序列号不同则不受影响
+func (dm *DeviceManager) decommissioned(disk *types.LocalDisk) bool {
+	dm.decommissionLock.Lock()
+	defer dm.decommissionLock.Unlock()
+	d, ok := dm.decommission[disk.Name]
+	if !ok || d.Phase == types.DecommissionFailed {
+		return false
+	}
+	return d.Serial == "" || disk.Serial == "" || d.Serial == disk.Serial
+}
+
+// 磁盘是否正在下线
+func (dm *DeviceManager) decommissioning(device string) bool {
+	dm.decommissionLock.Lock()
+	defer dm.decommissionLock.Unlock()
+	d, ok := dm.decommission[device]
+	return ok && d.Phase != types.DecommissionCompleted && d.Phase != types.DecommissionFailed
+}
+
+func (dm *DeviceManager) DecommissionTask() {
+	dm.resumeDecommission()
+
+	ticker1 := time.NewTicker(decommissionInterval)
+	go func(t *time.Ticker) {
+		defer ticker1.Stop()
+		for {
+			select {
+			case <-t.C:
+				dm.processDecommission()
+			case <-dm.decommissionChan:
+				dm.processDecommission()
+			case <-dm.stopChan:
+				log.Info("stop disk decommission...")
+				return
+			}
+		}
+	}(ticker1)
+}
+
+// 服务重启后加载下线状态，并恢复中断的数据迁移
+func (dm *DeviceManager) resumeDecommission() {
+	dm.loadDecommission()
+	for _, d := range dm.DecommissionList() {
+		if d.Phase == types.DecommissionMoving {
+			if err := dm.LvmManager.PVMove(""); err != nil {
+				log.Warnf("resume pvmove failed %s", err.Error())
+			}
+			break
+		}
+	}
+}
+
+// 推进所有下线任务
+func (dm *DeviceManager) processDecommission() {
+	for _, device := range dm.annotatedDecommission() {
+		dm.decommissionLock.Lock()
+		_, ok := dm.decommission[device]
+		dm.decommissionLock.Unlock()
+		if ok {
+			continue
+		}
+		if _, err := dm.Decommission(device); err != nil {
+			log.Errorf("decommission disk %s failed %s", device, err.Error())
+			dm.decommissionLock.Lock()
+			dm.decommission[device] = &types.Decommission{
+				Device:     device,
+				Phase:      types.DecommissionFailed,
+				Message:    err.Error(),
+				StartTime:  time.Now(),
+				UpdateTime: time.Now(),
+			}
+			dm.saveDecommission()
+			dm.decommissionLock.Unlock()
+			dm.recordNodeEvent(corev1.EventTypeWarning, "DiskDecommissionFailed", "decommission disk %s failed: %s", device, err.Error())
+		}
+	}
+
+	for _, d := range dm.DecommissionList() {
+		if d.Phase == types.DecommissionCompleted || d.Phase == types.DecommissionFailed {
+			continue
+		}
+		phase := d.Phase
+		err := dm.stepDecommission(&d)
+		if err != nil {
+			log.Errorf("decommission disk %s failed %s", d.Device, err.Error())
+			d.Phase = types.DecommissionFailed
+			d.Message = err.Error()
+			_ = dm.LvmManager.PVChangeAllocatable(d.Device, true)
+		}
+		d.UpdateTime = time.Now()
+
+		// 执行期间记录可能已被删除或重建，此时丢弃本次结果
+		dm.decommissionLock.Lock()
+		current, ok := dm.decommission[d.Device]
+		if !ok || !current.StartTime.Equal(d.StartTime) {
+			dm.decommissionLock.Unlock()
+			continue
+		}
+		dm.decommission[d.Device] = &d
+		dm.saveDecommission()
+		dm.decommissionLock.Unlock()
+
+		if phase == d.Phase {
+			continue
+		}
+		switch d.Phase {
+		case types.DecommissionCompleted:
+			dm.recordNodeEvent(corev1.EventTypeNormal, "DiskDecommissioned", "disk %s removed from %s", d.Device, d.VGName)
+			dm.VolumeManager.NoticeUpdateCapacity([]string{d.VGName})
+		case types.DecommissionFailed:
+			dm.recordNodeEvent(corev1.EventTypeWarning, "DiskDecommissionFailed", "decommission disk %s failed: %s", d.Device, d.Message)
+		}
+	}
+}
+
+// 下线任务的单步执行，依次为迁移数据、移出vg卷组并清除磁盘
+func (dm *DeviceManager) stepDecommission(d *types.Decommission) error {
+	pv, err := dm.LvmManager.PVDisplay(d.Device)
+	if err != nil || pv == nil {
+		// pv已经移除
+		if d.Phase == types.DecommissionReducing {
+			d.Phase = types.DecommissionCompleted
+			d.Progress = 100
+			return nil
+		}
+		return fmt.Errorf("pv %s not found", d.Device)
+	}
+	if pv.VGName == "" {
+		d.Phase = types.DecommissionReducing
+		if err := dm.LvmManager.PVRemove(d.Device); err != nil {
+			return err
+		}
+		return dm.wipeDecommission(d)
+	}
+	if pv.VGName != d.VGName {
+		return fmt.Errorf("pv %s moved to vg %s", d.Device, pv.VGName)
+	}
+
+	progress, moving, err := dm.LvmManager.PVMoveProgress(d.VGName)
+	if err != nil {
+		return err
+	}
+	if moving {
+		if d.Phase == types.DecommissionMoving {
+			d.Progress = progress
+			d.Message = fmt.Sprintf("moved %.2f%%", progress)
+		}
+		return nil
+	}
+
+	if pv.PVSize > pv.PVFree {
+		if d.Phase == types.DecommissionMoving {
+			// 迁移结束仍有已分配的空间，可能是迁移被中断，重新开始迁移
+			log.Warnf("pv %s still has %d bytes allocated, restart pvmove", d.Device, pv.PVSize-pv.PVFree)
+		}
+		targets, err := dm.decommissionTargets(d.Device, d.VGName, pv.PVSize-pv.PVFree)
+		if err != nil {
+			return err
+		}
+		if len(targets) > 0 {
+			log.Infof("move extents of %s to %s", d.Device, strings.Join(targets, ","))
+		}
+		if err := dm.LvmManager.PVMove(d.Device, targets...); err != nil {
+			return err
+		}
+		d.Phase = types.DecommissionMoving
+		d.Message = "moving extents"
+		return nil
+	}
+
+	// 移出vg卷组需要获取锁，获取失败则下次重试
+	if !dm.Mutex.TryAcquire(volume.VOLUMEMUTEX) {
+		log.Info("wait other task release mutex, please retry...")
+		return nil
+	}
+	defer dm.Mutex.Release(volume.VOLUMEMUTEX)
+	d.Phase = types.DecommissionReducing
+	d.Progress = 100
+	if err := dm.LvmManager.VGReduce(d.VGName, d.Device); err != nil {
+		return err
+	}
+	return dm.wipeDecommission(d)
+}
+
+// 磁盘上有反亲和组的卷时，只能迁移到没有同组卷的磁盘上，返回pvmove的目标pv，为空表示不限制
+func (dm *DeviceManager) decommissionTargets(device, vgName string, size uint64) ([]string, error) {
+	segments, err := dm.LvmManager.PVSegments(vgName)
+	if err != nil {
+		return nil, err
+	}
+	onDevice := map[string]bool{}
+	for _, lv := range segments[device] {
+		// thin pool的空间分配在隐藏的数据卷与元数据卷上
+		pool := strings.TrimSuffix(strings.TrimSuffix(lv, "_tdata"), "_tmeta")
+		if strings.HasPrefix(pool, volume.THIN) {
+			onDevice[strings.TrimPrefix(pool, volume.THIN)] = true
+		}
+	}
+	if len(onDevice) == 0 || dm.cache == nil {
+		return nil, nil
+	}
+
+	lvList := &carinav1.LogicVolumeList{}
+	if err := dm.cache.List(context.Background(), lvList, client.MatchingFields{"nodeName": dm.nodeName}); err != nil {
+		return nil, err
+	}
+	groups := map[string][]string{}
+	moving := map[string]bool{}
+	for _, lv := range lvList.Items {
+		group := lv.Annotations[utils.AntiAffinityGroupKey]
+		if group == "" || lv.DeletionTimestamp != nil || lv.Spec.NodeName != dm.nodeName || lv.Spec.DeviceGroup != vgName {
+			continue
+		}
+		groups[group] = append(groups[group], lv.Name)
+		if onDevice[lv.Name] {
+			moving[group] = true
+		}
+	}
+	apart := []string{}
+	for group := range moving {
+		apart = append(apart, groups[group]...)
+	}
+	if len(apart) == 0 {
+		return nil, nil
+	}
+	sort.Strings(apart)
+	targets, err := dm.VolumeManager.ApartPvs(vgName, size, apart)
+	if err != nil {
+		return nil, fmt.Errorf("cannot move %s apart from volumes %s: %s", device, strings.Join(apart, ","), err.Error())
+	}
+	return targets, nil
+}
+
+func (dm *DeviceManager) wipeDecommission(d *types.Decommission) error {
+	if err := dm.DiskManager.WipeDevice(d.Device); err != nil {
+		return err
+	}
+	d.Phase = types.DecommissionCompleted
+	d.Progress = 100
+	d.Message = "disk removed and wiped"
+	log.Infof("decommission disk %s completed", d.Device)
+	return nil
+}
+
+// Node注解中需要下线的磁盘
+func (dm *DeviceManager) annotatedDecommission() []string {
+	if dm.cache == nil {
+		return nil
+	}
+	node := &corev1.Node{}
+	if err := dm.cache.Get(context.Background(), client.ObjectKey{Name: dm.nodeName}, node); err != nil {
+		log.Warnf("get node %s failed %s", dm.nodeName, err.Error())
+		return nil
+	}
+	devices := []string{}
+	for _, d := range strings.Split(node.Annotations[utils.DiskDecommissionKey], ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+func (dm *DeviceManager) loadDecommission() {
+	dm.decommissionLock.Lock()
+	defer dm.decommissionLock.Unlock()
+	data, err := ioutil.ReadFile(decommissionStateFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Errorf("read decommission state failed %s", err.Error())
+		}
+		return
+	}
+	state := map[string]*types.Decommission{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Errorf("parse decommission state failed %s", err.Error())
+		return
+	}
+	dm.decommission = state
+}
+
+// 调用方需持有decommissionLock
+func (dm *DeviceManager) saveDecommission() {
+	data, err := json.Marshal(dm.decommission)
+	if err != nil {
+		log.Errorf("marshal decommission state failed %s", err.Error())
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(decommissionStateFile), 0755); err != nil {
+		log.Errorf("create decommission state dir failed %s", err.Error())
+		return
+	}
+	tmp := decommissionStateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		log.Errorf("write decommission state failed %s", err.Error())
+		return
+	}
+	if err := os.Rename(tmp, decommissionStateFile); err != nil {
+		log.Errorf("save decommission state failed %s", err.Error())
+	}
+}
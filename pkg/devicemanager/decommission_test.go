@@ -0,0 +1,225 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"encoding/json"
+	carinav1 "github.com/carina-io/carina/api/v1"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils"
+	"github.com/carina-io/carina/utils/mutx"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func newDecommissionManager(t *testing.T, lvm *fakeLvm, dev *fakeDevice) *DeviceManager {
+	stateFile := decommissionStateFile
+	decommissionStateFile = filepath.Join(t.TempDir(), "decommission.json")
+	t.Cleanup(func() { decommissionStateFile = stateFile })
+	return &DeviceManager{
+		LvmManager:    lvm,
+		DiskManager:   dev,
+		VolumeManager: &fakeVolume{},
+		Mutex:         mutx.NewGlobalLocks(),
+		decommission:  map[string]*types.Decommission{},
+	}
+}
+
+// 写入服务重启前保存的下线状态
+func saveDecommissionState(t *testing.T, d *types.Decommission) {
+	data, err := json.Marshal(map[string]*types.Decommission{d.Device: d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(decommissionStateFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 状态文件中保存的下线记录
+func savedDecommission(t *testing.T) map[string]*types.Decommission {
+	data, err := ioutil.ReadFile(decommissionStateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := map[string]*types.Decommission{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestDecommissionSteps(t *testing.T) {
+	lvm := &fakeLvm{pvs: []types.PVInfo{
+		{PVName: "/dev/sdb", VGName: "carina-vg-hdd", PVSize: 100 << 30, PVFree: 60 << 30},
+		{PVName: "/dev/sdc", VGName: "carina-vg-hdd", PVSize: 100 << 30, PVFree: 100 << 30},
+	}}
+	dev := &fakeDevice{}
+	dm := newDecommissionManager(t, lvm, dev)
+	dm.decommission["/dev/sdb"] = &types.Decommission{Device: "/dev/sdb", VGName: "carina-vg-hdd", Phase: types.DecommissionPending, StartTime: time.Now()}
+
+	// 开始迁移数据
+	dm.processDecommission()
+	if d := savedDecommission(t)["/dev/sdb"]; d == nil || d.Phase != types.DecommissionMoving {
+		t.Fatalf("expect moving after first step, got %+v", d)
+	}
+	// 迁移中更新进度
+	lvm.moving, lvm.progress = true, 40
+	dm.processDecommission()
+	if d := dm.DecommissionList()[0]; d.Phase != types.DecommissionMoving || d.Progress != 40 {
+		t.Fatalf("expect moving 40%%, got %+v", d)
+	}
+	// 迁移完成后移出vg卷组并清除磁盘
+	lvm.moving = false
+	lvm.pvs[0].PVFree = lvm.pvs[0].PVSize
+	dm.processDecommission()
+	if d := savedDecommission(t)["/dev/sdb"]; d == nil || d.Phase != types.DecommissionCompleted {
+		t.Fatalf("expect completed, got %+v", d)
+	}
+	expect := []string{"pvmove /dev/sdb", "vgreduce carina-vg-hdd /dev/sdb"}
+	if !reflect.DeepEqual(lvm.calls, expect) {
+		t.Errorf("lvm calls %v, want %v", lvm.calls, expect)
+	}
+	if !reflect.DeepEqual(dev.wiped, []string{"/dev/sdb"}) {
+		t.Errorf("wiped %v", dev.wiped)
+	}
+}
+
+func TestResumeDecommission(t *testing.T) {
+	table := []struct {
+		name   string
+		phase  string
+		pv     *types.PVInfo
+		phase2 string
+		calls  []string
+		wiped  bool
+	}{
+		// 迁移被中断，恢复迁移后仍有已分配的空间则重新开始迁移
+		{"moving interrupted", types.DecommissionMoving, &types.PVInfo{VGName: "carina-vg-hdd", PVSize: 100 << 30, PVFree: 60 << 30},
+			types.DecommissionMoving, []string{"pvmove", "pvmove /dev/sdb"}, false},
+		{"moving finished", types.DecommissionMoving, &types.PVInfo{VGName: "carina-vg-hdd", PVSize: 100 << 30, PVFree: 100 << 30},
+			types.DecommissionCompleted, []string{"pvmove", "vgreduce carina-vg-hdd /dev/sdb"}, true},
+		// 已移出vg卷组但未清除磁盘
+		{"reduced", types.DecommissionReducing, &types.PVInfo{PVSize: 100 << 30, PVFree: 100 << 30},
+			types.DecommissionCompleted, []string{"pvremove /dev/sdb"}, true},
+		// pv已经移除
+		{"pv removed", types.DecommissionReducing, nil, types.DecommissionCompleted, nil, false},
+	}
+
+	for _, e := range table {
+		lvm := &fakeLvm{}
+		if e.pv != nil {
+			e.pv.PVName = "/dev/sdb"
+			lvm.pvs = []types.PVInfo{*e.pv, {PVName: "/dev/sdc", VGName: "carina-vg-hdd", PVSize: 100 << 30, PVFree: 100 << 30}}
+		}
+		dev := &fakeDevice{}
+		dm := newDecommissionManager(t, lvm, dev)
+		saveDecommissionState(t, &types.Decommission{Device: "/dev/sdb", VGName: "carina-vg-hdd", Phase: e.phase, StartTime: time.Now()})
+
+		dm.resumeDecommission()
+		dm.processDecommission()
+		if d := savedDecommission(t)["/dev/sdb"]; d == nil || d.Phase != e.phase2 {
+			t.Errorf("%s: expect %s, got %+v", e.name, e.phase2, d)
+		}
+		if !reflect.DeepEqual(lvm.calls, e.calls) {
+			t.Errorf("%s: lvm calls %v, want %v", e.name, lvm.calls, e.calls)
+		}
+		if (len(dev.wiped) > 0) != e.wiped {
+			t.Errorf("%s: wiped %v", e.name, dev.wiped)
+		}
+	}
+}
+
+func TestDecommissionRemovedMeanwhile(t *testing.T) {
+	lvm := &fakeLvm{pvs: []types.PVInfo{
+		{PVName: "/dev/sdb", VGName: "carina-vg-hdd", PVSize: 100 << 30, PVFree: 60 << 30},
+	}}
+	dm := newDecommissionManager(t, lvm, &fakeDevice{})
+	dm.decommission["/dev/sdb"] = &types.Decommission{Device: "/dev/sdb", VGName: "carina-vg-hdd", Phase: types.DecommissionPending, StartTime: time.Now()}
+	dm.saveDecommission()
+	// 执行过程中记录被删除
+	lvm.onPVMove = func() {
+		dm.decommissionLock.Lock()
+		delete(dm.decommission, "/dev/sdb")
+		dm.saveDecommission()
+		dm.decommissionLock.Unlock()
+	}
+
+	dm.processDecommission()
+	if len(dm.DecommissionList()) != 0 {
+		t.Errorf("removed decommission resurrected %+v", dm.DecommissionList())
+	}
+	if state := savedDecommission(t); len(state) != 0 {
+		t.Errorf("removed decommission saved %+v", state)
+	}
+}
+
+func TestDecommissionAntiAffinity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = carinav1.AddToScheme(scheme)
+	// a与b属于同一反亲和组，分别在sdb与sdc上
+	lvs := []client.Object{}
+	for _, name := range []string{"a", "b"} {
+		lvs = append(lvs, &carinav1.LogicVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{utils.AntiAffinityGroupKey: "db"}},
+			Spec:       carinav1.LogicVolumeSpec{NodeName: "node-1", DeviceGroup: "carina-vg-hdd"},
+		})
+	}
+	c := &fakeCache{reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(lvs...).Build()}
+	segments := map[string][]string{"/dev/sdb": {"thin-a_tdata", "thin-a_tmeta"}, "/dev/sdc": {"thin-b_tdata"}}
+
+	for _, withSdd := range []bool{true, false} {
+		lvm := &fakeLvm{segments: segments, pvs: []types.PVInfo{
+			{PVName: "/dev/sdb", VGName: "carina-vg-hdd", PVAttr: "a--", PVSize: 100 << 30, PVFree: 60 << 30},
+			{PVName: "/dev/sdc", VGName: "carina-vg-hdd", PVAttr: "a--", PVSize: 100 << 30, PVFree: 90 << 30},
+		}}
+		if withSdd {
+			lvm.pvs = append(lvm.pvs, types.PVInfo{PVName: "/dev/sdd", VGName: "carina-vg-hdd", PVAttr: "a--", PVSize: 100 << 30, PVFree: 100 << 30})
+		}
+		vg := types.VgGroup{VGName: "carina-vg-hdd"}
+		for i := range lvm.pvs {
+			vg.PVS = append(vg.PVS, &lvm.pvs[i])
+		}
+		dm := newDecommissionManager(t, lvm, &fakeDevice{})
+		dm.nodeName = "node-1"
+		dm.cache = c
+		dm.VolumeManager = &fakeVolume{vgs: []types.VgGroup{vg}, lvm: lvm}
+
+		_, err := dm.Decommission("/dev/sdb")
+		if !withSdd {
+			// sdc上有同组的卷，没有可迁移的磁盘时拒绝下线
+			if err == nil || len(lvm.calls) != 0 {
+				t.Errorf("expect decommission refused, err %v calls %v", err, lvm.calls)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		dm.processDecommission()
+		expect := []string{"pvchange /dev/sdb false", "pvmove /dev/sdb /dev/sdd"}
+		if !reflect.DeepEqual(lvm.calls, expect) {
+			t.Errorf("lvm calls %v, want %v", lvm.calls, expect)
+		}
+	}
+}
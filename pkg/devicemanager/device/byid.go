@@ -0,0 +1,59 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package device
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// udev创建的稳定名称链接，需要挂载宿主机/dev
+var diskByIDDir = "/dev/disk/by-id"
+
+// DiskByID /dev/disk/by-id下的稳定名称，key为设备路径如/dev/sdb1
+// 同一设备有多个名称时优先使用wwn-开头的名称，dm-name-以设备映射名称命名，不是稳定标识
+func DiskByID() map[string]string {
+	result := map[string]string{}
+	infos, err := ioutil.ReadDir(diskByIDDir)
+	if err != nil {
+		return result
+	}
+	names := []string{}
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), "dm-name-") {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		wi, wj := strings.HasPrefix(names[i], "wwn-"), strings.HasPrefix(names[j], "wwn-")
+		if wi != wj {
+			return wi
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		target, err := filepath.EvalSymlinks(filepath.Join(diskByIDDir, name))
+		if err != nil {
+			continue
+		}
+		if _, ok := result[target]; !ok {
+			result[target] = name
+		}
+	}
+	return result
+}
@@ -21,6 +21,8 @@ import (
 	"github.com/carina-io/carina/utils/exec"
 	"github.com/carina-io/carina/utils/log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
@@ -32,6 +34,12 @@ type LocalDevice interface {
 
 	ListDevicesDetail(device string) ([]*types.LocalDisk, error)
 	GetDiskUsed(device string) (uint64, error)
+	// 清除设备上的文件系统、分区表及lvm签名
+	WipeDevice(device string) error
+	// 受保护的系统设备及原因
+	ProtectedDevices() map[string]string
+	// 探测设备上的签名
+	ProbeSignature(device string) ([]string, error)
 }
 
 type LocalDeviceImplement struct {
@@ -48,8 +56,8 @@ func (ld *LocalDeviceImplement) ListDevices() ([]string, error) {
 }
 
 /*
-# lsblk --pairs --paths --bytes --all --output NAME,FSTYPE,MOUNTPOINT,SIZE,STATE,TYPE,ROTA,RO,PKNAME
-NAME="/dev/sda" FSTYPE="" MOUNTPOINT="" SIZE="85899345920" STATE="running" TYPE="disk" ROTA="1" RO="0"
+# lsblk --pairs --paths --bytes --all --output NAME,FSTYPE,MOUNTPOINT,SIZE,STATE,TYPE,ROTA,RO,PKNAME,MODEL,SERIAL,TRAN
+NAME="/dev/sda" FSTYPE="" MOUNTPOINT="" SIZE="85899345920" STATE="running" TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="VBOX HARDDISK" SERIAL="VB5d1c6b4c-0f0e2f3a" TRAN="sata"
 NAME="/dev/sda1" FSTYPE="ext4" MOUNTPOINT="/" SIZE="81604378624" STATE="" TYPE="part" ROTA="1" RO="0"
 NAME="/dev/sda2" FSTYPE="" MOUNTPOINT="" SIZE="1024" STATE="" TYPE="part" ROTA="1" RO="0"
 NAME="/dev/sda5" FSTYPE="swap" MOUNTPOINT="[SWAP]" SIZE="4291821568" STATE="" TYPE="part" ROTA="1" RO="0"
@@ -65,7 +73,7 @@ NAME="/dev/loop6" FSTYPE="" MOUNTPOINT="" SIZE="" STATE="" TYPE="loop" ROTA="1"
 NAME="/dev/loop7" FSTYPE="" MOUNTPOINT="" SIZE="" STATE="" TYPE="loop" ROTA="1" RO="0"
 */
 func (ld *LocalDeviceImplement) ListDevicesDetail(device string) ([]*types.LocalDisk, error) {
-	args := []string{"--pairs", "--paths", "--bytes", "--all", "--output", "NAME,FSTYPE,MOUNTPOINT,SIZE,STATE,TYPE,ROTA,RO,PKNAME"}
+	args := []string{"--pairs", "--paths", "--bytes", "--all", "--output", "NAME,FSTYPE,MOUNTPOINT,SIZE,STATE,TYPE,ROTA,RO,PKNAME,MODEL,SERIAL,TRAN"}
 	if device != "" {
 		args = append(args, device)
 	}
@@ -75,7 +83,46 @@ func (ld *LocalDeviceImplement) ListDevicesDetail(device string) ([]*types.Local
 		return nil, err
 	}
 
-	return parseDiskString(devices), nil
+	disks := parseDiskString(devices)
+	fillTransport(disks)
+	return mergeMultipath(disks), nil
+}
+
+// 老版本lsblk不支持TRAN列，或者分区没有传输类型，需要通过sysfs及父设备补全
+// 对于nvme设备，/sys/block/nvme0n1 链接到 /sys/devices/.../nvme/nvme0/nvme0n1
+func fillTransport(disks []*types.LocalDisk) {
+	transport := map[string]string{}
+	for _, d := range disks {
+		if d.Transport == "" && d.ParentName == "" {
+			d.Transport = sysfsTransport(d.Name)
+		}
+		transport[d.Name] = d.Transport
+	}
+	for _, d := range disks {
+		if d.Transport != "" || d.ParentName == "" {
+			continue
+		}
+		if t, ok := transport[d.ParentName]; ok && t != "" {
+			d.Transport = t
+		} else {
+			d.Transport = sysfsTransport(d.ParentName)
+		}
+	}
+}
+
+func sysfsTransport(device string) string {
+	name := filepath.Base(device)
+	if name == "." || name == "/" {
+		return ""
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join("/sys/block", name))
+	if err == nil && strings.Contains(p, "/nvme/") {
+		return types.TransportNVMe
+	}
+	if strings.HasPrefix(name, "nvme") {
+		return types.TransportNVMe
+	}
+	return ""
 }
 
 /*
@@ -93,6 +140,13 @@ func (ld *LocalDeviceImplement) GetDiskUsed(device string) (uint64, error) {
 	return stat.Blocks - stat.Bavail, nil
 }
 
+// lsblk --pairs 输出的值可能包含空格，如MODEL="VBOX HARDDISK"，不能直接按空格切分
+var diskPairRegex = regexp.MustCompile(`([A-Z:-]+)="([^"]*)"`)
+
+func (ld *LocalDeviceImplement) WipeDevice(device string) error {
+	return ld.Executor.ExecuteCommand("wipefs", "-a", device)
+}
+
 func parseDiskString(diskString string) []*types.LocalDisk {
 	resp := []*types.LocalDisk{}
 
@@ -100,43 +154,47 @@ func parseDiskString(diskString string) []*types.LocalDisk {
 		return resp
 	}
 
-	diskString = strings.ReplaceAll(diskString, "\"", "")
-	//diskString = strings.ReplaceAll(diskString, " ", "")
-
 	vgsList := strings.Split(diskString, "\n")
 	for _, vgs := range vgsList {
 		tmp := types.LocalDisk{}
-		vg := strings.Split(vgs, " ")
-		for _, v := range vg {
-			k := strings.Split(v, "=")
-
-			switch k[0] {
+		for _, k := range diskPairRegex.FindAllStringSubmatch(vgs, -1) {
+			value := strings.TrimSpace(k[2])
+			switch k[1] {
 			case "NAME":
-				tmp.Name = k[1]
+				tmp.Name = value
 			case "MOUNTPOINT":
-				tmp.MountPoint = k[1]
+				tmp.MountPoint = value
 			case "SIZE":
-				tmp.Size, _ = strconv.ParseUint(k[1], 10, 64)
+				tmp.Size, _ = strconv.ParseUint(value, 10, 64)
 			case "STATE":
-				tmp.State = k[1]
+				tmp.State = value
 			case "TYPE":
-				tmp.Type = k[1]
+				tmp.Type = value
 			case "ROTA":
-				tmp.Rotational = k[1]
+				tmp.Rotational = value
 			case "RO":
-				if k[1] == "1" {
+				if value == "1" {
 					tmp.Readonly = true
 				} else {
 					tmp.Readonly = false
 				}
 			case "FSTYPE":
-				tmp.Filesystem = k[1]
+				tmp.Filesystem = value
 			case "PKNAME":
-				tmp.ParentName = k[1]
+				tmp.ParentName = value
+			case "MODEL":
+				tmp.Model = value
+			case "SERIAL":
+				tmp.Serial = value
+			case "TRAN":
+				tmp.Transport = value
 			default:
-				log.Warnf("undefined filed %s-%s", k[0], k[1])
+				log.Warnf("undefined filed %s-%s", k[1], value)
 			}
 		}
+		if tmp.Name == "" {
+			continue
+		}
 		resp = append(resp, &tmp)
 	}
 	return resp
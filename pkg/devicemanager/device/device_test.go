@@ -0,0 +1,167 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package device
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskString(t *testing.T) {
+	output := `NAME="/dev/sda" FSTYPE="" MOUNTPOINT="" SIZE="85899345920" STATE="running" TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="VBOX HARDDISK" SERIAL="VB5d1c6b4c" TRAN="sata"
+NAME="/dev/sda1" FSTYPE="ext4" MOUNTPOINT="/" SIZE="81604378624" STATE="" TYPE="part" ROTA="1" RO="0" PKNAME="/dev/sda" MODEL="" SERIAL="" TRAN=""
+NAME="/dev/nvme0n1" FSTYPE="" MOUNTPOINT="" SIZE="1000204886016" STATE="live" TYPE="disk" ROTA="0" RO="0" PKNAME="" MODEL="INTEL SSDPE2KX010T8" SERIAL="PHLJ0001" TRAN=""
+NAME="/dev/nvme0n1p1" FSTYPE="" MOUNTPOINT="" SIZE="500107862016" STATE="" TYPE="part" ROTA="0" RO="0" PKNAME="/dev/nvme0n1" MODEL="" SERIAL="" TRAN=""`
+
+	disks := parseDiskString(output)
+	fillTransport(disks)
+	if len(disks) != 4 {
+		t.Fatalf("parseDiskString got %d disks, want 4", len(disks))
+	}
+
+	table := []struct {
+		name      string
+		model     string
+		transport string
+		size      uint64
+	}{
+		{"/dev/sda", "VBOX HARDDISK", "sata", 85899345920},
+		{"/dev/sda1", "", "sata", 81604378624},
+		{"/dev/nvme0n1", "INTEL SSDPE2KX010T8", "nvme", 1000204886016},
+		{"/dev/nvme0n1p1", "", "nvme", 500107862016},
+	}
+	for i, e := range table {
+		d := disks[i]
+		if d.Name != e.name || d.Model != e.model || d.Transport != e.transport || d.Size != e.size {
+			t.Errorf("parseDiskString got %+v, want %+v", d, e)
+		}
+	}
+}
+
+func TestParseMountInfo(t *testing.T) {
+	output := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+1203 1180 253:0 /var/lib/kubelet/pods /var/lib/kubelet/pods rw,relatime master:1 - xfs /dev/mapper/centos-root rw
+1210 1180 8:2 /boot/my\040dir /mnt/a\040b rw - ext4 /dev/sda2 rw
+1211 1180 0:52 / /dev/shm rw - tmpfs shm rw`
+
+	mounts := parseMountInfo(bufio.NewScanner(strings.NewReader(output)))
+	if len(mounts) != 4 {
+		t.Fatalf("parseMountInfo got %d mounts, want 4", len(mounts))
+	}
+	table := []struct {
+		devNum     string
+		root       string
+		mountPoint string
+		protected  string
+	}{
+		{"8:1", "/", "/", "/"},
+		{"253:0", "/var/lib/kubelet/pods", "/var/lib/kubelet/pods", "/var/lib/kubelet"},
+		{"8:2", "/boot/my dir", "/mnt/a b", ""},
+		{"0:52", "/", "/dev/shm", ""},
+	}
+	for i, e := range table {
+		m := mounts[i]
+		if m.devNum != e.devNum || m.root != e.root || m.mountPoint != e.mountPoint {
+			t.Errorf("parseMountInfo got %+v, want %+v", m, e)
+		}
+		if p := protectedPath(m.mountPoint); p != e.protected {
+			t.Errorf("protectedPath(%s) = %s, want %s", m.mountPoint, p, e.protected)
+		}
+	}
+	if p := protectedPath(mounts[2].root); p != "/boot" {
+		t.Errorf("protectedPath(%s) = %s, want /boot", mounts[2].root, p)
+	}
+}
+
+func TestMergeMultipath(t *testing.T) {
+	output := `NAME="/dev/sdc" FSTYPE="mpath_member" MOUNTPOINT="" SIZE="4000787030016" STATE="running" TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="MSA 2040 SAS" SERIAL="600c0ff0001e" TRAN="sas"
+NAME="/dev/mapper/mpatha" FSTYPE="" MOUNTPOINT="" SIZE="4000787030016" STATE="running" TYPE="mpath" ROTA="1" RO="0" PKNAME="/dev/sdc" MODEL="" SERIAL="" TRAN=""
+NAME="/dev/sdd" FSTYPE="mpath_member" MOUNTPOINT="" SIZE="4000787030016" STATE="running" TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="MSA 2040 SAS" SERIAL="600c0ff0001e" TRAN="sas"
+NAME="/dev/mapper/mpatha" FSTYPE="" MOUNTPOINT="" SIZE="4000787030016" STATE="running" TYPE="mpath" ROTA="1" RO="0" PKNAME="/dev/sdd" MODEL="" SERIAL="" TRAN=""
+NAME="/dev/sde" FSTYPE="" MOUNTPOINT="" SIZE="85899345920" STATE="running" TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="VBOX HARDDISK" SERIAL="VB5d1c6b4c" TRAN="sata"`
+
+	disks := parseDiskString(output)
+	fillTransport(disks)
+	disks = mergeMultipath(disks)
+	if len(disks) != 4 {
+		t.Fatalf("mergeMultipath got %d disks, want 4", len(disks))
+	}
+	mp := disks[1]
+	if mp.Name != "/dev/mapper/mpatha" || mp.ParentName != "" || mp.Transport != "sas" || mp.Serial != "600c0ff0001e" {
+		t.Errorf("mergeMultipath got %+v", mp)
+	}
+	if len(mp.Paths) != 2 || mp.Paths[0] != "/dev/sdc" || mp.Paths[1] != "/dev/sdd" {
+		t.Errorf("mergeMultipath paths %v", mp.Paths)
+	}
+
+	paths := MultipathPaths(disks)
+	if len(paths) != 2 || paths["/dev/sdd"] != "/dev/mapper/mpatha" {
+		t.Errorf("MultipathPaths got %v", paths)
+	}
+	if _, ok := paths["/dev/sde"]; ok {
+		t.Error("/dev/sde is not a multipath path")
+	}
+}
+
+func TestDiskByID(t *testing.T) {
+	dir := t.TempDir()
+	dev := filepath.Join(dir, "dev")
+	byID := filepath.Join(dir, "by-id")
+	for _, d := range []string{dev, byID} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"sdb", "sdb1", "vdc", "dm-3"} {
+		if err := os.WriteFile(filepath.Join(dev, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"ata-ST4000NM_ZC1A2B3C":        "sdb",
+		"wwn-0x5000c500a1b2c3d4":       "sdb",
+		"wwn-0x5000c500a1b2c3d4-part1": "sdb1",
+		"ata-ST4000NM_ZC1A2B3C-part1":  "sdb1",
+		"virtio-data01":                "vdc",
+		"dm-name-mpatha":               "dm-3",
+	}
+	for name, target := range links {
+		if err := os.Symlink(filepath.Join(dev, target), filepath.Join(byID, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := diskByIDDir
+	diskByIDDir = byID
+	defer func() { diskByIDDir = old }()
+	ids := DiskByID()
+	expect := map[string]string{
+		filepath.Join(dev, "sdb"):  "wwn-0x5000c500a1b2c3d4",
+		filepath.Join(dev, "sdb1"): "wwn-0x5000c500a1b2c3d4-part1",
+		filepath.Join(dev, "vdc"):  "virtio-data01",
+	}
+	if len(ids) != len(expect) {
+		t.Errorf("DiskByID got %v, want %v", ids, expect)
+	}
+	for k, v := range expect {
+		if ids[k] != v {
+			t.Errorf("DiskByID %s got %s, want %s", k, ids[k], v)
+		}
+	}
+}
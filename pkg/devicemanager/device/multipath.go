@@ -0,0 +1,104 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package device
+
+import (
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// dm-multipath设备的dm uuid前缀，后面为WWID
+const mpathUUIDPrefix = "mpath-"
+
+/*
+lsblk会在每条路径下重复列出multipath设备及其上层设备，PKNAME分别为各路径
+NAME="/dev/sdc" ... TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="MSA 2040 SAS" SERIAL="600c0ff0001e" TRAN="sas"
+NAME="/dev/mapper/mpatha" ... TYPE="mpath" ROTA="1" RO="0" PKNAME="/dev/sdc" MODEL="" SERIAL="" TRAN=""
+NAME="/dev/sdd" ... TYPE="disk" ROTA="1" RO="0" PKNAME="" MODEL="MSA 2040 SAS" SERIAL="600c0ff0001e" TRAN="sas"
+NAME="/dev/mapper/mpatha" ... TYPE="mpath" ROTA="1" RO="0" PKNAME="/dev/sdd" MODEL="" SERIAL="" TRAN=""
+合并为一个设备，记录其所有路径，型号、序列号及传输类型取自路径设备
+*/
+func mergeMultipath(disks []*types.LocalDisk) []*types.LocalDisk {
+	all := map[string]*types.LocalDisk{}
+	for _, d := range disks {
+		if _, ok := all[d.Name]; !ok {
+			all[d.Name] = d
+		}
+	}
+
+	result := []*types.LocalDisk{}
+	merged := map[string]*types.LocalDisk{}
+	for _, d := range disks {
+		m, ok := merged[d.Name]
+		if !ok {
+			merged[d.Name] = d
+			result = append(result, d)
+			m = d
+		}
+		if d.Type != types.MultiPath {
+			continue
+		}
+		if d.ParentName != "" {
+			m.Paths = append(m.Paths, d.ParentName)
+		}
+		m.ParentName = ""
+		path, ok := all[d.ParentName]
+		if !ok {
+			continue
+		}
+		if m.Model == "" {
+			m.Model = path.Model
+		}
+		if m.Serial == "" {
+			m.Serial = path.Serial
+		}
+		if m.Transport == "" {
+			m.Transport = path.Transport
+		}
+	}
+	for _, d := range result {
+		if d.Type == types.MultiPath && d.WWID == "" {
+			d.WWID = sysfsWWID(d.Name)
+		}
+	}
+	return result
+}
+
+// /dev/mapper/mpatha 链接到 /dev/dm-3，/sys/block/dm-3/dm/uuid 内容为 mpath-<WWID>
+func sysfsWWID(device string) string {
+	p, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return ""
+	}
+	uuid, err := ioutil.ReadFile(filepath.Join("/sys/block", filepath.Base(p), "dm", "uuid"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(uuid)), mpathUUIDPrefix)
+}
+
+// MultipathPaths multipath设备的路径设备，key为路径设备，value为multipath设备
+func MultipathPaths(disks []*types.LocalDisk) map[string]string {
+	result := map[string]string{}
+	for _, d := range disks {
+		for _, p := range d.Paths {
+			result[p] = d.Name
+		}
+	}
+	return result
+}
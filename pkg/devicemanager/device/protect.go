@@ -0,0 +1,250 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package device
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/carina-io/carina/utils/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 系统及kubelet、容器运行时所在的目录，承载这些目录的磁盘无论如何配置都不会被使用
+var protectedPaths = []string{
+	"/",
+	"/boot",
+	"/boot/efi",
+	"/var/lib/kubelet",
+	"/var/lib/docker",
+	"/var/lib/containerd",
+	"/var/lib/containers",
+	"/var/lib/rancher",
+	"/run/containerd",
+}
+
+// 宿主机挂载信息，需要挂载宿主机/proc到/host/proc，未挂载时以容器视角为准
+var mountInfoFiles = []string{"/host/proc/1/mountinfo", "/proc/1/mountinfo", "/proc/self/mountinfo"}
+
+const (
+	sysDevBlock   = "/sys/dev/block"
+	sysClassBlock = "/sys/class/block"
+	procSwaps     = "/proc/swaps"
+)
+
+type mountInfo struct {
+	// major:minor
+	devNum     string
+	root       string
+	mountPoint string
+}
+
+// 受保护的块设备，key为设备名称如sda，value为原因
+// 包括承载系统目录的设备、swap设备，以及它们所在的磁盘和底层设备
+func (ld *LocalDeviceImplement) ProtectedDevices() map[string]string {
+	protected := map[string]string{}
+	add := func(name, reason string) {
+		for _, n := range blockAncestors(name) {
+			if _, ok := protected[n]; !ok {
+				protected[n] = reason
+			}
+		}
+	}
+
+	for _, file := range mountInfoFiles {
+		mounts, err := readMountInfo(file)
+		if err != nil {
+			continue
+		}
+		for _, m := range mounts {
+			path := protectedPath(m.mountPoint)
+			// 容器中通过hostPath挂载的目录，root为其在宿主机文件系统中的路径
+			if path == "" && m.root != "/" {
+				path = protectedPath(m.root)
+			}
+			if path == "" {
+				continue
+			}
+			name := devNumToName(m.devNum)
+			if name == "" {
+				continue
+			}
+			add(name, fmt.Sprintf("holds %s", path))
+		}
+	}
+
+	for _, name := range swapDevices() {
+		add(name, "swap device")
+	}
+	return protected
+}
+
+// 检查设备及其所在磁盘、底层设备是否受保护
+func ProtectedReason(protected map[string]string, device string) (string, bool) {
+	for _, n := range blockAncestors(filepath.Base(device)) {
+		if reason, ok := protected[n]; ok {
+			return fmt.Sprintf("system disk %s %s", n, reason), true
+		}
+	}
+	return "", false
+}
+
+/*
+# wipefs -n -p /dev/sdb
+# offset,uuid,label,type
+0x200,,,gpt
+0x1fffffe00,,,gpt
+0x1fe,,,PMBR
+*/
+// 探测设备上的分区表、文件系统、raid、lvm等签名
+func (ld *LocalDeviceImplement) ProbeSignature(device string) ([]string, error) {
+	output, err := ld.Executor.ExecuteCommandWithCombinedOutput("wipefs", "-n", "-p", device)
+	if err != nil {
+		return nil, fmt.Errorf("probe %s signature failed %s", device, output)
+	}
+	signatures := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		signatures = append(signatures, fields[len(fields)-1])
+	}
+	return signatures, nil
+}
+
+func protectedPath(path string) string {
+	for _, p := range protectedPaths {
+		if path == p {
+			return p
+		}
+		if p != "/" && strings.HasPrefix(path, p+"/") {
+			return p
+		}
+	}
+	return ""
+}
+
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func readMountInfo(file string) ([]mountInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(bufio.NewScanner(f)), nil
+}
+
+func parseMountInfo(scanner *bufio.Scanner) []mountInfo {
+	mounts := []mountInfo{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, mountInfo{
+			devNum:     fields[2],
+			root:       unescapeMountPath(fields[3]),
+			mountPoint: unescapeMountPath(fields[4]),
+		})
+	}
+	return mounts
+}
+
+// mountinfo中空格等字符以八进制转义，如\040
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			var c int
+			if _, err := fmt.Sscanf(path[i+1:i+4], "%o", &c); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// 8:1 -> sda1，非块设备(如overlay、tmpfs)返回空
+func devNumToName(devNum string) string {
+	p, err := os.Readlink(filepath.Join(sysDevBlock, devNum))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(p)
+}
+
+// 设备自身、所在磁盘以及dm、md等设备的底层设备
+func blockAncestors(name string) []string {
+	result := []string{}
+	visited := map[string]bool{}
+	queue := []string{name}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == "" || visited[n] {
+			continue
+		}
+		visited[n] = true
+		result = append(result, n)
+
+		// 分区的父设备为其所在磁盘
+		if _, err := os.Stat(filepath.Join(sysClassBlock, n, "partition")); err == nil {
+			if p, err := filepath.EvalSymlinks(filepath.Join(sysClassBlock, n)); err == nil {
+				queue = append(queue, filepath.Base(filepath.Dir(p)))
+			}
+		}
+		slaves, err := ioutil.ReadDir(filepath.Join(sysClassBlock, n, "slaves"))
+		if err == nil {
+			for _, s := range slaves {
+				queue = append(queue, s.Name())
+			}
+		}
+	}
+	return result
+}
+
+// Filename    Type       Size     Used  Priority
+// /dev/dm-1   partition  8388604  0     -2
+func swapDevices() []string {
+	data, err := ioutil.ReadFile(procSwaps)
+	if err != nil {
+		log.Warnf("read %s failed %s", procSwaps, err.Error())
+		return nil
+	}
+	devices := []string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 2 || fields[1] != "partition" {
+			continue
+		}
+		name := fields[0]
+		if p, err := filepath.EvalSymlinks(name); err == nil {
+			name = p
+		}
+		devices = append(devices, filepath.Base(name))
+	}
+	return devices
+}
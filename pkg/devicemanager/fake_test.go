@@ -0,0 +1,172 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"context"
+	"fmt"
+	"github.com/carina-io/carina/pkg/devicemanager/device"
+	"github.com/carina-io/carina/pkg/devicemanager/lvmd"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/pkg/devicemanager/volume"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+)
+
+// 测试用的lvm实现，记录执行的命令，未覆盖的方法调用时panic
+type fakeLvm struct {
+	lvmd.Lvm2
+	pvs      []types.PVInfo
+	moving   bool
+	progress float64
+	calls    []string
+	// pv上分配的lv
+	segments map[string][]string
+	// pvmove开始时回调，模拟并发操作
+	onPVMove func()
+}
+
+func (f *fakeLvm) PVS() ([]types.PVInfo, error) {
+	return f.pvs, nil
+}
+
+func (f *fakeLvm) PVResize(dev string) error {
+	return nil
+}
+
+func (f *fakeLvm) PVDisplay(dev string) (*types.PVInfo, error) {
+	for _, pv := range f.pvs {
+		if pv.PVName == dev {
+			return &pv, nil
+		}
+	}
+	return nil, lvmd.NewError(lvmd.ErrNotFound, "pv %s not found", dev)
+}
+
+func (f *fakeLvm) PVChangeAllocatable(dev string, allocatable bool) error {
+	f.calls = append(f.calls, fmt.Sprintf("pvchange %s %t", dev, allocatable))
+	return nil
+}
+
+func (f *fakeLvm) PVSegments(vg string) (map[string][]string, error) {
+	return f.segments, nil
+}
+
+func (f *fakeLvm) PVMove(dev string, targets ...string) error {
+	f.calls = append(f.calls, strings.TrimSpace("pvmove "+dev+" "+strings.Join(targets, " ")))
+	if f.onPVMove != nil {
+		f.onPVMove()
+	}
+	return nil
+}
+
+func (f *fakeLvm) PVMoveProgress(vg string) (float64, bool, error) {
+	return f.progress, f.moving, nil
+}
+
+func (f *fakeLvm) VGReduce(vg, pv string) error {
+	f.calls = append(f.calls, "vgreduce "+vg+" "+pv)
+	for i := range f.pvs {
+		if f.pvs[i].PVName == pv {
+			f.pvs[i].VGName = ""
+		}
+	}
+	return nil
+}
+
+func (f *fakeLvm) PVRemove(dev string) error {
+	f.calls = append(f.calls, "pvremove "+dev)
+	pvs := []types.PVInfo{}
+	for _, pv := range f.pvs {
+		if pv.PVName != dev {
+			pvs = append(pvs, pv)
+		}
+	}
+	f.pvs = pvs
+	return nil
+}
+
+// 测试用的磁盘设备实现
+type fakeDevice struct {
+	device.LocalDevice
+	disks []*types.LocalDisk
+	wiped []string
+}
+
+func (f *fakeDevice) ProtectedDevices() map[string]string {
+	return map[string]string{}
+}
+
+func (f *fakeDevice) WipeDevice(dev string) error {
+	f.wiped = append(f.wiped, dev)
+	return nil
+}
+
+func (f *fakeDevice) ListDevicesDetail(dev string) ([]*types.LocalDisk, error) {
+	result := []*types.LocalDisk{}
+	for _, d := range f.disks {
+		if dev == "" || d.Name == dev {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+// 测试用的卷管理实现
+type fakeVolume struct {
+	volume.LocalVolume
+	pvs []types.PVInfo
+	vgs []types.VgGroup
+	// 设置后按lvm中的pv计算反亲和的目标磁盘
+	lvm lvmd.Lvm2
+}
+
+func (f *fakeVolume) GetCurrentPvStruct() ([]types.PVInfo, error) {
+	return f.pvs, nil
+}
+
+func (f *fakeVolume) GetCurrentVgStruct() ([]types.VgGroup, error) {
+	return f.vgs, nil
+}
+
+func (f *fakeVolume) NoticeUpdateCapacity(vgName []string) {}
+
+func (f *fakeVolume) ApartPvs(vgName string, size uint64, apart []string) ([]string, error) {
+	if f.lvm == nil {
+		return nil, fmt.Errorf("no lvm for %s", vgName)
+	}
+	return (&volume.LocalVolumeImplement{Lv: f.lvm}).ApartPvs(vgName, size, apart)
+}
+
+// 测试用的informer缓存，读取fake client中的对象
+type fakeCache struct {
+	cache.Cache
+	reader client.Reader
+}
+
+func newFakeCache(objs ...client.Object) *fakeCache {
+	return &fakeCache{reader: fake.NewClientBuilder().WithObjects(objs...).Build()}
+}
+
+func (f *fakeCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return f.reader.Get(ctx, key, obj)
+}
+
+func (f *fakeCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return f.reader.List(ctx, list, opts...)
+}
@@ -0,0 +1,201 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils"
+	"github.com/carina-io/carina/utils/log"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 关闭SMART检查时，检查配置变更的时间间隔
+const smartIdleInterval = 600 * time.Second
+
+// 检查carina管理的磁盘SMART健康状态，状态变化时产生事件
+// 开启smartDegradeGroup时，磁盘故障会将其所在设备组标记为降级
+func (dm *DeviceManager) DiskHealthCheck() {
+	pvs, err := dm.VolumeManager.GetCurrentPvStruct()
+	if err != nil {
+		log.Errorf("get pv failed %s", err.Error())
+		return
+	}
+
+	// 按磁盘聚合pv所在的vg卷组，分区取其所在磁盘
+	diskGroups := map[string][]string{}
+	for _, pv := range pvs {
+		if !strings.HasPrefix(pv.VGName, types.KEYWORD) || strings.Contains(pv.PVName, "unknown") {
+			continue
+		}
+		disk := pv.PVName
+		devices, err := dm.DiskManager.ListDevicesDetail(pv.PVName)
+		if err != nil {
+			log.Warnf("get device %s failed %s", pv.PVName, err.Error())
+		}
+		for _, d := range devices {
+			if d.Name == pv.PVName && d.ParentName != "" {
+				disk = d.ParentName
+			}
+		}
+		if !utils.ContainsString(diskGroups[disk], pv.VGName) {
+			diskGroups[disk] = append(diskGroups[disk], pv.VGName)
+		}
+	}
+
+	now := time.Now()
+	current := map[string]*types.DiskHealth{}
+	for disk, vgs := range diskGroups {
+		health, err := dm.Smart.DiskHealth(disk)
+		if err != nil {
+			log.Warnf("get disk %s smart health failed %s", disk, err.Error())
+			health = &types.DiskHealth{
+				Device:  disk,
+				Status:  types.DiskUnknown,
+				Reason:  "SmartError",
+				Message: err.Error(),
+			}
+		}
+		sort.Strings(vgs)
+		health.DeviceGroups = vgs
+		health.LastCheckTime = now
+		health.LastTransitionTime = now
+
+		dm.healthLock.RLock()
+		old, ok := dm.diskHealth[disk]
+		dm.healthLock.RUnlock()
+		if ok && old.Status == health.Status {
+			health.LastTransitionTime = old.LastTransitionTime
+		} else {
+			dm.recordDiskHealthEvent(old, health)
+		}
+		if health.Status != types.DiskHealthy {
+			log.Warnf("disk %s health %s %s: %s", disk, health.Status, health.Reason, health.Message)
+		}
+		current[disk] = health
+	}
+
+	dm.healthLock.Lock()
+	dm.diskHealth = current
+	dm.healthLock.Unlock()
+
+	// 设备组降级状态
+	degraded := map[string]string{}
+	if configuration.SmartDegradeGroup() {
+		for _, h := range current {
+			if h.Status != types.DiskFailing {
+				continue
+			}
+			for _, vg := range h.DeviceGroups {
+				degraded[vg] = fmt.Sprintf("disk %s %s", h.Device, h.Reason)
+			}
+		}
+	}
+	vgs, err := dm.VolumeManager.GetCurrentVgStruct()
+	if err != nil {
+		log.Errorf("get current vg struct failed %s", err.Error())
+	}
+	for _, vg := range vgs {
+		if _, ok := degraded[vg.VGName]; !ok && strings.HasPrefix(vg.VGName, types.KEYWORD) {
+			degraded[vg.VGName] = ""
+		}
+	}
+	for vg, reason := range degraded {
+		dm.VolumeManager.SetDeviceGroupDegraded(vg, reason)
+	}
+}
+
+// 关闭SMART检查后清理健康信息并取消设备组降级
+func (dm *DeviceManager) resetDiskHealth() {
+	dm.healthLock.Lock()
+	health := dm.diskHealth
+	dm.diskHealth = map[string]*types.DiskHealth{}
+	dm.healthLock.Unlock()
+
+	for _, h := range health {
+		for _, vg := range h.DeviceGroups {
+			dm.VolumeManager.SetDeviceGroupDegraded(vg, "")
+		}
+	}
+}
+
+// 磁盘健康状态变化时在Node上产生事件
+func (dm *DeviceManager) recordDiskHealthEvent(old, health *types.DiskHealth) {
+	// 首次检查只关注异常的磁盘，虚拟磁盘通常不支持SMART
+	if old == nil && (health.Status == types.DiskHealthy || health.Status == types.DiskUnknown) {
+		return
+	}
+	eventType := corev1.EventTypeWarning
+	if health.Status == types.DiskHealthy {
+		eventType = corev1.EventTypeNormal
+	}
+	dm.recordNodeEvent(eventType, "Disk"+health.Status, "disk %s of %s is %s: %s %s",
+		health.Device, strings.Join(health.DeviceGroups, ","), strings.ToLower(health.Status), health.Reason, health.Message)
+}
+
+// 磁盘健康信息，按设备名称排序
+func (dm *DeviceManager) DiskHealthList() []types.DiskHealth {
+	dm.healthLock.RLock()
+	defer dm.healthLock.RUnlock()
+	result := []types.DiskHealth{}
+	for _, h := range dm.diskHealth {
+		result = append(result, *h)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Device < result[j].Device
+	})
+	return result
+}
+
+func (dm *DeviceManager) DiskHealthTask() {
+	checkInterval := configuration.SmartCheckInterval()
+	if checkInterval == 0 {
+		checkInterval = int64(smartIdleInterval.Seconds())
+	}
+
+	ticker1 := time.NewTicker(time.Duration(checkInterval) * time.Second)
+	go func(t *time.Ticker) {
+		defer ticker1.Stop()
+		// 服务启动先检查一次
+		if configuration.SmartCheckInterval() > 0 {
+			dm.DiskHealthCheck()
+		}
+		for {
+			select {
+			case <-t.C:
+				if configuration.SmartCheckInterval() == 0 {
+					checkInterval = 0
+					ticker1.Reset(smartIdleInterval)
+					dm.resetDiskHealth()
+					continue
+				}
+				if checkInterval != configuration.SmartCheckInterval() {
+					checkInterval = configuration.SmartCheckInterval()
+					ticker1.Reset(time.Duration(checkInterval) * time.Second)
+				}
+				log.Infof("clock %d second disk health check...", checkInterval)
+				dm.DiskHealthCheck()
+			case <-dm.stopChan:
+				log.Info("stop disk health check...")
+				return
+			}
+		}
+	}(ticker1)
+}
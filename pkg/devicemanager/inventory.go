@@ -0,0 +1,219 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/device"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils/log"
+	"regexp"
+	"strings"
+)
+
+// 磁盘筛选条件，一次扫描内共用
+type diskFilter struct {
+	diskSelector *regexp.Regexp
+	diskGroups   []types.DiskGroup
+	// 系统盘保护规则优先于任何配置
+	protected  map[string]string
+	parentDisk map[string]int8
+	// multipath设备的路径设备，路径设备不能单独使用
+	mpathPaths map[string]string
+}
+
+func (dm *DeviceManager) newDiskFilter(diskSelector *regexp.Regexp, diskGroups []types.DiskGroup, localDisk []*types.LocalDisk) *diskFilter {
+	f := &diskFilter{
+		diskSelector: diskSelector,
+		diskGroups:   diskGroups,
+		protected:    dm.DiskManager.ProtectedDevices(),
+		parentDisk:   map[string]int8{},
+		mpathPaths:   device.MultipathPaths(localDisk),
+	}
+	for _, d := range localDisk {
+		f.parentDisk[d.ParentName] = 1
+	}
+	return f
+}
+
+// 按照磁盘发现规则检查磁盘，返回磁盘应加入的vg卷组，不满足条件时返回原因
+// skip表示carina自身创建的设备，不需要检查
+func (dm *DeviceManager) checkDisk(f *diskFilter, d *types.LocalDisk) (string, string, bool) {
+	reason, skip := dm.checkDiskUsable(f, d)
+	if skip || reason != "" {
+		return "", reason, skip
+	}
+
+	if f.diskSelector == nil {
+		return "", "disk selector is empty", false
+	}
+	// multipath设备也可以通过WWID匹配
+	if !f.diskSelector.MatchString(d.Name) && (d.WWID == "" || !f.diskSelector.MatchString(d.WWID)) {
+		return "", fmt.Sprintf("mismatch disk selector %s", f.diskSelector.String()), false
+	}
+
+	vgName := matchDiskGroup(f.diskGroups, d)
+	if vgName == "" {
+		return "", fmt.Sprintf("mismatch disk group, rota: %s, tran: %s, model: %s", d.Rotational, d.Transport, d.Model), false
+	}
+	return vgName, "", false
+}
+
+// 检查磁盘是否可以安全的加入vg卷组，与diskSelector及磁盘分组配置无关
+func (dm *DeviceManager) checkDiskUsable(f *diskFilter, d *types.LocalDisk) (string, bool) {
+	if strings.Contains(d.Name, types.KEYWORD) {
+		return "", true
+	}
+	if strings.Contains(d.Name, "cache") {
+		return "", true
+	}
+	if strings.Contains(d.Type, types.RaidType) && dm.isRaidArray(d.Name) {
+		return "", true
+	}
+
+	if reason, ok := device.ProtectedReason(f.protected, d.Name); ok {
+		return reason, false
+	}
+	for _, p := range d.Paths {
+		if reason, ok := device.ProtectedReason(f.protected, p); ok {
+			return reason, false
+		}
+	}
+	if mp, ok := f.mpathPaths[d.Name]; ok {
+		return fmt.Sprintf("path of multipath device %s", mp), false
+	}
+
+	// 如果是其他磁盘Parent直接跳过
+	if _, ok := f.parentDisk[d.Name]; ok {
+		return "has partitions or holders", false
+	}
+
+	if d.Readonly || d.Size < 10<<30 || d.Filesystem != "" || d.MountPoint != "" {
+		return fmt.Sprintf("filesystem:%s mountpoint:%s readonly:%t size:%d", d.Filesystem, d.MountPoint, d.Readonly, d.Size), false
+	}
+
+	if dm.decommissioned(d) {
+		return "decommissioned", false
+	}
+
+	// 过滤不支持的磁盘类型
+	for _, t := range []string{types.LVMType, types.CryptType, types.RaidType, "rom"} {
+		if strings.Contains(d.Type, t) {
+			return fmt.Sprintf("unsupported disk type %s", d.Type), false
+		}
+	}
+
+	// 存在分区表或者任何签名的设备都不使用
+	signatures, err := dm.DiskManager.ProbeSignature(d.Name)
+	if err != nil {
+		return err.Error(), false
+	}
+	if len(signatures) > 0 {
+		return fmt.Sprintf("has signature %s", strings.Join(signatures, ",")), false
+	}
+
+	// 判断设备是否已经存在数据
+	dused, err := dm.DiskManager.GetDiskUsed(d.Name)
+	if err != nil {
+		return fmt.Sprintf("get disk used failed %v", err), false
+	}
+	if dused > 0 {
+		return "block device is not empty", false
+	}
+	return "", false
+}
+
+// 节点上所有块设备及其是否可被carina使用
+func (dm *DeviceManager) DiskInventory() ([]types.DiskInventory, error) {
+	localDisk, err := dm.DiskManager.ListDevicesDetail("")
+	if err != nil {
+		return nil, err
+	}
+
+	var diskSelector *regexp.Regexp
+	if dsList := configuration.DiskSelector(); len(dsList) > 0 {
+		diskSelector, err = regexp.Compile(strings.Join(dsList, "|"))
+		if err != nil {
+			log.Warnf("disk regex %s error %v ", strings.Join(dsList, "|"), err)
+			diskSelector = nil
+		}
+	}
+	filter := dm.newDiskFilter(diskSelector, configuration.DiskGroups(), localDisk)
+
+	pvVg := map[string]string{}
+	if pvs, err := dm.VolumeManager.GetCurrentPvStruct(); err == nil {
+		for _, pv := range pvs {
+			pvVg[pv.PVName] = pv.VGName
+		}
+	} else {
+		log.Warnf("get pv failed %s", err.Error())
+	}
+
+	health := map[string]string{}
+	for _, h := range dm.DiskHealthList() {
+		health[h.Device] = h.Status
+	}
+
+	result := []types.DiskInventory{}
+	for _, d := range localDisk {
+		inv := types.DiskInventory{LocalDisk: *d}
+		if h, ok := health[d.Name]; ok {
+			inv.Health = h
+		} else if h, ok := health[d.ParentName]; ok {
+			inv.Health = h
+		}
+
+		if vg := pvVg[d.Name]; vg != "" {
+			inv.VGName = vg
+			if strings.HasPrefix(vg, types.KEYWORD) {
+				inv.Verdict = types.DiskInUse
+				inv.Reason = fmt.Sprintf("pv of %s", vg)
+				if dm.decommissioning(d.Name) {
+					inv.Reason = fmt.Sprintf("pv of %s, decommissioning", vg)
+				}
+			} else {
+				inv.Verdict = types.DiskIneligible
+				inv.Reason = fmt.Sprintf("pv of other vg %s", vg)
+			}
+			result = append(result, inv)
+			continue
+		}
+
+		if a, ok := dm.raidMember(d.Name); ok {
+			inv.VGName = a.VGName
+			inv.Verdict = types.DiskInUse
+			inv.Reason = fmt.Sprintf("member of raid %s", a.Name)
+			result = append(result, inv)
+			continue
+		}
+
+		vgName, reason, skip := dm.checkDisk(filter, d)
+		switch {
+		case skip:
+			inv.Verdict = types.DiskInUse
+			inv.Reason = "carina device"
+		case reason != "":
+			inv.Verdict = types.DiskIneligible
+			inv.Reason = reason
+		default:
+			inv.Verdict = types.DiskEligible
+			inv.Reason = fmt.Sprintf("will be added to %s", vgName)
+		}
+		result = append(result, inv)
+	}
+	return result, nil
+}
@@ -0,0 +1,180 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/iostat"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils/log"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// 关闭IO采样时，检查配置变更的时间间隔
+	ioStatsIdleInterval = 600 * time.Second
+	// 设备组利用率变化超过该值时立即更新NodeStorage，其余变化随定时同步更新
+	ioStatsChangeThreshold = 10
+)
+
+// 采样/proc/diskstats，根据与上一次采样的差值计算各设备组的IO负载
+func (dm *DeviceManager) IOStatsCheck() {
+	pvs, err := dm.VolumeManager.GetCurrentPvStruct()
+	if err != nil {
+		log.Errorf("get pv failed %s", err.Error())
+		return
+	}
+	sample, err := iostat.ReadDiskStats()
+	if err != nil {
+		log.Errorf("read %s failed %s", iostat.DiskStatsPath, err.Error())
+		return
+	}
+	now := time.Now()
+
+	// pv路径可能是软链接，如/dev/md/name、/dev/mapper/mpatha，diskstats中为内核设备名称
+	groupDevices := map[string][]string{}
+	for _, pv := range pvs {
+		if !strings.HasPrefix(pv.VGName, types.KEYWORD) || strings.Contains(pv.PVName, "unknown") {
+			continue
+		}
+		dev, err := filepath.EvalSymlinks(pv.PVName)
+		if err != nil {
+			dev = pv.PVName
+		}
+		groupDevices[pv.VGName] = append(groupDevices[pv.VGName], filepath.Base(dev))
+	}
+
+	dm.ioLock.Lock()
+	prev, prevTime, old := dm.ioSample, dm.ioSampleTime, dm.ioStats
+	dm.ioSample, dm.ioSampleTime = sample, now
+	// 首次采样无法计算负载
+	if prev == nil {
+		dm.ioLock.Unlock()
+		return
+	}
+	current := map[string]iostat.Usage{}
+	for vg, devices := range groupDevices {
+		usages := []iostat.Usage{}
+		for _, d := range devices {
+			p, ok1 := prev[d]
+			c, ok2 := sample[d]
+			if !ok1 || !ok2 {
+				continue
+			}
+			usages = append(usages, iostat.DeviceUsage(p, c, now.Sub(prevTime)))
+		}
+		if len(usages) > 0 {
+			current[vg] = roundUsage(iostat.GroupUsage(usages))
+		}
+	}
+	dm.ioStats = current
+	dm.ioLock.Unlock()
+
+	if ioStatsChanged(old, current) {
+		select {
+		case dm.ioStatsChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 设备组增减或者利用率变化明显
+func ioStatsChanged(old, current map[string]iostat.Usage) bool {
+	if len(old) != len(current) {
+		return true
+	}
+	for vg, u := range current {
+		o, ok := old[vg]
+		if !ok || math.Abs(o.Utilization-u.Utilization) >= ioStatsChangeThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// 保留两位小数，避免NodeStorage对象中出现过长的浮点数
+func roundUsage(u iostat.Usage) iostat.Usage {
+	round := func(v float64) float64 {
+		return math.Round(v*100) / 100
+	}
+	return iostat.Usage{
+		Utilization: round(u.Utilization),
+		QueueDepth:  round(u.QueueDepth),
+		Latency:     round(u.Latency),
+		IOPS:        round(u.IOPS),
+	}
+}
+
+// 关闭IO采样后清理采样数据
+func (dm *DeviceManager) resetIOStats() {
+	dm.ioLock.Lock()
+	changed := len(dm.ioStats) > 0
+	dm.ioSample = nil
+	dm.ioStats = map[string]iostat.Usage{}
+	dm.ioLock.Unlock()
+	if changed {
+		select {
+		case dm.ioStatsChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 设备组最近一个采样周期的IO负载
+func (dm *DeviceManager) DeviceGroupIOStats(vg string) (iostat.Usage, bool) {
+	dm.ioLock.RLock()
+	defer dm.ioLock.RUnlock()
+	u, ok := dm.ioStats[vg]
+	return u, ok
+}
+
+func (dm *DeviceManager) IOStatsTask() {
+	checkInterval := configuration.IOStatsInterval()
+	if checkInterval == 0 {
+		checkInterval = int64(ioStatsIdleInterval.Seconds())
+	}
+
+	ticker1 := time.NewTicker(time.Duration(checkInterval) * time.Second)
+	go func(t *time.Ticker) {
+		defer ticker1.Stop()
+		// 服务启动先采样一次
+		if configuration.IOStatsInterval() > 0 {
+			dm.IOStatsCheck()
+		}
+		for {
+			select {
+			case <-t.C:
+				if configuration.IOStatsInterval() == 0 {
+					checkInterval = 0
+					ticker1.Reset(ioStatsIdleInterval)
+					dm.resetIOStats()
+					continue
+				}
+				if checkInterval != configuration.IOStatsInterval() {
+					checkInterval = configuration.IOStatsInterval()
+					ticker1.Reset(time.Duration(checkInterval) * time.Second)
+				}
+				dm.IOStatsCheck()
+			case <-dm.stopChan:
+				log.Info("stop io stats sampling...")
+				return
+			}
+		}
+	}(ticker1)
+}
@@ -0,0 +1,149 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package iostat
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiskStatsPath 内核块设备IO统计
+const DiskStatsPath = "/proc/diskstats"
+
+// DiskStat /proc/diskstats中单个设备的累计计数，时间单位为毫秒
+type DiskStat struct {
+	Name            string
+	ReadsCompleted  uint64
+	ReadTicks       uint64
+	WritesCompleted uint64
+	WriteTicks      uint64
+	InFlight        uint64
+	IOTicks         uint64
+	WeightedTicks   uint64
+}
+
+// Usage 一段时间内的IO负载
+type Usage struct {
+	// 设备忙碌时间占比 0-100
+	Utilization float64
+	// 平均队列深度
+	QueueDepth float64
+	// 平均每个IO的耗时(毫秒)
+	Latency float64
+	IOPS    float64
+}
+
+// ReadDiskStats 读取所有块设备的IO统计
+func ReadDiskStats() (map[string]DiskStat, error) {
+	content, err := ioutil.ReadFile(DiskStatsPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiskStats(string(content)), nil
+}
+
+// ParseDiskStats 解析/proc/diskstats
+// 示例: 8 0 sda 12345 0 2345678 9876 5432 0 345678 4321 0 8765 14197
+// 字段依次为major minor name reads merged sectors read_ms writes merged sectors write_ms in_flight io_ms weighted_ms
+func ParseDiskStats(content string) map[string]DiskStat {
+	stats := map[string]DiskStat{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		values := make([]uint64, 11)
+		valid := true
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i+3], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = v
+		}
+		if !valid {
+			continue
+		}
+		stats[fields[2]] = DiskStat{
+			Name:            fields[2],
+			ReadsCompleted:  values[0],
+			ReadTicks:       values[3],
+			WritesCompleted: values[4],
+			WriteTicks:      values[7],
+			InFlight:        values[8],
+			IOTicks:         values[9],
+			WeightedTicks:   values[10],
+		}
+	}
+	return stats
+}
+
+// DeviceUsage 根据两次采样计算设备在采样间隔内的IO负载
+func DeviceUsage(prev, cur DiskStat, interval time.Duration) Usage {
+	ms := float64(interval.Milliseconds())
+	if ms <= 0 {
+		return Usage{}
+	}
+	ios := delta(cur.ReadsCompleted, prev.ReadsCompleted) + delta(cur.WritesCompleted, prev.WritesCompleted)
+	ticks := delta(cur.ReadTicks, prev.ReadTicks) + delta(cur.WriteTicks, prev.WriteTicks)
+	usage := Usage{
+		Utilization: float64(delta(cur.IOTicks, prev.IOTicks)) * 100 / ms,
+		QueueDepth:  float64(delta(cur.WeightedTicks, prev.WeightedTicks)) / ms,
+		IOPS:        float64(ios) * 1000 / ms,
+	}
+	if usage.Utilization > 100 {
+		usage.Utilization = 100
+	}
+	if ios > 0 {
+		usage.Latency = float64(ticks) / float64(ios)
+	}
+	return usage
+}
+
+// GroupUsage 合并设备组内各设备的负载
+// 设备组的利用率及队列深度取最繁忙的设备，延迟按IO数量加权，IOPS求和
+func GroupUsage(usages []Usage) Usage {
+	group := Usage{}
+	totalIOs := 0.0
+	for _, u := range usages {
+		if u.Utilization > group.Utilization {
+			group.Utilization = u.Utilization
+		}
+		if u.QueueDepth > group.QueueDepth {
+			group.QueueDepth = u.QueueDepth
+		}
+		group.Latency += u.Latency * u.IOPS
+		totalIOs += u.IOPS
+		group.IOPS += u.IOPS
+	}
+	if totalIOs > 0 {
+		group.Latency = group.Latency / totalIOs
+	}
+	return group
+}
+
+// 计数器回绕或设备重建时按0处理
+func delta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
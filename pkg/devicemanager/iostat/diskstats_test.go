@@ -0,0 +1,66 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package iostat
+
+import (
+	"testing"
+	"time"
+)
+
+const diskstats = `   8       0 sda 1000 10 80000 2000 3000 20 240000 6000 0 5000 8000
+   8      16 sdb 500 0 4000 500 0 0 0 0 2 400 500
+ 253       0 dm-0 abc 0 0 0 0 0 0 0 0 0 0
+   7       0 loop0 0 0
+`
+
+func TestParseDiskStats(t *testing.T) {
+	stats := ParseDiskStats(diskstats)
+	if len(stats) != 2 {
+		t.Fatalf("parse diskstats %v", stats)
+	}
+	sda := stats["sda"]
+	if sda.ReadsCompleted != 1000 || sda.ReadTicks != 2000 || sda.WritesCompleted != 3000 || sda.WriteTicks != 6000 || sda.IOTicks != 5000 || sda.WeightedTicks != 8000 {
+		t.Errorf("parse sda %+v", sda)
+	}
+	if stats["sdb"].InFlight != 2 {
+		t.Errorf("parse sdb %+v", stats["sdb"])
+	}
+}
+
+func TestDeviceUsage(t *testing.T) {
+	prev := DiskStat{ReadsCompleted: 100, ReadTicks: 100, WritesCompleted: 100, WriteTicks: 300, IOTicks: 1000, WeightedTicks: 2000}
+	cur := DiskStat{ReadsCompleted: 300, ReadTicks: 300, WritesCompleted: 300, WriteTicks: 900, IOTicks: 6000, WeightedTicks: 22000}
+	u := DeviceUsage(prev, cur, 10*time.Second)
+	if u.Utilization != 50 || u.QueueDepth != 2 || u.IOPS != 40 || u.Latency != 2 {
+		t.Errorf("device usage %+v", u)
+	}
+	if u = DeviceUsage(prev, DiskStat{IOTicks: 30000}, 10*time.Second); u.Utilization != 100 || u.IOPS != 0 || u.Latency != 0 {
+		t.Errorf("device usage after reset %+v", u)
+	}
+}
+
+func TestGroupUsage(t *testing.T) {
+	g := GroupUsage([]Usage{
+		{Utilization: 20, QueueDepth: 3, Latency: 1, IOPS: 30},
+		{Utilization: 80, QueueDepth: 1, Latency: 5, IOPS: 10},
+	})
+	if g.Utilization != 80 || g.QueueDepth != 3 || g.IOPS != 40 || g.Latency != 2 {
+		t.Errorf("group usage %+v", g)
+	}
+	if g = GroupUsage(nil); g != (Usage{}) {
+		t.Errorf("empty group usage %+v", g)
+	}
+}
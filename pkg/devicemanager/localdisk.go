@@ -0,0 +1,281 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"context"
+	"fmt"
+	carinav1 "github.com/carina-io/carina/api/v1"
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/device"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var localDiskNameRegex = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// 根据LocalDisk对象计算磁盘变更计划，对象不存在时按照配置文件创建
+// LocalDisk对象的spec是磁盘归属的唯一依据，移除磁盘与配置文件方式一样需要审批
+func (dm *DeviceManager) planLocalDisk(actuallyVg []types.VgGroup) (*types.DiskPlan, error) {
+	ctx := context.Background()
+	ldList := &carinav1.LocalDiskList{}
+	if err := dm.client.List(ctx, ldList); err != nil {
+		return nil, err
+	}
+	objects := map[string]*carinav1.LocalDisk{}
+	for i := range ldList.Items {
+		if ldList.Items[i].Spec.NodeName == dm.nodeName {
+			objects[ldList.Items[i].Name] = &ldList.Items[i]
+		}
+	}
+
+	localDisk, err := dm.DiskManager.ListDevicesDetail("")
+	if err != nil {
+		return nil, fmt.Errorf("get local disk failed: %s", err.Error())
+	}
+
+	// 配置文件仅决定新磁盘的默认归属
+	var diskSelector *regexp.Regexp
+	if dsList := configuration.DiskSelector(); len(dsList) > 0 {
+		diskSelector, err = regexp.Compile(strings.Join(dsList, "|"))
+		if err != nil {
+			log.Warnf("disk regex %s error %v ", strings.Join(dsList, "|"), err)
+			diskSelector = nil
+		}
+	}
+	filter := dm.newDiskFilter(diskSelector, configuration.DiskGroups(), localDisk)
+	dm.setMultipathPaths(filter.mpathPaths)
+
+	pvList, err := dm.VolumeManager.GetCurrentPvStruct()
+	if err != nil {
+		return nil, fmt.Errorf("get pv failed %s", err.Error())
+	}
+	pvVg := map[string]string{}
+	pvUUID := map[string]string{}
+	for _, pv := range pvList {
+		// 重新配置pv容量大小
+		if strings.HasPrefix(pv.VGName, types.KEYWORD) {
+			if err := dm.LvmManager.PVResize(pv.PVName); err != nil {
+				log.Errorf("resize %s error", pv.PVName)
+			}
+		}
+		pvVg[pv.PVName] = pv.VGName
+		pvUUID[pv.PVName] = pv.PVUUID
+	}
+	byID := device.DiskByID()
+
+	health := map[string]string{}
+	for _, h := range dm.DiskHealthList() {
+		health[h.Device] = h.Status
+	}
+
+	plan := &types.DiskPlan{
+		Add:        []types.DiskChange{},
+		Remove:     []types.DiskChange{},
+		DryRun:     configuration.DiskScanDryRun(),
+		CreateTime: time.Now(),
+	}
+	dm.resetRejectedDisk()
+	seen := map[string]bool{}
+	for _, d := range localDisk {
+		if d.Type != types.DiskType && d.Type != types.PartType && d.Type != types.MultiPath {
+			continue
+		}
+		// 路径设备属于multipath设备，不单独创建LocalDisk对象
+		if _, ok := filter.mpathPaths[d.Name]; ok {
+			continue
+		}
+		vg, isPv := pvVg[d.Name]
+		defaultVg, reason, skip := dm.checkDisk(filter, d)
+		if skip {
+			continue
+		}
+
+		name := localDiskName(dm.nodeName, d, byID, pvUUID)
+		if name == "" {
+			// 设备名称重启后可能变化，没有稳定标识的设备不纳入LocalDisk管理，已加入vg卷组的保持不变
+			if !strings.HasPrefix(vg, types.KEYWORD) {
+				dm.rejectDisk(d.Name, "no stable device id")
+			}
+			continue
+		}
+		seen[name] = true
+		ld, ok := objects[name]
+		if !ok {
+			ld = &carinav1.LocalDisk{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec:       carinav1.LocalDiskSpec{NodeName: dm.nodeName},
+			}
+			if strings.HasPrefix(vg, types.KEYWORD) {
+				ld.Spec.DeviceGroup = vg
+			} else if reason == "" {
+				ld.Spec.DeviceGroup = defaultVg
+			}
+			if err := dm.client.Create(ctx, ld); err != nil {
+				log.Errorf("create localdisk %s failed %s", name, err.Error())
+				continue
+			}
+			log.Infof("create localdisk %s device %s group %s", name, d.Name, ld.Spec.DeviceGroup)
+		}
+
+		status := carinav1.LocalDiskStatus{
+			DevicePath: d.Name,
+			Size:       d.Size,
+			Type:       d.Type,
+			Rotational: d.Rotational,
+			Model:      d.Model,
+			Serial:     d.Serial,
+			Transport:  d.Transport,
+			WWID:       d.WWID,
+			PathCount:  len(d.Paths),
+		}
+		if h, ok := health[d.Name]; ok {
+			status.Health = h
+		} else if h, ok := health[d.ParentName]; ok {
+			status.Health = h
+		}
+		if strings.HasPrefix(vg, types.KEYWORD) {
+			status.DeviceGroup = vg
+		}
+
+		desired := localDiskGroup(ld.Spec.DeviceGroup)
+		array, isMember := dm.raidMember(d.Name)
+		switch {
+		case isMember:
+			// raid阵列成员由阵列整体加入vg卷组，不单独移除
+			status.DeviceGroup = array.VGName
+			status.Phase = carinav1.LocalDiskInUse
+			status.Message = "member of raid " + array.Name
+		case strings.HasPrefix(vg, types.KEYWORD) && vg == desired:
+			status.Phase = carinav1.LocalDiskInUse
+			if dm.decommissioning(d.Name) {
+				status.Phase = carinav1.LocalDiskRemoving
+				status.Message = "decommissioning"
+			}
+		case strings.HasPrefix(vg, types.KEYWORD):
+			status.Phase = carinav1.LocalDiskRemoving
+			if !dm.decommissioning(d.Name) {
+				plan.Remove = append(plan.Remove, types.DiskChange{Device: d.Name, VGName: vg, Reason: "released by localdisk " + name})
+			}
+		case desired == "":
+			status.Phase = carinav1.LocalDiskUnclaimed
+			status.Message = reason
+			if reason != "" {
+				dm.rejectDisk(d.Name, reason)
+			}
+		case vg != "":
+			status.Phase = carinav1.LocalDiskFailed
+			status.Message = fmt.Sprintf("pv of other vg %s", vg)
+		default:
+			// 只有pv没有vg的设备，是之前加入vg卷组失败留下的
+			usable := ""
+			if isPv {
+				usable, _ = device.ProtectedReason(filter.protected, d.Name)
+			} else {
+				usable, _ = dm.checkDiskUsable(filter, d)
+			}
+			if usable != "" {
+				status.Phase = carinav1.LocalDiskFailed
+				status.Message = usable
+				dm.rejectDisk(d.Name, usable)
+				break
+			}
+			status.Phase = carinav1.LocalDiskClaimed
+			plan.Add = append(plan.Add, types.DiskChange{Device: d.Name, VGName: desired, Reason: "claimed by localdisk " + name})
+		}
+		if status.Health == types.DiskFailing && status.Phase != carinav1.LocalDiskRemoving {
+			status.Phase = carinav1.LocalDiskFailed
+			status.Message = "disk health failing"
+		}
+		dm.updateLocalDiskStatus(ctx, ld, status)
+	}
+
+	// 磁盘已经从节点上消失
+	for name, ld := range objects {
+		if seen[name] {
+			continue
+		}
+		status := ld.Status
+		status.Phase = carinav1.LocalDiskFailed
+		status.Message = "device not found"
+		dm.updateLocalDiskStatus(ctx, ld, status)
+	}
+
+	sortDiskChange(plan.Add)
+	sortDiskChange(plan.Remove)
+	plan.ID = diskPlanID(plan.Remove)
+	if len(plan.Remove) > 0 {
+		plan.Approved = dm.diskRemovalApproved(plan.ID)
+	}
+	return plan, nil
+}
+
+func (dm *DeviceManager) updateLocalDiskStatus(ctx context.Context, ld *carinav1.LocalDisk, status carinav1.LocalDiskStatus) {
+	if ld.Status == status {
+		return
+	}
+	if ld.Status.Phase != status.Phase {
+		log.Infof("localdisk %s phase %s -> %s %s", ld.Name, ld.Status.Phase, status.Phase, status.Message)
+	}
+	ld.Status = status
+	if err := dm.client.Status().Update(ctx, ld); err != nil {
+		log.Errorf("update localdisk %s status failed %s", ld.Name, err.Error())
+	}
+}
+
+// LocalDisk对象变更后触发磁盘扫描
+func (dm *DeviceManager) TriggerDiskScan() {
+	select {
+	case dm.localDiskChan <- struct{}{}:
+	default:
+	}
+}
+
+// 只使用稳定标识命名，设备名称变化后仍能对应到同一个对象
+// 依次为multipath设备的WWID、整盘的序列号、/dev/disk/by-id下的名称以及pv uuid，都没有时返回空
+func localDiskName(nodeName string, d *types.LocalDisk, byID, pvUUID map[string]string) string {
+	var id string
+	switch {
+	case d.WWID != "":
+		id = d.WWID
+	case d.Type == types.DiskType && d.Serial != "":
+		id = d.Serial
+	case byID[d.Name] != "":
+		id = byID[d.Name]
+	case pvUUID[d.Name] != "":
+		id = "pv-" + pvUUID[d.Name]
+	default:
+		return ""
+	}
+	name := localDiskNameRegex.ReplaceAllString(strings.ToLower(nodeName+"-"+id), "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return name
+}
+
+// 支持hdd以及carina-vg-hdd两种写法
+func localDiskGroup(group string) string {
+	if group == "" || strings.HasPrefix(group, types.DeviceGroupPrefix) {
+		return group
+	}
+	return types.DeviceGroupPrefix + group
+}
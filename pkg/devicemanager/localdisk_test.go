@@ -0,0 +1,119 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"context"
+	carinav1 "github.com/carina-io/carina/api/v1"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestLocalDiskName(t *testing.T) {
+	byID := map[string]string{
+		"/dev/sdb1": "wwn-0x5000c500a1b2c3d4-part1",
+		"/dev/vdc":  "virtio-data01",
+	}
+	pvUUID := map[string]string{"/dev/vdd1": "Xq3Mzc-0PqR-dYzS"}
+	table := []struct {
+		disk   types.LocalDisk
+		expect string
+	}{
+		{types.LocalDisk{Name: "/dev/sdb", Type: types.DiskType, Serial: "ZC1A2B3C"}, "node-1-zc1a2b3c"},
+		{types.LocalDisk{Name: "/dev/mapper/mpatha", Type: types.MultiPath, WWID: "3600c0ff0001e"}, "node-1-3600c0ff0001e"},
+		// 分区没有序列号，使用by-id名称
+		{types.LocalDisk{Name: "/dev/sdb1", Type: types.PartType, Serial: "ZC1A2B3C"}, "node-1-wwn-0x5000c500a1b2c3d4-part1"},
+		// virtio磁盘没有序列号
+		{types.LocalDisk{Name: "/dev/vdc", Type: types.DiskType}, "node-1-virtio-data01"},
+		{types.LocalDisk{Name: "/dev/vdd1", Type: types.PartType}, "node-1-pv-xq3mzc-0pqr-dyzs"},
+		// 没有稳定标识时不能使用设备名称
+		{types.LocalDisk{Name: "/dev/vde", Type: types.DiskType}, ""},
+		{types.LocalDisk{Name: "/dev/vde1", Type: types.PartType}, ""},
+	}
+	for _, e := range table {
+		if name := localDiskName("node-1", &e.disk, byID, pvUUID); name != e.expect {
+			t.Errorf("localDiskName(%s) got %q, want %q", e.disk.Name, name, e.expect)
+		}
+	}
+}
+
+func TestPlanLocalDiskRemoval(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = carinav1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		// 管理员将磁盘移出设备组
+		&carinav1.LocalDisk{ObjectMeta: metav1.ObjectMeta{Name: "node-1-pv-abc-123"}, Spec: carinav1.LocalDiskSpec{NodeName: "node-1"}},
+		// 之前以设备名称命名的对象，重启后设备名称可能已对应其他磁盘
+		&carinav1.LocalDisk{ObjectMeta: metav1.ObjectMeta{Name: "node-1-fakevdc"}, Spec: carinav1.LocalDiskSpec{NodeName: "node-1", DeviceGroup: "carina-vg-ssd"}},
+	).Build()
+
+	pvs := []types.PVInfo{
+		{PVName: "/dev/fakevdb", VGName: "carina-vg-hdd", PVUUID: "abc-123"},
+		{PVName: "/dev/fakevdc", VGName: "carina-vg-hdd"},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	dm := &DeviceManager{
+		nodeName:      "node-1",
+		client:        c,
+		cache:         newFakeCache(node),
+		LvmManager:    &fakeLvm{pvs: pvs},
+		VolumeManager: &fakeVolume{pvs: pvs},
+		DiskManager: &fakeDevice{disks: []*types.LocalDisk{
+			{Name: "/dev/fakevdb", Type: types.DiskType, Size: 100 << 30, Filesystem: "LVM2_member"},
+			{Name: "/dev/fakevdc", Type: types.DiskType, Size: 100 << 30, Filesystem: "LVM2_member"},
+		}},
+	}
+
+	plan, err := dm.planLocalDisk(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Remove) != 1 || plan.Remove[0].Device != "/dev/fakevdb" {
+		t.Fatalf("expect only /dev/fakevdb to be removed, got %+v", plan.Remove)
+	}
+	if plan.Approved {
+		t.Fatal("localdisk removal approved without annotation")
+	}
+	// 没有稳定标识的pv不创建对象，以设备名称命名的旧对象不再对应任何磁盘
+	ldList := &carinav1.LocalDiskList{}
+	if err := c.List(context.Background(), ldList); err != nil || len(ldList.Items) != 2 {
+		t.Errorf("expect no new localdisk, got %d %v", len(ldList.Items), err)
+	}
+	ld := &carinav1.LocalDisk{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "node-1-fakevdc"}, ld); err != nil || ld.Status.Phase != carinav1.LocalDiskFailed {
+		t.Errorf("legacy localdisk %+v %v", ld.Status, err)
+	}
+
+	dm.cache = newFakeCache(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node-1",
+		Annotations: map[string]string{utils.DiskRemovalApproveKey: plan.ID},
+	}})
+	plan, err = dm.planLocalDisk(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Approved {
+		t.Error("localdisk removal not approved with annotation")
+	}
+}
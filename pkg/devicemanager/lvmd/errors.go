@@ -0,0 +1,119 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package lvmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/carina-io/carina/utils/exec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// lvm命令失败的类型，通过errors.Is判断
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrNoSpace    = errors.New("insufficient space")
+	ErrLocked     = errors.New("locked")
+	ErrDeviceBusy = errors.New("device busy")
+	ErrMetadata   = errors.New("metadata error")
+)
+
+// 按照lvm标准错误输出判断失败类型，顺序即优先级
+var errorPatterns = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrNoSpace, []string{"insufficient free space", "insufficient suitable allocatable extents", "insufficient free extents", "not enough free space", "no space left on device"}},
+	{ErrLocked, []string{"can't get lock", "failed to get lock", "unable to obtain global lock", "resource temporarily unavailable", "global mutex"}},
+	{ErrDeviceBusy, []string{"device or resource busy", "filesystem in use", "is in use by", "in use by another", "can't remove open logical volume", "is open"}},
+	// 未找到的对象的提示中可能带有metadata字样，需要优先判断
+	{ErrNotFound, []string{"failed to find", "not found", "no such file or directory", "does not exist"}},
+	{ErrMetadata, []string{"metadata area", "inconsistent metadata", "checksum", "parse error", "failed to write vg", "failed to read vg"}},
+}
+
+// LvmError 带有失败类型的lvm错误
+type LvmError struct {
+	Kind error
+	Err  error
+}
+
+func (e *LvmError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LvmError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LvmError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// NewError 创建指定类型的错误
+func NewError(kind error, format string, args ...interface{}) error {
+	return &LvmError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// 根据命令的标准错误输出对错误分类，无法识别时原样返回
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var lvmErr *LvmError
+	if errors.As(err, &lvmErr) {
+		return err
+	}
+	message := err.Error()
+	var execErr *exec.ExecError
+	if errors.As(err, &execErr) {
+		message = execErr.Stderr
+	}
+	message = strings.ToLower(message)
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(message, pattern) {
+				return &LvmError{Kind: p.kind, Err: err}
+			}
+		}
+	}
+	return err
+}
+
+// GRPCCode 将错误类型映射为gRPC状态码
+func GRPCCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, ErrNoSpace):
+		return codes.ResourceExhausted
+	case errors.Is(err, ErrLocked):
+		// 稍后重试即可
+		return codes.Aborted
+	case errors.Is(err, ErrDeviceBusy):
+		return codes.FailedPrecondition
+	case errors.Is(err, ErrMetadata):
+		return codes.Internal
+	}
+	return codes.Internal
+}
@@ -0,0 +1,72 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package lvmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/carina-io/carina/utils/exec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	table := []struct {
+		stderr string
+		kind   error
+		code   codes.Code
+	}{
+		{"Volume group \"carina-vg-hdd\" has insufficient free space (255 extents): 512 required.", ErrNoSpace, codes.ResourceExhausted},
+		{"Insufficient suitable allocatable extents for logical volume volume-1", ErrNoSpace, codes.ResourceExhausted},
+		{"Global lock failed: check that global lockspace is started. Can't get lock for carina-vg-hdd", ErrLocked, codes.Aborted},
+		{"Logical volume carina-vg-hdd/volume-1 contains a filesystem in use.", ErrDeviceBusy, codes.FailedPrecondition},
+		{"Can't remove open logical volume \"volume-1\".", ErrDeviceBusy, codes.FailedPrecondition},
+		{"WARNING: Inconsistent metadata found for VG carina-vg-hdd", ErrMetadata, codes.Internal},
+		{"Failed to find logical volume \"carina-vg-hdd/volume-1\"", ErrNotFound, codes.NotFound},
+		{"Failed to find physical volume \"/dev/sdb\" in use by metadata", ErrNotFound, codes.NotFound},
+		{"Device /dev/sdb is in use by another process", ErrDeviceBusy, codes.FailedPrecondition},
+		{"Checksum error at offset 4608 on /dev/sdb", ErrMetadata, codes.Internal},
+		{"Failed to write metadata area header on /dev/sdb", ErrMetadata, codes.Internal},
+		{"Volume group \"carina-vg-ssd\" not found", ErrNotFound, codes.NotFound},
+		{"unknown failure", nil, codes.Internal},
+	}
+	for _, e := range table {
+		err := classifyError(&exec.ExecError{Command: "lvcreate", ExitCode: 5, Stderr: e.stderr, Err: errors.New("exit status 5")})
+		if e.kind != nil && !errors.Is(err, e.kind) {
+			t.Errorf("classifyError %q got %v, want %v", e.stderr, err, e.kind)
+		}
+		var execErr *exec.ExecError
+		if !errors.As(err, &execErr) {
+			t.Errorf("classifyError %q lost exec error", e.stderr)
+		}
+		if code := GRPCCode(fmt.Errorf("create volume failed: %w", err)); code != e.code {
+			t.Errorf("GRPCCode %q got %s, want %s", e.stderr, code, e.code)
+		}
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	if code := GRPCCode(nil); code != codes.OK {
+		t.Errorf("GRPCCode nil got %s", code)
+	}
+	if code := GRPCCode(status.Error(codes.Unavailable, "retry")); code != codes.Unavailable {
+		t.Errorf("GRPCCode status error got %s", code)
+	}
+	if code := GRPCCode(NewError(ErrNotFound, "lv %s not found", "volume-1")); code != codes.NotFound {
+		t.Errorf("GRPCCode not found got %s", code)
+	}
+}
@@ -28,6 +28,15 @@ type Lvm2 interface {
 	// 扫盲pv加入cache,在服务启动时执行
 	PVScan(dev string) error
 	PVDisplay(dev string) (*types.PVInfo, error)
+	// 设置pv是否允许分配新的空间
+	PVChangeAllocatable(dev string, allocatable bool) error
+	// 后台迁移pv上已分配的空间到vg卷组中的其他pv，dev为空时恢复中断的迁移
+	// targets不为空时只迁移到这些pv上
+	PVMove(dev string, targets ...string) error
+	// 查询vg卷组中正在进行的pv迁移及进度
+	PVMoveProgress(vg string) (float64, bool, error)
+	// vg卷组中各pv上已分配空间所属的lv
+	PVSegments(vg string) (map[string][]string, error)
 
 	VGCheck(vg string) error
 	VGCreate(vg string, tags, pvs []string) error
@@ -37,14 +46,14 @@ type Lvm2 interface {
 	VGScan(vg string) error
 	// vg卷组增加新的pv
 	VGExtend(vg, pv string) error
-	// vg卷组安全移除pv
+	// vg卷组移除pv，pv上不能有已分配的空间
 	VGReduce(vg, pv string) error
 
 	// 每一个Volume对应的是一个thin pool下一个lvm卷
 	// 若是要扩容卷，则必须先扩容池子
 	// 快照占用的是池子剩余的容量
-	CreateThinPool(lv, vg string, size uint64) error
-	ResizeThinPool(lv, vg string, size uint64) error
+	CreateThinPool(lv, vg string, size uint64, pvs ...string) error
+	ResizeThinPool(lv, vg string, size uint64, pvs ...string) error
 	DeleteThinPool(lv, vg string) error
 	LVCreateFromPool(lv, thin, vg string, size uint64) error
 	// 这个方法不用
@@ -23,11 +23,46 @@ import (
 	"github.com/carina-io/carina/utils/exec"
 	"github.com/carina-io/carina/utils/log"
 	"strings"
+	"sync"
 	"time"
 )
 
+// json格式报告查询的字段
+const (
+	pvsReportFields = "pv_name,vg_name,pv_fmt,pv_attr,pv_size,pv_free,pv_uuid,pv_pe_count,pv_pe_alloc_count,pv_tags"
+	vgsReportFields = "vg_name,pv_name,pv_count,lv_count,snap_count,vg_attr,vg_size,vg_free,vg_uuid,vg_extent_size,vg_extent_count,vg_free_count,vg_missing_pv_count,vg_tags"
+	lvsReportFields = "lv_name,vg_name,lv_path,lv_size,data_percent,metadata_percent,lv_attr,lv_kernel_major,lv_kernel_minor,origin,origin_size,pool_lv,thin_count,lv_tags,lv_active,lv_uuid,lv_health_status,segtype,devices"
+)
+
 type Lvm2Implement struct {
 	Executor exec.Executor
+	// 首次查询时检测lvm版本，老版本不支持json格式报告时使用--nameprefixes
+	versionOnce sync.Once
+	jsonReport  bool
+}
+
+// lvm是否支持--reportformat json
+func (lv2 *Lvm2Implement) useJSONReport() bool {
+	lv2.versionOnce.Do(func() {
+		output, err := lv2.Executor.ExecuteCommandWithOutput("lvm", "version")
+		if err != nil {
+			log.Warnf("get lvm version failed %s, use legacy report", err.Error())
+			return
+		}
+		version, supported := parseLvmVersion(output)
+		lv2.jsonReport = supported
+		log.Infof("lvm version %s, json report %t", version, supported)
+	})
+	return lv2.jsonReport
+}
+
+func reportArgs(fields string) []string {
+	return []string{"--reportformat", "json", "--units=b", "--nosuffix", "--unbuffered", "-o", fields}
+}
+
+// 执行lvm命令，失败时根据错误输出对错误分类
+func (lv2 *Lvm2Implement) run(command string, arg ...string) error {
+	return classifyError(lv2.Executor.ExecuteCommand(command, arg...))
 }
 
 func (lv2 *Lvm2Implement) PVCheck(dev string) (string, error) {
@@ -35,31 +70,49 @@ func (lv2 *Lvm2Implement) PVCheck(dev string) (string, error) {
 }
 
 func (lv2 *Lvm2Implement) PVCreate(dev string) error {
-	return lv2.Executor.ExecuteCommand("pvcreate", dev)
+	return lv2.run("pvcreate", dev)
 }
 
 func (lv2 *Lvm2Implement) PVRemove(dev string) error {
-	return lv2.Executor.ExecuteCommand("pvremove", dev)
+	return lv2.run("pvremove", dev)
 }
 
 func (lv2 *Lvm2Implement) PVResize(dev string) error {
-	return lv2.Executor.ExecuteCommand("pvresize", dev)
+	return lv2.run("pvresize", dev)
 }
 
 // 示例输出
 // pvs --noheadings --separator=, --units=b --nosuffix --unbuffered --nameprefixes
 // LVM2_PV_NAME='/dev/loop2',LVM2_VG_NAME='lvmvg',LVM2_PV_FMT='lvm2',LVM2_PV_ATTR='a--',LVM2_PV_SIZE='16101933056',LVM2_PV_FREE='16101933056'
 func (lv2 *Lvm2Implement) PVS() ([]types.PVInfo, error) {
+	if lv2.useJSONReport() {
+		output, err := lv2.Executor.ExecuteCommandWithOutput("pvs", reportArgs(pvsReportFields)...)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		return parsePvsReport(output)
+	}
 
 	args := []string{"--noheadings", "--separator=,", "--units=b", "--nosuffix", "--unbuffered", "--nameprefixes"}
 
 	pvsInfo, err := lv2.Executor.ExecuteCommandWithOutput("pvs", args...)
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 	return parsePvs(pvsInfo), nil
 }
 
+// pvs --segments --noheadings --separator=, --unbuffered --nameprefixes -o pv_name,vg_name,lv_name
+// vg卷组中各pv上已分配空间所属的lv，key为pv名称，thin pool的数据卷为隐藏卷如thin-xx_tdata
+func (lv2 *Lvm2Implement) PVSegments(vg string) (map[string][]string, error) {
+	args := []string{"--segments", "--noheadings", "--separator=,", "--unbuffered", "--nameprefixes", "-o", "pv_name,vg_name,lv_name"}
+	output, err := lv2.Executor.ExecuteCommandWithOutput("pvs", args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return parsePvSegments(output, vg), nil
+}
+
 /*
 # pvdisplay /dev/loop4
   --- Physical volume ---
@@ -83,7 +136,52 @@ func (lv2 *Lvm2Implement) PVDisplay(dev string) (*types.PVInfo, error) {
 			return &pv, nil
 		}
 	}
-	return nil, errors.New("disk not found")
+	return nil, NewError(ErrNotFound, "pv %s not found", dev)
+}
+
+// pvchange -x n /dev/loop4
+func (lv2 *Lvm2Implement) PVChangeAllocatable(dev string, allocatable bool) error {
+	flag := "n"
+	if allocatable {
+		flag = "y"
+	}
+	return lv2.run("pvchange", "-x", flag, dev)
+}
+
+// pvmove -b /dev/loop4 /dev/loop5
+// 迁移在后台进行，中断后执行不带参数的pvmove即可恢复
+func (lv2 *Lvm2Implement) PVMove(dev string, targets ...string) error {
+	args := []string{"-b"}
+	if dev != "" {
+		args = append(args, dev)
+		args = append(args, targets...)
+	}
+	output, err := lv2.Executor.ExecuteCommandWithCombinedOutput("pvmove", args...)
+	if err != nil && !strings.Contains(output, "No data to move") {
+		log.Error(output)
+		return classifyError(err)
+	}
+	return nil
+}
+
+// 示例输出
+// lvs -a --noheadings --separator=, --nosuffix --unbuffered --nameprefixes -o lv_name,lv_attr,copy_percent v1
+// LVM2_LV_NAME='[pvmove0]',LVM2_LV_ATTR='p-C-aom---',LVM2_COPY_PERCENT='12.50'
+func (lv2 *Lvm2Implement) PVMoveProgress(vg string) (float64, bool, error) {
+	if lv2.useJSONReport() {
+		output, err := lv2.Executor.ExecuteCommandWithOutput("lvs", append([]string{"-a"}, append(reportArgs("lv_name,lv_attr,copy_percent"), vg)...)...)
+		if err != nil {
+			return 0, false, classifyError(err)
+		}
+		return parsePvMoveReport(output)
+	}
+	args := []string{"-a", "--noheadings", "--separator=,", "--nosuffix", "--unbuffered", "--nameprefixes", "-o", "lv_name,lv_attr,copy_percent", vg}
+	output, err := lv2.Executor.ExecuteCommandWithOutput("lvs", args...)
+	if err != nil {
+		return 0, false, classifyError(err)
+	}
+	progress, moving := parsePvMove(output)
+	return progress, moving, nil
 }
 
 // PVScan runs the `pvscan --cache <dev>` command. It scans for the
@@ -94,11 +192,11 @@ func (lv2 *Lvm2Implement) PVScan(dev string) error {
 	if dev != "" {
 		args = append(args, dev)
 	}
-	return lv2.Executor.ExecuteCommand("pvscan", args...)
+	return lv2.run("pvscan", args...)
 }
 
 func (lv2 *Lvm2Implement) VGCheck(vg string) error {
-	return lv2.Executor.ExecuteCommand("vgck", vg)
+	return lv2.run("vgck", vg)
 }
 
 // vgcreate --add-tag=v1 v1 /dev/loop4
@@ -113,7 +211,7 @@ func (lv2 *Lvm2Implement) VGCreate(vg string, tags, pvs []string) error {
 	for _, pv := range pvs {
 		args = append(args, pv)
 	}
-	err := lv2.Executor.ExecuteCommand("vgcreate", args...)
+	err := lv2.run("vgcreate", args...)
 	if err != nil {
 		return err
 	}
@@ -122,7 +220,7 @@ func (lv2 *Lvm2Implement) VGCreate(vg string, tags, pvs []string) error {
 }
 
 func (lv2 *Lvm2Implement) VGRemove(vg string) error {
-	return lv2.Executor.ExecuteCommand("vgremove", "-f", vg)
+	return lv2.run("vgremove", "-f", vg)
 }
 
 // 示例
@@ -130,12 +228,19 @@ func (lv2 *Lvm2Implement) VGRemove(vg string) error {
 // LVM2_VG_NAME='lvmvg',LVM2_PV_COUNT='1',LVM2_LV_COUNT='0',LVM2_SNAP_COUNT='0',LVM2_VG_ATTR='wz--n-',LVM2_VG_SIZE='16101933056',LVM2_VG_FREE='16101933056'
 // LVM2_VG_NAME='v1',LVM2_PV_COUNT='2',LVM2_LV_COUNT='0',LVM2_SNAP_COUNT='0',LVM2_VG_ATTR='wz--n-',LVM2_VG_SIZE='32203866112',LVM2_VG_FREE='32203866112'
 func (lv2 *Lvm2Implement) VGS() ([]types.VgGroup, error) {
+	if lv2.useJSONReport() {
+		output, err := lv2.Executor.ExecuteCommandWithOutput("vgs", reportArgs(vgsReportFields)...)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		return parseVgsReport(output)
+	}
 	flieds := []string{"-o", "VG_NAME,PV_NAME,PV_COUNT,LV_COUNT,SNAP_COUNT,VG_ATTR,VG_SIZE,VG_FREE"}
 	args := []string{"--noheadings", "--separator=,", "--units=b", "--nosuffix", "--unbuffered", "--nameprefixes"}
 
 	vgsInfo, err := lv2.Executor.ExecuteCommandWithOutput("vgs", append(flieds, args...)...)
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	return parseVgs(vgsInfo), nil
@@ -152,7 +257,7 @@ func (lv2 *Lvm2Implement) VGDisplay(vg string) (*types.VgGroup, error) {
 		}
 	}
 
-	return nil, errors.New("vg not found")
+	return nil, NewError(ErrNotFound, "vg %s not found", vg)
 }
 
 // VGScan runs the `vgscan --cache <name>` command. It scans for the
@@ -163,12 +268,12 @@ func (lv2 *Lvm2Implement) VGScan(vg string) error {
 	if vg != "" {
 		args = append(args, vg)
 	}
-	return lv2.Executor.ExecuteCommand("vgscan", args...)
+	return lv2.run("vgscan", args...)
 }
 
 func (lv2 *Lvm2Implement) VGExtend(vg, pv string) error {
 
-	err := lv2.Executor.ExecuteCommand("vgextend", vg, pv)
+	err := lv2.run("vgextend", vg, pv)
 	if err != nil {
 		return err
 	}
@@ -197,20 +302,11 @@ func (lv2 *Lvm2Implement) VGExtend(vg, pv string) error {
   /dev/loop4 v1    lvm2 a--  15.00g 15.00g
 */
 func (lv2 *Lvm2Implement) VGReduce(vg, pv string) error {
-
-	output, err := lv2.Executor.ExecuteCommandWithOutput("pvmove", pv)
-	if err != nil && !strings.Contains(output, "No data to move") {
-		log.Error(output)
-		return err
-	}
-
-	log.Info("wait 1s to exec vgreduce ")
-	time.Sleep(1 * time.Second)
-	if err := lv2.Executor.ExecuteCommand("vgreduce", vg, pv); err != nil {
+	if err := lv2.run("vgreduce", vg, pv); err != nil {
 		return err
 	}
 
-	err = lv2.PVRemove(pv)
+	err := lv2.PVRemove(pv)
 	if err != nil {
 		return err
 	}
@@ -218,14 +314,18 @@ func (lv2 *Lvm2Implement) VGReduce(vg, pv string) error {
 	return nil
 }
 
-// lvcreate -T v1/t5 --size 2g
-func (lv2 *Lvm2Implement) CreateThinPool(lv, vg string, size uint64) error {
-	return lv2.Executor.ExecuteCommand("lvcreate", "-T", fmt.Sprintf("%s/%s", vg, lv), "--size", fmt.Sprintf("%vg", size>>30))
+// lvcreate -T v1/t5 --size 2g /dev/loop4
+// 指定pv时只在这些pv上分配空间
+func (lv2 *Lvm2Implement) CreateThinPool(lv, vg string, size uint64, pvs ...string) error {
+	args := []string{"-T", fmt.Sprintf("%s/%s", vg, lv), "--size", fmt.Sprintf("%vg", size>>30)}
+	return lv2.run("lvcreate", append(args, pvs...)...)
 }
 
-// lvresize -f -L 6g v1/t5
-func (lv2 *Lvm2Implement) ResizeThinPool(lv, vg string, size uint64) error {
-	return lv2.Executor.ExecuteCommand("lvresize", "-f", "-L", fmt.Sprintf("%vg", size>>30), fmt.Sprintf("%s/%s", vg, lv))
+// lvresize -f -L 6g v1/t5 /dev/loop4
+// 指定pv时只在这些pv上分配扩容的空间
+func (lv2 *Lvm2Implement) ResizeThinPool(lv, vg string, size uint64, pvs ...string) error {
+	args := []string{"-f", "-L", fmt.Sprintf("%vg", size>>30), fmt.Sprintf("%s/%s", vg, lv)}
+	return lv2.run("lvresize", append(args, pvs...)...)
 }
 
 // lvremove v1/t3
@@ -236,7 +336,7 @@ func (lv2 *Lvm2Implement) DeleteThinPool(lv, vg string) error {
 
 func (lv2 *Lvm2Implement) LVCreateFromPool(lv, thin, vg string, size uint64) error {
 
-	return lv2.Executor.ExecuteCommand("lvcreate", "-T", fmt.Sprintf("%s/%s", vg, thin), "-n", lv, "-V", fmt.Sprintf("%vg", size>>30))
+	return lv2.run("lvcreate", "-T", fmt.Sprintf("%s/%s", vg, thin), "-n", lv, "-V", fmt.Sprintf("%vg", size>>30))
 }
 
 // LVCreate creates logical volume in this volume group.
@@ -258,16 +358,16 @@ func (lv2 *Lvm2Implement) LVCreateFromVG(lv, vg string, size uint64, tags []stri
 	}
 	args = append(args, vg)
 
-	return lv2.Executor.ExecuteCommand("lvcreate", args...)
+	return lv2.run("lvcreate", args...)
 }
 
 func (lv2 *Lvm2Implement) LVRemove(lv, vg string) error {
-	return lv2.Executor.ExecuteCommand("lvremove", "-f", fmt.Sprintf("%s/%s", vg, lv))
+	return lv2.run("lvremove", "-f", fmt.Sprintf("%s/%s", vg, lv))
 }
 
 // lvresize -L 2g v1/m2
 func (lv2 *Lvm2Implement) LVResize(lv, vg string, size uint64) error {
-	return lv2.Executor.ExecuteCommand("lvresize", "-L", fmt.Sprintf("%vg", size>>30), fmt.Sprintf("%s/%s", vg, lv))
+	return lv2.run("lvresize", "-L", fmt.Sprintf("%vg", size>>30), fmt.Sprintf("%s/%s", vg, lv))
 }
 
 // lvdisplay v1/m2
@@ -277,7 +377,7 @@ func (lv2 *Lvm2Implement) LVDisplay(lv, vg string) (*types.LvInfo, error) {
 		return nil, err
 	}
 	if len(lvInfo) < 1 {
-		return nil, errors.New("not found")
+		return nil, NewError(ErrNotFound, "lv %s/%s not found", vg, lv)
 	}
 	return &lvInfo[0], nil
 	//return lv2.Executor.ExecuteCommandWithOutput("lvdisplay", fmt.Sprintf("%s/%s", vg, lv))
@@ -291,6 +391,17 @@ func (lv2 *Lvm2Implement) LVDisplay(lv, vg string) (*types.LvInfo, error) {
 
 */
 func (lv2 *Lvm2Implement) LVS(lvName string) ([]types.LvInfo, error) {
+	if lv2.useJSONReport() {
+		args := reportArgs(lvsReportFields)
+		if lvName != "" {
+			args = append(args, lvName)
+		}
+		output, err := lv2.Executor.ExecuteCommandWithOutput("lvs", args...)
+		if err != nil {
+			return lvsNotFound(output, err)
+		}
+		return parseLvsReport(output)
+	}
 	fields := []string{"-o", "lv_name,vg_name,lv_path,lv_size,data_percent,lv_attr,lv_kernel_major,lv_kernel_minor,origin,origin_size,pool_lv,thin_count,lv_tags,lv_active"}
 	args := []string{"--noheadings", "--separator=,", "--units=b", "--nosuffix", "--unbuffered", "--nameprefixes"}
 
@@ -299,20 +410,27 @@ func (lv2 *Lvm2Implement) LVS(lvName string) ([]types.LvInfo, error) {
 	}
 
 	lvsInfo, err := lv2.Executor.ExecuteCommandWithOutput("lvs", append(fields, args...)...)
-	if err != nil && strings.Contains(lvsInfo, "Failed to find logical volume") {
-		return []types.LvInfo{}, nil
-	}
 	if err != nil {
-		return nil, errors.New(lvsInfo)
+		return lvsNotFound(lvsInfo, err)
 	}
 	return parseLvs(lvsInfo), nil
 }
 
+// 查询的lv不存在时返回空列表
+func lvsNotFound(output string, err error) ([]types.LvInfo, error) {
+	var execErr *exec.ExecError
+	if strings.Contains(output, "Failed to find logical volume") ||
+		(errors.As(err, &execErr) && strings.Contains(execErr.Stderr, "Failed to find logical volume")) {
+		return []types.LvInfo{}, nil
+	}
+	return nil, classifyError(err)
+}
+
 // lvcreate -s v1/m2 -n snaph-m1 -ay -Ky
 func (lv2 *Lvm2Implement) CreateSnapshot(snap, lv, vg string) error {
 	// Pool容量时lv卷的三倍，则能创建两个快照
 	// TODO: 需要检查pool>lvm卷,若是相等则不支持创建快照操作
-	return lv2.Executor.ExecuteCommand("lvcreate", "-s", fmt.Sprintf("%s/%s", vg, lv), "-n", snap, "-ay", "-Ky")
+	return lv2.run("lvcreate", "-s", fmt.Sprintf("%s/%s", vg, lv), "-n", snap, "-ay", "-Ky")
 }
 
 //
@@ -331,7 +449,7 @@ func (lv2 *Lvm2Implement) DeleteSnapshot(snap, vg string) error {
 func (lv2 *Lvm2Implement) RestoreSnapshot(snap, vg string) error {
 	// 恢复快照后，此快照将消失
 	// TODO: 恢复快照前要umount
-	return lv2.Executor.ExecuteCommand("lvconvert", "--merge", fmt.Sprintf("%s/%s", vg, snap))
+	return lv2.run("lvconvert", "--merge", fmt.Sprintf("%s/%s", vg, snap))
 }
 
 func (lv2 *Lvm2Implement) StartLvm2() error {
@@ -349,5 +467,5 @@ func (lv2 *Lvm2Implement) StartLvm2() error {
 }
 
 func (lv2 *Lvm2Implement) RemoveUnknownDevice(vg string) error {
-	return lv2.Executor.ExecuteCommand("vgreduce", "--removemissing", vg)
+	return lv2.run("vgreduce", "--removemissing", vg)
 }
@@ -17,6 +17,7 @@ package lvmd
 
 import (
 	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils"
 	"github.com/carina-io/carina/utils/log"
 	"strconv"
 	"strings"
@@ -170,3 +171,65 @@ func parsePvs(pvsString string) []types.PVInfo {
 	}
 	return resp
 }
+
+// 解析pvmove临时卷的迁移进度，pvmove卷的属性以p开头
+func parsePvMove(lvsString string) (float64, bool) {
+	lvsString = strings.ReplaceAll(lvsString, "'", "")
+	lvsString = strings.ReplaceAll(lvsString, " ", "")
+	for _, lvs := range strings.Split(lvsString, "\n") {
+		attr := ""
+		percent := float64(0)
+		for _, v := range strings.Split(lvs, ",") {
+			k := strings.SplitN(v, "=", 2)
+			if len(k) != 2 {
+				continue
+			}
+			switch k[0] {
+			case "LVM2_LV_ATTR":
+				attr = k[1]
+			case "LVM2_COPY_PERCENT":
+				percent, _ = strconv.ParseFloat(k[1], 64)
+			}
+		}
+		if strings.HasPrefix(attr, "p") {
+			return percent, true
+		}
+	}
+	return 0, false
+}
+
+// 解析pv上各段所属的lv，隐藏lv去掉方括号，空闲段不记录
+// LVM2_PV_NAME='/dev/loop2',LVM2_VG_NAME='v1',LVM2_LV_NAME='[t5_tdata]'
+// LVM2_PV_NAME='/dev/loop2',LVM2_VG_NAME='v1',LVM2_LV_NAME=''
+func parsePvSegments(segString, vg string) map[string][]string {
+	resp := map[string][]string{}
+	segString = strings.ReplaceAll(segString, "'", "")
+	segString = strings.ReplaceAll(segString, " ", "")
+	for _, seg := range strings.Split(segString, "\n") {
+		pv, vgName, lv := "", "", ""
+		for _, v := range strings.Split(seg, ",") {
+			k := strings.SplitN(v, "=", 2)
+			if len(k) != 2 {
+				continue
+			}
+			switch k[0] {
+			case "LVM2_PV_NAME":
+				pv = k[1]
+			case "LVM2_VG_NAME":
+				vgName = k[1]
+			case "LVM2_LV_NAME":
+				lv = strings.Trim(k[1], "[]")
+			}
+		}
+		if pv == "" || vgName != vg {
+			continue
+		}
+		if _, ok := resp[pv]; !ok {
+			resp[pv] = []string{}
+		}
+		if lv != "" && !utils.ContainsString(resp[pv], lv) {
+			resp[pv] = append(resp[pv], lv)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,37 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package lvmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePvSegments(t *testing.T) {
+	output := `  LVM2_PV_NAME='/dev/loop2',LVM2_VG_NAME='carina-vg-hdd',LVM2_LV_NAME='[thin-pvc-1_tdata]'
+  LVM2_PV_NAME='/dev/loop2',LVM2_VG_NAME='carina-vg-hdd',LVM2_LV_NAME='[thin-pvc-1_tmeta]'
+  LVM2_PV_NAME='/dev/loop2',LVM2_VG_NAME='carina-vg-hdd',LVM2_LV_NAME='[thin-pvc-1_tdata]'
+  LVM2_PV_NAME='/dev/loop3',LVM2_VG_NAME='carina-vg-hdd',LVM2_LV_NAME=''
+  LVM2_PV_NAME='/dev/loop4',LVM2_VG_NAME='carina-vg-ssd',LVM2_LV_NAME='[thin-pvc-2_tdata]'
+`
+	expect := map[string][]string{
+		"/dev/loop2": {"thin-pvc-1_tdata", "thin-pvc-1_tmeta"},
+		"/dev/loop3": {},
+	}
+	if result := parsePvSegments(output, "carina-vg-hdd"); !reflect.DeepEqual(result, expect) {
+		t.Errorf("parsePvSegments got %v, want %v", result, expect)
+	}
+}
@@ -0,0 +1,310 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package lvmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lvm2从2.02.158开始支持--reportformat json
+var jsonReportVersion = [3]int{2, 2, 158}
+
+var lvmVersionRegex = regexp.MustCompile(`LVM version:\s*(\d+)\.(\d+)\.(\d+)`)
+
+// 解析lvm version输出，返回版本号以及是否支持json格式的报告
+//   LVM version:     2.02.187(2)-RHEL7 (2020-03-24)
+//   Library version: 1.02.170-RHEL7 (2020-03-24)
+//   Driver version:  4.37.1
+func parseLvmVersion(output string) (string, bool) {
+	m := lvmVersionRegex.FindStringSubmatch(output)
+	if m == nil {
+		return "", false
+	}
+	version := [3]int{}
+	for i := range version {
+		version[i], _ = strconv.Atoi(m[i+1])
+	}
+	supported := true
+	for i := range version {
+		if version[i] != jsonReportVersion[i] {
+			supported = version[i] > jsonReportVersion[i]
+			break
+		}
+	}
+	return fmt.Sprintf("%d.%02d.%d", version[0], version[1], version[2]), supported
+}
+
+// json报告中数值均为字符串，空字符串表示该字段不适用
+type lvmUint uint64
+
+func (u *lvmUint) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" {
+		*u = 0
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse lvm number %s failed %v", s, err)
+	}
+	*u = lvmUint(v)
+	return nil
+}
+
+type lvmFloat float64
+
+func (f *lvmFloat) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("parse lvm number %s failed %v", s, err)
+	}
+	*f = lvmFloat(v)
+	return nil
+}
+
+type pvReport struct {
+	PVName       string  `json:"pv_name"`
+	VGName       string  `json:"vg_name"`
+	PVFmt        string  `json:"pv_fmt"`
+	PVAttr       string  `json:"pv_attr"`
+	PVSize       lvmUint `json:"pv_size"`
+	PVFree       lvmUint `json:"pv_free"`
+	PVUUID       string  `json:"pv_uuid"`
+	PECount      lvmUint `json:"pv_pe_count"`
+	PEAllocCount lvmUint `json:"pv_pe_alloc_count"`
+	PVTags       string  `json:"pv_tags"`
+}
+
+type vgReport struct {
+	VGName         string  `json:"vg_name"`
+	PVName         string  `json:"pv_name"`
+	PVCount        lvmUint `json:"pv_count"`
+	LVCount        lvmUint `json:"lv_count"`
+	SnapCount      lvmUint `json:"snap_count"`
+	VGAttr         string  `json:"vg_attr"`
+	VGSize         lvmUint `json:"vg_size"`
+	VGFree         lvmUint `json:"vg_free"`
+	VGUUID         string  `json:"vg_uuid"`
+	ExtentSize     lvmUint `json:"vg_extent_size"`
+	ExtentCount    lvmUint `json:"vg_extent_count"`
+	FreeCount      lvmUint `json:"vg_free_count"`
+	MissingPVCount lvmUint `json:"vg_missing_pv_count"`
+	VGTags         string  `json:"vg_tags"`
+}
+
+type lvReport struct {
+	LVName          string   `json:"lv_name"`
+	VGName          string   `json:"vg_name"`
+	LVPath          string   `json:"lv_path"`
+	LVSize          lvmUint  `json:"lv_size"`
+	DataPercent     lvmFloat `json:"data_percent"`
+	MetadataPercent lvmFloat `json:"metadata_percent"`
+	CopyPercent     lvmFloat `json:"copy_percent"`
+	LVAttr          string   `json:"lv_attr"`
+	LVKernelMajor   string   `json:"lv_kernel_major"`
+	LVKernelMinor   string   `json:"lv_kernel_minor"`
+	Origin          string   `json:"origin"`
+	OriginSize      lvmUint  `json:"origin_size"`
+	PoolLV          string   `json:"pool_lv"`
+	ThinCount       lvmUint  `json:"thin_count"`
+	LVTags          string   `json:"lv_tags"`
+	LVActive        string   `json:"lv_active"`
+	LVUUID          string   `json:"lv_uuid"`
+	HealthStatus    string   `json:"lv_health_status"`
+	SegType         string   `json:"segtype"`
+	Devices         string   `json:"devices"`
+}
+
+// {"report": [{"pv": [...]}]}，vgs及lvs分别为vg及lv
+type lvmReport struct {
+	Report []struct {
+		PV []pvReport `json:"pv"`
+		VG []vgReport `json:"vg"`
+		LV []lvReport `json:"lv"`
+	} `json:"report"`
+}
+
+func unmarshalReport(output string) (*lvmReport, error) {
+	report := &lvmReport{}
+	if strings.TrimSpace(output) == "" {
+		return report, nil
+	}
+	if err := json.Unmarshal([]byte(output), report); err != nil {
+		return nil, fmt.Errorf("parse lvm json report failed %v", err)
+	}
+	return report, nil
+}
+
+func parsePvsReport(output string) ([]types.PVInfo, error) {
+	report, err := unmarshalReport(output)
+	if err != nil {
+		return nil, err
+	}
+	resp := []types.PVInfo{}
+	for _, r := range report.Report {
+		for _, pv := range r.PV {
+			resp = append(resp, types.PVInfo{
+				PVName:       pv.PVName,
+				VGName:       pv.VGName,
+				PVFmt:        pv.PVFmt,
+				PVAttr:       pv.PVAttr,
+				PVSize:       uint64(pv.PVSize),
+				PVFree:       uint64(pv.PVFree),
+				PVUUID:       pv.PVUUID,
+				PECount:      uint64(pv.PECount),
+				PEAllocCount: uint64(pv.PEAllocCount),
+				PVTags:       pv.PVTags,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// vgs报告中包含pv_name时每个pv一行，按vg合并
+func parseVgsReport(output string) ([]types.VgGroup, error) {
+	report, err := unmarshalReport(output)
+	if err != nil {
+		return nil, err
+	}
+	resp := []types.VgGroup{}
+	seen := map[string]bool{}
+	for _, r := range report.Report {
+		for _, vg := range r.VG {
+			if seen[vg.VGName] {
+				continue
+			}
+			seen[vg.VGName] = true
+			resp = append(resp, types.VgGroup{
+				VGName:         vg.VGName,
+				PVName:         vg.PVName,
+				PVCount:        uint64(vg.PVCount),
+				LVCount:        uint64(vg.LVCount),
+				SnapCount:      uint64(vg.SnapCount),
+				VGAttr:         vg.VGAttr,
+				VGSize:         uint64(vg.VGSize),
+				VGFree:         uint64(vg.VGFree),
+				VGUUID:         vg.VGUUID,
+				ExtentSize:     uint64(vg.ExtentSize),
+				ExtentCount:    uint64(vg.ExtentCount),
+				FreeCount:      uint64(vg.FreeCount),
+				MissingPVCount: uint64(vg.MissingPVCount),
+				VGTags:         vg.VGTags,
+				PVS:            []*types.PVInfo{},
+			})
+		}
+	}
+	return resp, nil
+}
+
+// lvs报告中包含段信息时每个段一行，按lv合并段类型及所在设备
+func parseLvsReport(output string) ([]types.LvInfo, error) {
+	report, err := unmarshalReport(output)
+	if err != nil {
+		return nil, err
+	}
+	resp := []types.LvInfo{}
+	index := map[string]int{}
+	for _, r := range report.Report {
+		for _, lv := range r.LV {
+			if !strings.HasPrefix(lv.LVName, "volume") && !strings.HasPrefix(lv.LVName, "thin") && !strings.HasPrefix(lv.LVName, "snap") {
+				continue
+			}
+			key := lv.VGName + "/" + lv.LVName
+			if i, ok := index[key]; ok {
+				resp[i].Devices = appendLvDevices(resp[i].Devices, lv.Devices)
+				if lv.SegType != "" && !strings.Contains(resp[i].SegType, lv.SegType) {
+					resp[i].SegType += "," + lv.SegType
+				}
+				continue
+			}
+			major, _ := strconv.ParseUint(lv.LVKernelMajor, 10, 32)
+			minor, _ := strconv.ParseUint(lv.LVKernelMinor, 10, 32)
+			index[key] = len(resp)
+			resp = append(resp, types.LvInfo{
+				LVName:          lv.LVName,
+				VGName:          lv.VGName,
+				LVPath:          lv.LVPath,
+				LVSize:          uint64(lv.LVSize),
+				LVKernelMajor:   uint32(major),
+				LVKernelMinor:   uint32(minor),
+				Origin:          lv.Origin,
+				OriginSize:      uint64(lv.OriginSize),
+				PoolLV:          lv.PoolLV,
+				ThinCount:       uint64(lv.ThinCount),
+				LVTags:          lv.LVTags,
+				DataPercent:     float64(lv.DataPercent),
+				MetadataPercent: float64(lv.MetadataPercent),
+				LVAttr:          lv.LVAttr,
+				LVActive:        lv.LVActive,
+				LVUUID:          lv.LVUUID,
+				HealthStatus:    lv.HealthStatus,
+				SegType:         lv.SegType,
+				Devices:         appendLvDevices(nil, lv.Devices),
+			})
+		}
+	}
+	return resp, nil
+}
+
+// devices字段形如/dev/loop2(0),/dev/loop3(0)，括号内为起始extent
+func appendLvDevices(devices []string, value string) []string {
+	for _, d := range strings.Split(value, ",") {
+		if i := strings.Index(d, "("); i >= 0 {
+			d = d[:i]
+		}
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		exist := false
+		for _, e := range devices {
+			if e == d {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// pvmove临时卷的迁移进度，pvmove卷的属性以p开头
+func parsePvMoveReport(output string) (float64, bool, error) {
+	report, err := unmarshalReport(output)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, r := range report.Report {
+		for _, lv := range r.LV {
+			if strings.HasPrefix(lv.LVAttr, "p") {
+				return float64(lv.CopyPercent), true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
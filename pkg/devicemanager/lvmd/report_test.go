@@ -0,0 +1,132 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package lvmd
+
+import (
+	"testing"
+)
+
+func TestParseLvmVersion(t *testing.T) {
+	table := []struct {
+		output    string
+		version   string
+		supported bool
+	}{
+		{"  LVM version:     2.02.187(2)-RHEL7 (2020-03-24)\n  Library version: 1.02.170-RHEL7 (2020-03-24)", "2.02.187", true},
+		{"  LVM version:     2.03.11(2) (2021-01-08)", "2.03.11", true},
+		{"  LVM version:     2.02.158(2) (2016-06-25)", "2.02.158", true},
+		{"  LVM version:     2.02.98(2) (2012-10-15)", "2.02.98", false},
+		{"lvm: command not found", "", false},
+	}
+	for _, e := range table {
+		version, supported := parseLvmVersion(e.output)
+		if version != e.version || supported != e.supported {
+			t.Errorf("parseLvmVersion %q got %s %t, want %s %t", e.output, version, supported, e.version, e.supported)
+		}
+	}
+}
+
+func TestParsePvsReport(t *testing.T) {
+	output := `  {
+      "report": [
+          {
+              "pv": [
+                  {"pv_name":"/dev/loop2", "vg_name":"carina-vg-hdd", "pv_fmt":"lvm2", "pv_attr":"a--", "pv_size":"16101933056", "pv_free":"15028191232", "pv_uuid":"OiNoxD-Y1sw-FSzi-mqPN-07EW-C77P-TNdtc6", "pv_pe_count":"3839", "pv_pe_alloc_count":"256", "pv_tags":""},
+                  {"pv_name":"/dev/mapper/mpath a", "vg_name":"", "pv_fmt":"lvm2", "pv_attr":"---", "pv_size":"16106127360", "pv_free":"16106127360", "pv_uuid":"", "pv_pe_count":"0", "pv_pe_alloc_count":"0", "pv_tags":"a,b"}
+              ]
+          }
+      ]
+  }`
+	pvs, err := parsePvsReport(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pvs) != 2 {
+		t.Fatalf("parsePvsReport got %d pvs, want 2", len(pvs))
+	}
+	if pvs[0].PVName != "/dev/loop2" || pvs[0].PVFree != 15028191232 || pvs[0].PECount != 3839 || pvs[0].PEAllocCount != 256 {
+		t.Errorf("parsePvsReport got %+v", pvs[0])
+	}
+	if pvs[1].PVName != "/dev/mapper/mpath a" || pvs[1].VGName != "" || pvs[1].PVTags != "a,b" {
+		t.Errorf("parsePvsReport got %+v", pvs[1])
+	}
+
+	if _, err := parsePvsReport("  WARNING: Not using lvmetad"); err == nil {
+		t.Error("parsePvsReport should fail on invalid json")
+	}
+}
+
+func TestParseVgsReport(t *testing.T) {
+	output := `{"report": [{"vg": [
+      {"vg_name":"carina-vg-hdd", "pv_name":"/dev/loop2", "pv_count":"2", "lv_count":"3", "snap_count":"0", "vg_attr":"wz--n-", "vg_size":"32203866112", "vg_free":"30056382464", "vg_uuid":"x1", "vg_extent_size":"4194304", "vg_extent_count":"7678", "vg_free_count":"7166", "vg_missing_pv_count":"0", "vg_tags":""},
+      {"vg_name":"carina-vg-hdd", "pv_name":"/dev/loop3", "pv_count":"2", "lv_count":"3", "snap_count":"0", "vg_attr":"wz--n-", "vg_size":"32203866112", "vg_free":"30056382464", "vg_uuid":"x1", "vg_extent_size":"4194304", "vg_extent_count":"7678", "vg_free_count":"7166", "vg_missing_pv_count":"0", "vg_tags":""},
+      {"vg_name":"carina-vg-ssd", "pv_name":"[unknown]", "pv_count":"1", "lv_count":"0", "snap_count":"0", "vg_attr":"wz-pn-", "vg_size":"16101933056", "vg_free":"16101933056", "vg_uuid":"x2", "vg_extent_size":"4194304", "vg_extent_count":"3839", "vg_free_count":"3839", "vg_missing_pv_count":"1", "vg_tags":"carina"}
+  ]}]}`
+	vgs, err := parseVgsReport(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vgs) != 2 {
+		t.Fatalf("parseVgsReport got %d vgs, want 2", len(vgs))
+	}
+	if vgs[0].VGName != "carina-vg-hdd" || vgs[0].PVCount != 2 || vgs[0].VGFree != 30056382464 || vgs[0].ExtentSize != 4194304 || vgs[0].FreeCount != 7166 {
+		t.Errorf("parseVgsReport got %+v", vgs[0])
+	}
+	if vgs[1].MissingPVCount != 1 || vgs[1].VGTags != "carina" || vgs[1].PVS == nil {
+		t.Errorf("parseVgsReport got %+v", vgs[1])
+	}
+}
+
+func TestParseLvsReport(t *testing.T) {
+	output := `{"report": [{"lv": [
+      {"lv_name":"thin-pvc-1", "vg_name":"carina-vg-hdd", "lv_path":"", "lv_size":"2147483648", "data_percent":"12.50", "metadata_percent":"10.94", "lv_attr":"twi-aotz--", "lv_kernel_major":"253", "lv_kernel_minor":"2", "origin":"", "origin_size":"", "pool_lv":"", "thin_count":"1", "lv_tags":"", "lv_active":"active", "lv_uuid":"u1", "lv_health_status":"", "segtype":"thin-pool", "devices":"thin-pvc-1_tdata(0)"},
+      {"lv_name":"volume-pvc-1", "vg_name":"carina-vg-hdd", "lv_path":"/dev/carina-vg-hdd/volume-pvc-1", "lv_size":"2147483648", "data_percent":"12.50", "metadata_percent":"", "lv_attr":"Vwi-aotz--", "lv_kernel_major":"253", "lv_kernel_minor":"4", "origin":"", "origin_size":"", "pool_lv":"thin-pvc-1", "thin_count":"", "lv_tags":"pvc,ns", "lv_active":"active", "lv_uuid":"u2", "lv_health_status":"", "segtype":"thin", "devices":""},
+      {"lv_name":"volume-pvc-2", "vg_name":"carina-vg-hdd", "lv_path":"/dev/carina-vg-hdd/volume-pvc-2", "lv_size":"4294967296", "data_percent":"", "metadata_percent":"", "lv_attr":"-wi-a-----", "lv_kernel_major":"253", "lv_kernel_minor":"5", "origin":"", "origin_size":"", "pool_lv":"", "thin_count":"", "lv_tags":"", "lv_active":"active", "lv_uuid":"u3", "lv_health_status":"partial", "segtype":"linear", "devices":"/dev/loop2(512)"},
+      {"lv_name":"volume-pvc-2", "vg_name":"carina-vg-hdd", "lv_path":"/dev/carina-vg-hdd/volume-pvc-2", "lv_size":"4294967296", "data_percent":"", "metadata_percent":"", "lv_attr":"-wi-a-----", "lv_kernel_major":"253", "lv_kernel_minor":"5", "origin":"", "origin_size":"", "pool_lv":"", "thin_count":"", "lv_tags":"", "lv_active":"active", "lv_uuid":"u3", "lv_health_status":"partial", "segtype":"striped", "devices":"/dev/loop3(0),/dev/loop4(0)"},
+      {"lv_name":"lvol0_pmspare", "vg_name":"carina-vg-hdd", "lv_path":"", "lv_size":"8388608", "data_percent":"", "metadata_percent":"", "lv_attr":"ewi-------", "lv_kernel_major":"-1", "lv_kernel_minor":"-1", "origin":"", "origin_size":"", "pool_lv":"", "thin_count":"", "lv_tags":"", "lv_active":"", "lv_uuid":"u4", "lv_health_status":"", "segtype":"linear", "devices":"/dev/loop2(0)"}
+  ]}]}`
+	lvs, err := parseLvsReport(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lvs) != 3 {
+		t.Fatalf("parseLvsReport got %d lvs, want 3", len(lvs))
+	}
+	if lvs[0].DataPercent != 12.5 || lvs[0].MetadataPercent != 10.94 || lvs[0].ThinCount != 1 || lvs[0].SegType != "thin-pool" {
+		t.Errorf("parseLvsReport got %+v", lvs[0])
+	}
+	if lvs[1].PoolLV != "thin-pvc-1" || lvs[1].LVTags != "pvc,ns" || lvs[1].LVKernelMinor != 4 || len(lvs[1].Devices) != 0 {
+		t.Errorf("parseLvsReport got %+v", lvs[1])
+	}
+	if lvs[2].SegType != "linear,striped" || lvs[2].HealthStatus != "partial" || len(lvs[2].Devices) != 3 || lvs[2].Devices[2] != "/dev/loop4" {
+		t.Errorf("parseLvsReport got %+v", lvs[2])
+	}
+}
+
+func TestParsePvMoveReport(t *testing.T) {
+	output := `{"report": [{"lv": [
+      {"lv_name":"[pvmove0]", "lv_attr":"p-C-aom---", "copy_percent":"12.50"},
+      {"lv_name":"volume-pvc-1", "lv_attr":"-wI-ao----", "copy_percent":""}
+  ]}]}`
+	progress, moving, err := parsePvMoveReport(output)
+	if err != nil || !moving || progress != 12.5 {
+		t.Errorf("parsePvMoveReport got %f %t %v", progress, moving, err)
+	}
+	_, moving, err = parsePvMoveReport(`{"report": [{"lv": []}]}`)
+	if err != nil || moving {
+		t.Errorf("parsePvMoveReport got %t %v", moving, err)
+	}
+}
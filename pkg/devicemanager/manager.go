@@ -19,17 +19,24 @@ import (
 	"github.com/carina-io/carina/pkg/configuration"
 	"github.com/carina-io/carina/pkg/devicemanager/bcache"
 	"github.com/carina-io/carina/pkg/devicemanager/device"
+	"github.com/carina-io/carina/pkg/devicemanager/iostat"
 	"github.com/carina-io/carina/pkg/devicemanager/lvmd"
+	"github.com/carina-io/carina/pkg/devicemanager/raid"
+	"github.com/carina-io/carina/pkg/devicemanager/smart"
 	"github.com/carina-io/carina/pkg/devicemanager/troubleshoot"
 	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/pkg/devicemanager/udev"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
-	"github.com/carina-io/carina/utils"
 	"github.com/carina-io/carina/utils/exec"
 	"github.com/carina-io/carina/utils/log"
 	"github.com/carina-io/carina/utils/mutx"
+	"k8s.io/client-go/tools/record"
 	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -54,9 +61,51 @@ type DeviceManager struct {
 	trouble *troubleshoot.Trouble
 	// 配置变更即触发搜索本地磁盘逻辑
 	configModifyChan chan struct{}
+	cache            cache.Cache
+	// 不经过缓存的客户端，用于读写LocalDisk对象，为空时只根据配置文件管理磁盘
+	client client.Client
+	// LocalDisk对象变更即触发磁盘扫描
+	localDiskChan chan struct{}
+	// 磁盘热插拔事件，key为设备路径，value为事件类型
+	deviceEventChan chan map[string]string
+	// 磁盘SMART健康检查
+	Smart      smart.Smart
+	recorder   record.EventRecorder
+	healthLock sync.RWMutex
+	diskHealth map[string]*types.DiskHealth
+	// 最近一次磁盘变更计划
+	planLock sync.RWMutex
+	diskPlan *types.DiskPlan
+	// 不满足条件的磁盘及原因
+	rejectLock    sync.RWMutex
+	rejectedDisks map[string]types.DiskRejection
+	// 磁盘下线任务
+	decommissionLock sync.Mutex
+	decommission     map[string]*types.Decommission
+	decommissionChan chan struct{}
+	// md raid阵列
+	Raid       raid.Raid
+	raidLock   sync.RWMutex
+	raidArrays map[string]*types.RaidArray
+	// 最近一次全量扫描得到的multipath路径设备，路径故障不视为磁盘移除
+	mpathLock  sync.RWMutex
+	mpathPaths map[string]string
+	// 设备组IO负载，key为vg卷组名称，ioSample为上一次/proc/diskstats采样
+	ioLock       sync.RWMutex
+	ioSample     map[string]iostat.DiskStat
+	ioSampleTime time.Time
+	ioStats      map[string]iostat.Usage
+	// IO负载变化明显时通知更新NodeStorage
+	ioStatsChan chan struct{}
 }
 
-func NewDeviceManager(nodeName string, cache cache.Cache, stopChan <-chan struct{}) *DeviceManager {
+// 合并磁盘热插拔事件的时间窗口
+const deviceEventDebounce = 10 * time.Second
+
+// 磁盘容量至少增加一个默认PE大小时才需要pvresize
+const pvResizeThreshold = 4 << 20
+
+func NewDeviceManager(nodeName string, cache cache.Cache, c client.Client, recorder record.EventRecorder, stopChan <-chan struct{}) *DeviceManager {
 	executor := &exec.CommandExecutor{}
 	mutex := mutx.NewGlobalLocks()
 	dm := DeviceManager{
@@ -70,27 +119,36 @@ func NewDeviceManager(nodeName string, cache cache.Cache, stopChan <-chan struct
 			Bcache:          &bcache.BcacheImplement{Executor: executor},
 			NoticeServerMap: make(map[string]chan struct{}),
 		},
-		Bcache:   &bcache.BcacheImplement{Executor: executor},
-		stopChan: stopChan,
-		nodeName: nodeName,
+		Bcache:     &bcache.BcacheImplement{Executor: executor},
+		Smart:      &smart.SmartImplement{Executor: executor},
+		Raid:       &raid.RaidImplement{Executor: executor},
+		stopChan:   stopChan,
+		nodeName:   nodeName,
+		cache:      cache,
+		client:     c,
+		recorder:   recorder,
+		diskHealth: make(map[string]*types.DiskHealth),
 	}
+	dm.rejectedDisks = make(map[string]types.DiskRejection)
+	dm.decommission = make(map[string]*types.Decommission)
+	dm.decommissionChan = make(chan struct{}, 1)
+	dm.raidArrays = make(map[string]*types.RaidArray)
+	dm.mpathPaths = make(map[string]string)
+	dm.ioStats = make(map[string]iostat.Usage)
+	dm.ioStatsChan = make(chan struct{}, 1)
 	dm.trouble = troubleshoot.NewTroubleObject(dm.VolumeManager, cache, nodeName)
 	// 注册监听配置变更
 	dm.configModifyChan = make(chan struct{}, 1)
 	configuration.RegisterListenerChan(dm.configModifyChan)
+	dm.deviceEventChan = make(chan map[string]string)
+	dm.localDiskChan = make(chan struct{}, 1)
 
 	return &dm
 }
 
 // 定时巡检磁盘，是否有新磁盘加入
+// 每次扫描生成磁盘变更计划，新增磁盘直接执行，移除磁盘需要审批后才会执行
 func (dm *DeviceManager) AddAndRemoveDevice() {
-
-	currentDiskSelector := configuration.DiskSelector()
-	if len(currentDiskSelector) == 0 {
-		log.Info("disk selector cannot be empty, skip device scan")
-		return
-	}
-
 	ActuallyVg, err := dm.VolumeManager.GetCurrentVgStruct()
 	if err != nil {
 		log.Error("get current vg struct failed: " + err.Error())
@@ -98,51 +156,26 @@ func (dm *DeviceManager) AddAndRemoveDevice() {
 	}
 	changeBefore := ActuallyVg
 
-	newDisk, err := dm.DiscoverDisk()
+	plan, err := dm.planDiskChange(ActuallyVg)
 	if err != nil {
-		log.Error("find new device failed: " + err.Error())
+		log.Error("plan disk change failed: " + err.Error())
 		return
 	}
-	newPv, err := dm.DiscoverPv()
-	if err != nil {
-		log.Error("find new pv failed: " + err.Error())
+	if plan == nil {
 		return
 	}
-
-	// 合并新增设备
-	for key, value := range newDisk {
-		if v, ok := newPv[key]; ok {
-			newDisk[key] = utils.SliceMergeSlice(value, v)
-		}
-	}
-	for key, value := range newPv {
-		if _, ok := newDisk[key]; !ok {
-			newDisk[key] = value
-		}
-	}
-
-	// 需要新增的磁盘, 处理成容易比较的数据
-	needAddPv := newDisk
-	ActuallyVgMap := map[string][]string{}
-	for _, v := range ActuallyVg {
-		for _, pv := range v.PVS {
-			ActuallyVgMap[v.VGName] = append(ActuallyVgMap[v.VGName], pv.PVName)
-		}
-	}
-	for vgName, pvs := range newDisk {
-		if actuallyPv, ok := ActuallyVgMap[vgName]; ok {
-			needAddPv[vgName] = utils.SliceSubSlice(pvs, actuallyPv)
-		}
+	dm.publishDiskPlan(plan)
+	if plan.DryRun {
+		log.Infof("disk scan dry run, plan %s add %d disks remove %d disks", plan.ID, len(plan.Add), len(plan.Remove))
+		return
 	}
 
 	// 执行新增磁盘
-	for vg, pvs := range needAddPv {
-		for _, pv := range pvs {
-			if err := dm.VolumeManager.AddNewDiskToVg(pv, vg); err != nil {
-				log.Errorf("add new disk failed vg: %s, disk: %s, error: %v", vg, pv, err)
-			}
-		}
+	newDisk := map[string][]string{}
+	for _, c := range plan.Add {
+		newDisk[c.VGName] = append(newDisk[c.VGName], c.Device)
 	}
+	dm.addDisksToVg(newDisk)
 	time.Sleep(5 * time.Second)
 	// 移出磁盘
 	// 无法判断单独的PV属于carina管理范围，所以不支持单独对pv remove
@@ -153,24 +186,31 @@ func (dm *DeviceManager) AddAndRemoveDevice() {
 		return
 	}
 
-	diskSelector, err := regexp.Compile(strings.Join(currentDiskSelector, "|"))
-	if err != nil {
-		log.Warnf("disk regex %s error %v ", strings.Join(configuration.DiskSelector(), "|"), err)
-		return
-	}
-
 	log.Info("local logic volume auto tuning")
 	for _, v := range ActuallyVg {
 		for _, pv := range v.PVS {
 			if strings.Contains(pv.PVName, "unknown") {
 				_ = dm.LvmManager.RemoveUnknownDevice(pv.VGName)
-				continue
 			}
-			if !diskSelector.MatchString(pv.PVName) {
-				log.Infof("remove pv %s in vg %s", pv.PVName, v.VGName)
-				if err := dm.VolumeManager.RemoveDiskInVg(pv.PVName, v.VGName); err != nil {
-					log.Errorf("remove pv %s error %v", pv.PVName, err)
+		}
+	}
+
+	if len(plan.Remove) > 0 && !plan.Approved {
+		log.Warnf("disk plan %s remove %d disks, waiting for approval", plan.ID, len(plan.Remove))
+	}
+	if len(plan.Remove) > 0 && plan.Approved {
+		for _, c := range plan.Remove {
+			// pv上仍有数据则通过下线任务迁移数据后移除
+			pv, err := dm.LvmManager.PVDisplay(c.Device)
+			if err == nil && pv != nil && pv.PVSize > pv.PVFree {
+				if _, err := dm.Decommission(c.Device); err != nil {
+					log.Errorf("decommission pv %s error %v", c.Device, err)
 				}
+				continue
+			}
+			log.Infof("remove pv %s in vg %s", c.Device, c.VGName)
+			if err := dm.VolumeManager.RemoveDiskInVg(c.Device, c.VGName); err != nil {
+				log.Errorf("remove pv %s error %v", c.Device, err)
 			}
 		}
 	}
@@ -187,6 +227,11 @@ func (dm *DeviceManager) AddAndRemoveDevice() {
 
 // 查找是否有符合条件的块设备加入
 func (dm *DeviceManager) DiscoverDisk() (map[string][]string, error) {
+	return dm.discoverDisk("")
+}
+
+// dev为空时扫描所有块设备，否则只扫描该设备及其分区
+func (dm *DeviceManager) discoverDisk(dev string) (map[string][]string, error) {
 	blockClass := map[string][]string{}
 
 	dsList := configuration.DiskSelector()
@@ -201,8 +246,14 @@ func (dm *DeviceManager) DiscoverDisk() (map[string][]string, error) {
 		return blockClass, err
 	}
 
+	diskGroups := configuration.DiskGroups()
+	if len(diskGroups) == 0 {
+		log.Info("disk groups cannot not be empty, skip device scan")
+		return blockClass, nil
+	}
+
 	// 列出所有本地磁盘
-	localDisk, err := dm.DiskManager.ListDevicesDetail("")
+	localDisk, err := dm.DiskManager.ListDevicesDetail(dev)
 	if err != nil {
 		log.Error("get local disk failed: " + err.Error())
 		return blockClass, err
@@ -212,70 +263,40 @@ func (dm *DeviceManager) DiscoverDisk() (map[string][]string, error) {
 		return blockClass, nil
 	}
 
-	parentDisk := map[string]int8{}
-	for _, d := range localDisk {
-		parentDisk[d.ParentName] = 1
+	if dev == "" {
+		dm.resetRejectedDisk()
+	}
+
+	filter := dm.newDiskFilter(diskSelector, diskGroups, localDisk)
+	if dev == "" {
+		dm.setMultipathPaths(filter.mpathPaths)
 	}
 	// 过滤出空块设备
 	for _, d := range localDisk {
-		if strings.Contains(d.Name, types.KEYWORD) {
+		vgName, reason, skip := dm.checkDisk(filter, d)
+		if skip {
 			continue
 		}
-		// 如果是其他磁盘Parent直接跳过
-		if _, ok := parentDisk[d.Name]; ok {
-			continue
-		}
-
-		if d.Readonly || d.Size < 10<<30 || d.Filesystem != "" || d.MountPoint != "" {
-			log.Infof("mismatched disk: %s filesystem:%s mountpoint:%s readonly:%t, size:%d", d.Name, d.Filesystem, d.MountPoint, d.Readonly, d.Size)
-			continue
-		}
-
-		if strings.Contains(d.Name, "cache") {
-			continue
-		}
-
-		// 过滤不支持的磁盘类型
-		diskTypeCheck := true
-		for _, t := range []string{types.LVMType, types.CryptType, types.MultiPath, "rom"} {
-			if strings.Contains(d.Type, t) {
-				diskTypeCheck = false
-				break
-			}
-		}
-		if !diskTypeCheck {
-			log.Infof("mismatched disk:%s, disktype:%s", d.Name, d.Type)
-			continue
-		}
-
-		if !diskSelector.MatchString(d.Name) {
-			log.Infof("mismatched disk:%s, regex:%s", d.Name, diskSelector.String())
+		if reason != "" {
+			dm.rejectDisk(d.Name, reason)
 			continue
 		}
+		dm.acceptDisk(d.Name)
+		blockClass[vgName] = append(blockClass[vgName], d.Name)
+		log.Infof("eligible %s device %s", vgName, d.Name)
+	}
 
-		// 判断设备是否已经存在数据
-		dused, err := dm.DiskManager.GetDiskUsed(d.Name)
-		if err != nil {
-			log.Warnf("get disk %s used failed %v", d.Name, err)
-			continue
-		}
-		if dused > 0 {
-			log.Warnf("block device don't empty " + d.Name)
-			continue
-		}
+	return blockClass, nil
+}
 
-		if d.Rotational == "0" {
-			blockClass[utils.DeviceVGSSD] = append(blockClass[utils.DeviceVGSSD], d.Name)
-			log.Infof("eligible ssd device %s", d.Name)
-		} else if d.Rotational == "1" {
-			blockClass[utils.DeviceVGHDD] = append(blockClass[utils.DeviceVGHDD], d.Name)
-			log.Infof("eligible hdd device %s", d.Name)
-		} else {
-			log.Infof("unsupported disk type name: %s, rota: %s", d.Name, d.Rotational)
+// 按照配置顺序查找磁盘所属分组，返回分组对应的vg卷组名称
+func matchDiskGroup(diskGroups []types.DiskGroup, d *types.LocalDisk) string {
+	for i := range diskGroups {
+		if diskGroups[i].Match(d) {
+			return diskGroups[i].VGName()
 		}
 	}
-
-	return blockClass, nil
+	return ""
 }
 
 // 支持发现Pv，由于某些异常情况，只创建成功了PV,并未创建成功VG
@@ -291,6 +312,8 @@ func (dm *DeviceManager) DiscoverPv() (map[string][]string, error) {
 		log.Warnf("disk regex %s error %v ", strings.Join(dsList, "|"), err)
 		return resp, err
 	}
+	diskGroups := configuration.DiskGroups()
+	protected := dm.DiskManager.ProtectedDevices()
 	pvList, err := dm.VolumeManager.GetCurrentPvStruct()
 	if err != nil {
 		log.Errorf("get pv failed %s", err.Error())
@@ -320,15 +343,17 @@ func (dm *DeviceManager) DiscoverPv() (map[string][]string, error) {
 			log.Error("get disk count not equal 1")
 			continue
 		}
-		if disk[0].Rotational == "0" {
-			resp[utils.DeviceVGSSD] = append(resp[utils.DeviceVGSSD], disk[0].Name)
-			log.Infof("eligible ssd pv %s", disk[0].Name)
-		} else if disk[0].Rotational == "1" {
-			resp[utils.DeviceVGHDD] = append(resp[utils.DeviceVGHDD], disk[0].Name)
-			log.Infof("eligible hdd pv %s", disk[0].Name)
-		} else {
-			log.Infof("unsupported disk type name: %s, rota: %s", disk[0].Name, disk[0].Rotational)
+		if reason, ok := device.ProtectedReason(protected, disk[0].Name); ok {
+			dm.rejectDisk(disk[0].Name, reason)
+			continue
 		}
+		vgName := matchDiskGroup(diskGroups, disk[0])
+		if vgName == "" {
+			log.Infof("mismatched disk group pv: %s, rota: %s, tran: %s, model: %s", disk[0].Name, disk[0].Rotational, disk[0].Transport, disk[0].Model)
+			continue
+		}
+		resp[vgName] = append(resp[vgName], disk[0].Name)
+		log.Infof("eligible %s pv %s", vgName, disk[0].Name)
 	}
 	return resp, nil
 }
@@ -351,11 +376,173 @@ func (dm *DeviceManager) VolumeConsistencyCheck() {
 	}(ticker1)
 }
 
+// 磁盘热插拔事件触发的定向扫描，只处理发生变化的设备
+// 新增或变更的设备若符合条件则加入vg卷组，移除的设备则清理vg卷组中丢失的pv
+func (dm *DeviceManager) DeviceEventScan(events map[string]string) {
+	if configuration.DiskScanInterval() == 0 {
+		log.Info("skip disk discovery...")
+		return
+	}
+	events = dm.filterDeviceEvents(events)
+	if len(events) == 0 {
+		return
+	}
+	// 预演模式下只更新磁盘变更计划，LocalDisk对象管理磁盘时需要完整的扫描
+	if configuration.DiskScanDryRun() || dm.client != nil {
+		dm.AddAndRemoveDevice()
+		return
+	}
+	currentDiskSelector := configuration.DiskSelector()
+	if len(currentDiskSelector) == 0 {
+		log.Info("disk selector cannot be empty, skip device scan")
+		return
+	}
+	diskSelector, err := regexp.Compile(strings.Join(currentDiskSelector, "|"))
+	if err != nil {
+		log.Warnf("disk regex %s error %v ", strings.Join(currentDiskSelector, "|"), err)
+		return
+	}
+
+	changeBefore, err := dm.VolumeManager.GetCurrentVgStruct()
+	if err != nil {
+		log.Error("get current vg struct failed: " + err.Error())
+		return
+	}
+
+	removed := false
+	for device, action := range events {
+		if !diskSelector.MatchString(device) {
+			continue
+		}
+		// 单条路径故障或恢复，multipath设备本身仍然可用
+		if mp, ok := dm.multipathDevice(device); ok {
+			log.Infof("path %s of multipath device %s %s, skip device scan", device, mp, action)
+			continue
+		}
+		log.Infof("device %s %s, trigger device scan", device, action)
+		if action == udev.ActionRemove {
+			removed = true
+			continue
+		}
+		// 磁盘扩容后需要刷新pv容量
+		if action == udev.ActionChange {
+			pv, err := dm.LvmManager.PVDisplay(device)
+			if err == nil && pv != nil && strings.HasPrefix(pv.VGName, types.KEYWORD) {
+				if err := dm.LvmManager.PVResize(device); err != nil {
+					log.Errorf("resize %s error", device)
+				}
+				continue
+			}
+		}
+		newDisk, err := dm.discoverDisk(device)
+		if err != nil {
+			log.Errorf("find new device %s failed: %s", device, err.Error())
+			continue
+		}
+		dm.addDisksToVg(newDisk)
+	}
+	if removed {
+		dm.VolumeManager.HealthCheck()
+	}
+
+	changeAfter, err := dm.VolumeManager.GetCurrentVgStruct()
+	if err != nil {
+		log.Error("get current vg struct failed: " + err.Error())
+		return
+	}
+	if validateVg(changeBefore, changeAfter) {
+		dm.VolumeManager.NoticeUpdateCapacity([]string{})
+	}
+}
+
+// lvm写入元数据时也会产生change事件，已加入carina vg卷组的磁盘只有容量变化时才需要处理
+func (dm *DeviceManager) filterDeviceEvents(events map[string]string) map[string]string {
+	pvs, err := dm.LvmManager.PVS()
+	if err != nil {
+		log.Warnf("get pv info failed %s", err.Error())
+		return events
+	}
+	pvMap := map[string]types.PVInfo{}
+	for _, pv := range pvs {
+		pvMap[pv.PVName] = pv
+	}
+	result := map[string]string{}
+	for device, action := range events {
+		pv, ok := pvMap[device]
+		if action == udev.ActionChange && ok && strings.HasPrefix(pv.VGName, types.KEYWORD) {
+			if size, ok := dm.deviceSize(device); ok && !pvSizeChanged(size, pv.PVSize) {
+				continue
+			}
+		}
+		result[device] = action
+	}
+	return result
+}
+
+func (dm *DeviceManager) deviceSize(device string) (uint64, bool) {
+	disks, err := dm.DiskManager.ListDevicesDetail(device)
+	if err != nil {
+		return 0, false
+	}
+	for _, d := range disks {
+		if d.Name == device {
+			return d.Size, true
+		}
+	}
+	return 0, false
+}
+
+func pvSizeChanged(deviceSize, pvSize uint64) bool {
+	return deviceSize < pvSize || deviceSize >= pvSize+pvResizeThreshold
+}
+
+// 监听内核块设备事件，合并一段时间内的事件后触发扫描
+func (dm *DeviceManager) watchDeviceEvent() {
+	monitor, err := udev.NewMonitor()
+	if err != nil {
+		log.Warnf("start udev monitor failed %s, only periodic disk scan", err.Error())
+		return
+	}
+	events := make(chan udev.DeviceEvent, 100)
+	go monitor.Run(events, dm.stopChan)
+
+	go func() {
+		pending := map[string]string{}
+		timer := time.NewTimer(deviceEventDebounce)
+		timer.Stop()
+		for {
+			select {
+			case e := <-events:
+				pending[e.DevName] = e.Action
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(deviceEventDebounce)
+			case <-timer.C:
+				select {
+				case dm.deviceEventChan <- pending:
+				case <-dm.stopChan:
+					return
+				}
+				pending = map[string]string{}
+			case <-dm.stopChan:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
 func (dm *DeviceManager) DeviceCheckTask() {
 	log.Info("start device scan...")
 	dm.VolumeManager.RefreshLvmCache()
 	// 服务启动先检查一次
 	dm.AddAndRemoveDevice()
+	// 磁盘热插拔事件，定时扫描作为兜底
+	dm.watchDeviceEvent()
 
 	monitorInterval := configuration.DiskScanInterval()
 	if monitorInterval == 0 {
@@ -385,6 +572,11 @@ func (dm *DeviceManager) DeviceCheckTask() {
 			case <-dm.configModifyChan:
 				log.Info("config modify trigger disk scan...")
 				dm.AddAndRemoveDevice()
+			case <-dm.localDiskChan:
+				log.Info("localdisk modify trigger disk scan...")
+				dm.AddAndRemoveDevice()
+			case events := <-dm.deviceEventChan:
+				dm.DeviceEventScan(events)
 			case <-dm.stopChan:
 				log.Info("stop device scan...")
 				return
@@ -411,3 +603,55 @@ func validateVg(src []types.VgGroup, dst []types.VgGroup) bool {
 	}
 	return false
 }
+
+// 记录不满足条件的磁盘及原因
+func (dm *DeviceManager) rejectDisk(device, reason string) {
+	log.Infof("mismatched disk: %s, %s", device, reason)
+	dm.rejectLock.Lock()
+	defer dm.rejectLock.Unlock()
+	dm.rejectedDisks[device] = types.DiskRejection{
+		Device: device,
+		Reason: reason,
+		Time:   time.Now(),
+	}
+}
+
+func (dm *DeviceManager) acceptDisk(device string) {
+	dm.rejectLock.Lock()
+	defer dm.rejectLock.Unlock()
+	delete(dm.rejectedDisks, device)
+}
+
+func (dm *DeviceManager) resetRejectedDisk() {
+	dm.rejectLock.Lock()
+	defer dm.rejectLock.Unlock()
+	dm.rejectedDisks = make(map[string]types.DiskRejection)
+}
+
+func (dm *DeviceManager) setMultipathPaths(paths map[string]string) {
+	dm.mpathLock.Lock()
+	defer dm.mpathLock.Unlock()
+	dm.mpathPaths = paths
+}
+
+// 路径设备所属的multipath设备
+func (dm *DeviceManager) multipathDevice(path string) (string, bool) {
+	dm.mpathLock.RLock()
+	defer dm.mpathLock.RUnlock()
+	mp, ok := dm.mpathPaths[path]
+	return mp, ok
+}
+
+// 不满足条件的磁盘，按设备名称排序
+func (dm *DeviceManager) RejectedDisks() []types.DiskRejection {
+	dm.rejectLock.RLock()
+	defer dm.rejectLock.RUnlock()
+	result := []types.DiskRejection{}
+	for _, r := range dm.rejectedDisks {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Device < result[j].Device
+	})
+	return result
+}
@@ -19,8 +19,11 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/pkg/devicemanager/udev"
 	"os"
 	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -91,7 +94,7 @@ func TestDeviceManager(t *testing.T) {
 
 	stopChan := make(chan struct{})
 	defer close(stopChan)
-	dm := NewDeviceManager("localhost", stopChan)
+	dm := NewDeviceManager("localhost", nil, nil, nil, stopChan)
 	defer func() {
 		// 清理volume
 		_ = cleanVolume(dm)
@@ -172,7 +175,7 @@ func volumeCreate(dm *DeviceManager) error {
 	}
 
 	for _, e := range table {
-		err := dm.VolumeManager.CreateVolume(e.lvName, e.vgName, e.size, 1)
+		err := dm.VolumeManager.CreateVolume(e.lvName, e.vgName, e.size, 1, nil)
 		if err != nil {
 			fmt.Println(fmt.Sprintf("craete volume failed %s", err.Error()))
 			return err
@@ -188,3 +191,37 @@ func volumeCreate(dm *DeviceManager) error {
 	}
 	return nil
 }
+
+func TestFilterDeviceEvents(t *testing.T) {
+	dm := &DeviceManager{
+		LvmManager: &fakeLvm{pvs: []types.PVInfo{
+			{PVName: "/dev/sdb", VGName: "carina-vg-hdd", PVSize: 100 << 30},
+			{PVName: "/dev/sdc", VGName: "carina-vg-hdd", PVSize: 100 << 30},
+			{PVName: "/dev/sdd", VGName: "data", PVSize: 100 << 30},
+		}},
+		DiskManager: &fakeDevice{disks: []*types.LocalDisk{
+			{Name: "/dev/sdb", Size: 100 << 30},
+			{Name: "/dev/sdc", Size: 200 << 30},
+			{Name: "/dev/sdd", Size: 100 << 30},
+			{Name: "/dev/sde", Size: 100 << 30},
+		}},
+	}
+	events := dm.filterDeviceEvents(map[string]string{
+		// lvm写元数据产生的事件
+		"/dev/sdb": udev.ActionChange,
+		// 磁盘扩容
+		"/dev/sdc": udev.ActionChange,
+		"/dev/sdd": udev.ActionChange,
+		"/dev/sde": udev.ActionChange,
+		"/dev/sdf": udev.ActionAdd,
+	})
+	expect := map[string]string{
+		"/dev/sdc": udev.ActionChange,
+		"/dev/sdd": udev.ActionChange,
+		"/dev/sde": udev.ActionChange,
+		"/dev/sdf": udev.ActionAdd,
+	}
+	if !reflect.DeepEqual(events, expect) {
+		t.Errorf("filterDeviceEvents got %v, want %v", events, expect)
+	}
+}
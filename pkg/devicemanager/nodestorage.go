@@ -0,0 +1,249 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"context"
+	carinav1 "github.com/carina-io/carina/api/v1"
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/pkg/devicemanager/volume"
+	"github.com/carina-io/carina/utils"
+	"github.com/carina-io/carina/utils/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// 容量变更通知合并的时间窗口
+	nodeStorageDebounce = 5 * time.Second
+	// 兜底的定时同步
+	nodeStorageInterval = 300 * time.Second
+)
+
+// 容量变化时更新NodeStorage对象，同时定时同步作为兜底
+func (dm *DeviceManager) NodeStorageTask() {
+	if dm.client == nil {
+		return
+	}
+	notice := make(chan struct{}, 5)
+	dm.VolumeManager.RegisterNoticeServer(volume.NoticeAllGroup, notice)
+	configModifyChan := make(chan struct{}, 1)
+	configuration.RegisterListenerChan(configModifyChan)
+
+	go func() {
+		ticker := time.NewTicker(nodeStorageInterval)
+		defer ticker.Stop()
+		// 启动后立即同步一次
+		timer := time.NewTimer(0)
+		for {
+			select {
+			case <-notice:
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(nodeStorageDebounce)
+			case <-dm.ioStatsChan:
+				dm.syncNodeStorage()
+			case <-configModifyChan:
+				dm.syncNodeStorage()
+			case <-timer.C:
+				dm.syncNodeStorage()
+			case <-ticker.C:
+				dm.syncNodeStorage()
+			case <-dm.stopChan:
+				timer.Stop()
+				log.Info("stop node storage sync...")
+				return
+			}
+		}
+	}()
+}
+
+func (dm *DeviceManager) syncNodeStorage() {
+	if err := dm.updateNodeStorage(); err != nil {
+		log.Errorf("update node storage %s failed %s", dm.nodeName, err.Error())
+	}
+}
+
+// 状态没有变化时不更新对象
+func (dm *DeviceManager) updateNodeStorage() error {
+	status, err := dm.nodeStorageStatus()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ns := &carinav1.NodeStorage{}
+	err = dm.client.Get(ctx, client.ObjectKey{Name: dm.nodeName}, ns)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		ns = &carinav1.NodeStorage{
+			ObjectMeta: metav1.ObjectMeta{Name: dm.nodeName},
+			Spec:       carinav1.NodeStorageSpec{NodeName: dm.nodeName},
+		}
+		// 节点删除后NodeStorage对象随之删除
+		node := &corev1.Node{}
+		if err := dm.client.Get(ctx, client.ObjectKey{Name: dm.nodeName}, node); err == nil {
+			ns.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}}
+		}
+		if err := dm.client.Create(ctx, ns); err != nil {
+			return err
+		}
+		log.Infof("create node storage %s", dm.nodeName)
+	}
+
+	old := ns.Status.DeepCopy()
+	old.LastUpdateTime = status.LastUpdateTime
+	if reflect.DeepEqual(old, status) {
+		return nil
+	}
+	ns.Status = *status
+	return dm.client.Status().Update(ctx, ns)
+}
+
+// 统计各设备组容量、卷数量以及thin pool使用情况
+func (dm *DeviceManager) nodeStorageStatus() (*carinav1.NodeStorageStatus, error) {
+	vgs, err := dm.VolumeManager.GetCurrentVgStruct()
+	if err != nil {
+		return nil, err
+	}
+	lvs, err := dm.VolumeManager.VolumeList("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &carinav1.NodeStorageStatus{
+		Version:        utils.Version,
+		Features:       nodeFeatures(),
+		LastUpdateTime: metav1.Now(),
+	}
+	groups := map[string]*carinav1.DeviceGroupStorage{}
+	for _, vg := range vgs {
+		if !strings.HasPrefix(vg.VGName, types.KEYWORD) {
+			continue
+		}
+		g := &carinav1.DeviceGroupStorage{
+			Name:     vg.VGName,
+			Total:    vg.VGSize,
+			Used:     vg.VGSize - vg.VGFree,
+			Reserved: configuration.ReservedSpace(vg.VGName, vg.VGSize),
+			PVCount:  vg.PVCount,
+		}
+		if vg.VGFree > g.Reserved {
+			g.Allocatable = vg.VGFree - g.Reserved
+		}
+		if reason, ok := dm.VolumeManager.DeviceGroupDegraded(vg.VGName); ok {
+			g.Allocatable = 0
+			g.Degraded = reason
+		}
+		if u, ok := dm.DeviceGroupIOStats(vg.VGName); ok {
+			g.IOStats = &carinav1.DeviceGroupIOStats{
+				Utilization: u.Utilization,
+				QueueDepth:  u.QueueDepth,
+				Latency:     u.Latency,
+				IOPS:        u.IOPS,
+			}
+		}
+		groups[vg.VGName] = g
+	}
+	for _, lv := range lvs {
+		g, ok := groups[lv.VGName]
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(lv.LVName, volume.LVVolume):
+			g.VolumeCount++
+			status.VolumeCount++
+		case strings.HasPrefix(lv.LVName, volume.SNAP):
+			g.SnapCount++
+		case strings.HasPrefix(lv.LVName, volume.THIN):
+			g.ThinPools = append(g.ThinPools, carinav1.ThinPoolStorage{
+				Name:        lv.LVName,
+				Size:        lv.LVSize,
+				DataPercent: lv.DataPercent,
+				ThinCount:   lv.ThinCount,
+			})
+		}
+	}
+	for _, a := range dm.RaidArrayList() {
+		g, ok := groups[a.VGName]
+		if !ok {
+			continue
+		}
+		g.RaidArrays = append(g.RaidArrays, carinav1.RaidArrayStorage{
+			Name:          a.Name,
+			Level:         a.Level,
+			Health:        a.Health(),
+			State:         a.State,
+			RaidDevices:   a.RaidDevices,
+			ActiveDevices: a.ActiveDevices,
+			Rebuild:       a.Rebuild,
+		})
+	}
+	for _, g := range groups {
+		sort.Slice(g.ThinPools, func(i, j int) bool {
+			return g.ThinPools[i].Name < g.ThinPools[j].Name
+		})
+		status.DeviceGroups = append(status.DeviceGroups, *g)
+	}
+	sort.Slice(status.DeviceGroups, func(i, j int) bool {
+		return status.DeviceGroups[i].Name < status.DeviceGroups[j].Name
+	})
+	return status, nil
+}
+
+// carina-node开启的功能
+func nodeFeatures() []string {
+	features := []string{}
+	if configuration.DiskScanInterval() > 0 {
+		features = append(features, "diskScan")
+	}
+	if configuration.DiskScanDryRun() {
+		features = append(features, "diskScanDryRun")
+	}
+	if configuration.AutoApproveDiskRemoval() {
+		features = append(features, "autoApproveDiskRemoval")
+	}
+	if configuration.SmartCheckInterval() > 0 {
+		features = append(features, "smartCheck")
+	}
+	if configuration.SmartDegradeGroup() {
+		features = append(features, "smartDegradeGroup")
+	}
+	if configuration.IOStatsInterval() > 0 {
+		features = append(features, "ioStats")
+	}
+	features = append(features, "diskGroupPolicy="+configuration.DiskGroupPolicy())
+	return features
+}
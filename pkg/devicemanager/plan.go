@@ -0,0 +1,230 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils"
+	"github.com/carina-io/carina/utils/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 计算磁盘变更计划，优先以LocalDisk对象为准，集群中没有LocalDisk资源时使用配置文件
+// 无需扫描时返回空计划
+func (dm *DeviceManager) planDiskChange(actuallyVg []types.VgGroup) (*types.DiskPlan, error) {
+	if dm.client != nil {
+		plan, err := dm.planLocalDisk(actuallyVg)
+		if err == nil {
+			return plan, nil
+		}
+		if !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+		log.Warnf("localdisk resource not found, manage disk by config file")
+	}
+
+	currentDiskSelector := configuration.DiskSelector()
+	if len(currentDiskSelector) == 0 {
+		log.Info("disk selector cannot be empty, skip device scan")
+		return nil, nil
+	}
+	return dm.planConfigDisk(actuallyVg, currentDiskSelector)
+}
+
+// 根据配置文件计算磁盘变更计划，包括需要加入vg卷组的磁盘以及不再匹配diskSelector需要移除的磁盘
+func (dm *DeviceManager) planConfigDisk(actuallyVg []types.VgGroup, currentDiskSelector []string) (*types.DiskPlan, error) {
+	newDisk, err := dm.DiscoverDisk()
+	if err != nil {
+		return nil, fmt.Errorf("find new device failed: %s", err.Error())
+	}
+	newPv, err := dm.DiscoverPv()
+	if err != nil {
+		return nil, fmt.Errorf("find new pv failed: %s", err.Error())
+	}
+
+	// 合并新增设备
+	for key, value := range newDisk {
+		if v, ok := newPv[key]; ok {
+			newDisk[key] = utils.SliceMergeSlice(value, v)
+		}
+	}
+	for key, value := range newPv {
+		if _, ok := newDisk[key]; !ok {
+			newDisk[key] = value
+		}
+	}
+
+	// 需要新增的磁盘, 处理成容易比较的数据
+	ActuallyVgMap := map[string][]string{}
+	for _, v := range actuallyVg {
+		for _, pv := range v.PVS {
+			ActuallyVgMap[v.VGName] = append(ActuallyVgMap[v.VGName], pv.PVName)
+		}
+	}
+
+	plan := &types.DiskPlan{
+		Add:        []types.DiskChange{},
+		Remove:     []types.DiskChange{},
+		DryRun:     configuration.DiskScanDryRun(),
+		CreateTime: time.Now(),
+	}
+	for vgName, pvs := range newDisk {
+		if actuallyPv, ok := ActuallyVgMap[vgName]; ok {
+			pvs = utils.SliceSubSlice(pvs, actuallyPv)
+		}
+		for _, pv := range pvs {
+			plan.Add = append(plan.Add, types.DiskChange{Device: pv, VGName: vgName, Reason: "match disk group"})
+		}
+	}
+
+	diskSelector, err := regexp.Compile(strings.Join(currentDiskSelector, "|"))
+	if err != nil {
+		return nil, fmt.Errorf("disk regex %s error %v ", strings.Join(currentDiskSelector, "|"), err)
+	}
+	plan.Remove = append(plan.Remove, dm.mismatchDisks(actuallyVg, diskSelector)...)
+
+	sortDiskChange(plan.Add)
+	sortDiskChange(plan.Remove)
+	plan.ID = diskPlanID(plan.Remove)
+	if len(plan.Remove) > 0 {
+		plan.Approved = dm.diskRemovalApproved(plan.ID)
+	}
+	return plan, nil
+}
+
+// 移除磁盘需要开启自动审批，或者在Node上添加注解，值为当前计划ID
+func (dm *DeviceManager) diskRemovalApproved(planID string) bool {
+	if configuration.AutoApproveDiskRemoval() {
+		return true
+	}
+	if dm.cache == nil {
+		return false
+	}
+	node := &corev1.Node{}
+	if err := dm.cache.Get(context.Background(), client.ObjectKey{Name: dm.nodeName}, node); err != nil {
+		log.Warnf("get node %s failed %s", dm.nodeName, err.Error())
+		return false
+	}
+	return node.Annotations[utils.DiskRemovalApproveKey] == planID
+}
+
+// 保存磁盘变更计划，计划变化时在Node上产生事件
+func (dm *DeviceManager) publishDiskPlan(plan *types.DiskPlan) {
+	dm.planLock.Lock()
+	old := dm.diskPlan
+	dm.diskPlan = plan
+	dm.planLock.Unlock()
+
+	if old != nil && diskPlanSummary(old) == diskPlanSummary(plan) && old.Approved == plan.Approved {
+		return
+	}
+	if plan.DryRun && len(plan.Add)+len(plan.Remove) > 0 {
+		dm.recordNodeEvent(corev1.EventTypeNormal, "DiskPlanDryRun", "disk plan %s dry run: %s", plan.ID, diskPlanSummary(plan))
+		return
+	}
+	if len(plan.Remove) > 0 && !plan.Approved {
+		dm.recordNodeEvent(corev1.EventTypeWarning, "DiskRemovalPending", "disk plan %s: %s, approve with node annotation %s=%s",
+			plan.ID, diskPlanSummary(plan), utils.DiskRemovalApproveKey, plan.ID)
+	}
+}
+
+// 最近一次磁盘变更计划
+func (dm *DeviceManager) DiskPlan() *types.DiskPlan {
+	dm.planLock.RLock()
+	defer dm.planLock.RUnlock()
+	return dm.diskPlan
+}
+
+func (dm *DeviceManager) recordNodeEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if dm.recorder == nil {
+		return
+	}
+	ref := &corev1.ObjectReference{
+		Kind: "Node",
+		Name: dm.nodeName,
+		UID:  k8stypes.UID(dm.nodeName),
+	}
+	dm.recorder.Eventf(ref, eventType, reason, messageFmt, args...)
+}
+
+func sortDiskChange(changes []types.DiskChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].VGName != changes[j].VGName {
+			return changes[i].VGName < changes[j].VGName
+		}
+		return changes[i].Device < changes[j].Device
+	})
+}
+
+// 计划ID只与待移除的磁盘有关，新增磁盘不影响已有的审批
+func diskPlanID(remove []types.DiskChange) string {
+	if len(remove) == 0 {
+		return ""
+	}
+	items := []string{}
+	for _, c := range remove {
+		items = append(items, c.VGName+":"+c.Device)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(items, ","))))[:10]
+}
+
+func diskPlanSummary(plan *types.DiskPlan) string {
+	items := []string{}
+	for _, c := range plan.Add {
+		items = append(items, fmt.Sprintf("add %s to %s", c.Device, c.VGName))
+	}
+	for _, c := range plan.Remove {
+		items = append(items, fmt.Sprintf("remove %s from %s", c.Device, c.VGName))
+	}
+	return strings.Join(items, ", ")
+}
+
+// 不再匹配磁盘选择器的pv，carina创建的raid阵列由raid配置管理，不参与移除
+func (dm *DeviceManager) mismatchDisks(actuallyVg []types.VgGroup, diskSelector *regexp.Regexp) []types.DiskChange {
+	result := []types.DiskChange{}
+	for _, v := range actuallyVg {
+		for _, pv := range v.PVS {
+			if strings.Contains(pv.PVName, "unknown") {
+				continue
+			}
+			if dm.decommissioning(pv.PVName) {
+				continue
+			}
+			if dm.isRaidArray(pv.PVName) {
+				continue
+			}
+			// lvm可能通过某条路径识别到multipath设备上的pv，以multipath设备为准
+			if mp, ok := dm.multipathDevice(pv.PVName); ok && diskSelector.MatchString(mp) {
+				continue
+			}
+			if !diskSelector.MatchString(pv.PVName) {
+				result = append(result, types.DiskChange{Device: pv.PVName, VGName: v.VGName, Reason: "mismatch disk selector"})
+			}
+		}
+	}
+	return result
+}
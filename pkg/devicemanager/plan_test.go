@@ -0,0 +1,87 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestMismatchDisksSkipRaidArray(t *testing.T) {
+	// 模拟/dev/md/<name> -> /dev/mdN的链接关系
+	dir := t.TempDir()
+	md := filepath.Join(dir, "md127")
+	if err := os.WriteFile(md, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "carina-raid0")
+	if err := os.Symlink(md, link); err != nil {
+		t.Fatal(err)
+	}
+
+	dm := &DeviceManager{
+		decommission: map[string]*types.Decommission{},
+		raidArrays: map[string]*types.RaidArray{
+			"carina-raid0": {Name: "carina-raid0", Device: link},
+		},
+		mpathPaths: map[string]string{},
+	}
+	vgs := []types.VgGroup{{
+		VGName: "carina-vg-raid",
+		PVS: []*types.PVInfo{
+			{PVName: md, VGName: "carina-vg-raid"},
+			{PVName: "/dev/vdc", VGName: "carina-vg-raid"},
+			{PVName: "/dev/sdb", VGName: "carina-vg-raid"},
+		},
+	}}
+	remove := dm.mismatchDisks(vgs, regexp.MustCompile("sd[b-z]"))
+	if len(remove) != 1 || remove[0].Device != "/dev/vdc" {
+		t.Fatalf("expect only /dev/vdc to be removed, got %v", remove)
+	}
+}
+
+func TestDiskRemovalApproved(t *testing.T) {
+	node := func(annotations map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: annotations}}
+	}
+	table := []struct {
+		name        string
+		autoApprove bool
+		node        *corev1.Node
+		expect      bool
+	}{
+		{"auto approve", true, node(nil), true},
+		{"annotation matches plan", false, node(map[string]string{utils.DiskRemovalApproveKey: "a1b2c3"}), true},
+		{"stale annotation", false, node(map[string]string{utils.DiskRemovalApproveKey: "d4e5f6"}), false},
+		{"no annotation", false, node(nil), false},
+	}
+
+	defer configuration.GlobalConfig.Set("autoApproveDiskRemoval", configuration.AutoApproveDiskRemoval())
+	for _, e := range table {
+		configuration.GlobalConfig.Set("autoApproveDiskRemoval", e.autoApprove)
+		dm := &DeviceManager{nodeName: "node-1", cache: newFakeCache(e.node)}
+		if got := dm.diskRemovalApproved("a1b2c3"); got != e.expect {
+			t.Errorf("%s: diskRemovalApproved got %t, want %t", e.name, got, e.expect)
+		}
+	}
+}
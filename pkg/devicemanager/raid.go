@@ -0,0 +1,269 @@
+/*
+  Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package deviceManager
+
+import (
+	"github.com/carina-io/carina/pkg/configuration"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils/log"
+	corev1 "k8s.io/api/core/v1"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// raid阵列巡检时间间隔
+const raidCheckInterval = 60 * time.Second
+
+// 配置了raid级别的设备组，key为vg卷组名称
+func raidDiskGroups() map[string]types.DiskGroup {
+	result := map[string]types.DiskGroup{}
+	for _, g := range configuration.DiskGroups() {
+		if g.RaidDeviceCount() > 0 {
+			result[g.VGName()] = g
+		}
+	}
+	return result
+}
+
+// 将磁盘加入vg卷组，配置了raid的设备组先组建阵列再将阵列加入vg卷组
+func (dm *DeviceManager) addDisksToVg(disks map[string][]string) {
+	raidGroups := raidDiskGroups()
+	for vg, devices := range disks {
+		if g, ok := raidGroups[vg]; ok {
+			dm.assembleRaid(g, devices)
+			continue
+		}
+		for _, d := range devices {
+			if err := dm.VolumeManager.AddNewDiskToVg(d, vg); err != nil {
+				log.Errorf("add new disk failed vg: %s, disk: %s, error: %v", vg, d, err)
+			}
+		}
+	}
+}
+
+// 新磁盘优先替换降级阵列中缺失的磁盘，其余磁盘按照阵列磁盘数量组建新阵列
+// 不足一个阵列的磁盘等待后续磁盘加入
+func (dm *DeviceManager) assembleRaid(g types.DiskGroup, devices []string) {
+	arrays, err := dm.Raid.List()
+	if err != nil {
+		log.Errorf("list raid array failed %s", err.Error())
+		return
+	}
+	sort.Strings(devices)
+
+	used := map[string]bool{}
+	for _, a := range arrays {
+		if types.RaidGroup(a.Name) != g.Name {
+			continue
+		}
+		used[a.Name] = true
+		for a.WorkingDevices < a.RaidDevices && len(devices) > 0 {
+			log.Infof("add disk %s to degraded raid %s, start rebuild", devices[0], a.Name)
+			if err := dm.Raid.Add(a.Device, devices[0]); err != nil {
+				log.Errorf("add disk %s to raid %s failed %s", devices[0], a.Name, err.Error())
+				break
+			}
+			dm.recordNodeEvent(corev1.EventTypeNormal, "RaidRebuild", "disk %s added to raid %s, rebuilding", devices[0], a.Name)
+			devices = devices[1:]
+			a.WorkingDevices++
+		}
+		// 阵列创建成功但是加入vg卷组失败，重新加入
+		if !dm.isPv(a.Device) {
+			if err := dm.VolumeManager.AddNewDiskToVg(a.Device, g.VGName()); err != nil {
+				log.Errorf("add raid %s to vg %s failed %s", a.Name, g.VGName(), err.Error())
+			}
+		}
+	}
+
+	count := g.RaidDeviceCount()
+	index := 0
+	for len(devices) >= count {
+		for used[types.RaidName(g.Name, index)] {
+			index++
+		}
+		name := types.RaidName(g.Name, index)
+		used[name] = true
+		members := devices[:count]
+		devices = devices[count:]
+
+		log.Infof("create raid%s %s with %s", types.NormalizeRaidLevel(g.RaidLevel), name, strings.Join(members, ","))
+		dev, err := dm.Raid.Create(name, g.RaidLevel, members)
+		if err != nil {
+			log.Errorf("create raid %s failed %s", name, err.Error())
+			dm.recordNodeEvent(corev1.EventTypeWarning, "RaidCreateFailed", "create raid %s with %s failed: %s", name, strings.Join(members, ","), err.Error())
+			continue
+		}
+		dm.recordNodeEvent(corev1.EventTypeNormal, "RaidCreated", "raid%s %s created with %s", types.NormalizeRaidLevel(g.RaidLevel), name, strings.Join(members, ","))
+		if err := dm.VolumeManager.AddNewDiskToVg(dev, g.VGName()); err != nil {
+			log.Errorf("add raid %s to vg %s failed %s", name, g.VGName(), err.Error())
+		}
+	}
+	if len(devices) > 0 {
+		log.Infof("disks %s of group %s are waiting for more disks to create raid", strings.Join(devices, ","), g.Name)
+	}
+}
+
+// 判断设备是否已经是pv，md设备存在/dev/md127与/dev/md/name两种路径
+func (dm *DeviceManager) isPv(dev string) bool {
+	pvs, err := dm.VolumeManager.GetCurrentPvStruct()
+	if err != nil {
+		log.Errorf("get pv failed %s", err.Error())
+		// 无法判断时不重复加入
+		return true
+	}
+	target, _ := filepath.EvalSymlinks(dev)
+	for _, pv := range pvs {
+		if pv.PVName == dev {
+			return true
+		}
+		if p, err := filepath.EvalSymlinks(pv.PVName); err == nil && p == target {
+			return true
+		}
+	}
+	return false
+}
+
+// 巡检carina创建的raid阵列，移除故障磁盘，阵列状态变化时产生事件
+// 阵列降级后触发磁盘扫描，若有新磁盘即加入阵列开始重建
+func (dm *DeviceManager) RaidCheck() {
+	if len(raidDiskGroups()) == 0 && len(dm.RaidArrayList()) == 0 {
+		return
+	}
+	arrays, err := dm.Raid.List()
+	if err != nil {
+		log.Errorf("list raid array failed %s", err.Error())
+		return
+	}
+
+	dm.raidLock.RLock()
+	old := dm.raidArrays
+	dm.raidLock.RUnlock()
+
+	now := time.Now()
+	current := map[string]*types.RaidArray{}
+	rescan := false
+	for i := range arrays {
+		a := &arrays[i]
+		if types.RaidGroup(a.Name) == "" {
+			continue
+		}
+		if a.FailedDevices > 0 {
+			log.Warnf("raid %s has %d failed devices, remove them", a.Name, a.FailedDevices)
+			if err := dm.Raid.RemoveFailed(a.Device); err != nil {
+				log.Errorf("remove failed devices of raid %s failed %s", a.Name, err.Error())
+			}
+		}
+		a.UpdateTime = now
+		current[a.Name] = a
+
+		health := a.Health()
+		if health == types.RaidDegraded {
+			rescan = true
+		}
+		if o, ok := old[a.Name]; ok && o.Health() == health {
+			continue
+		} else if !ok && health == types.RaidHealthy {
+			continue
+		}
+		eventType := corev1.EventTypeWarning
+		if health == types.RaidHealthy {
+			eventType = corev1.EventTypeNormal
+		}
+		log.Infof("raid %s of %s is %s, state %s", a.Name, a.VGName, strings.ToLower(health), a.State)
+		dm.recordNodeEvent(eventType, "Raid"+health, "raid %s of %s is %s: %s, %d/%d devices active",
+			a.Name, a.VGName, strings.ToLower(health), a.State, a.ActiveDevices, a.RaidDevices)
+	}
+	for name, o := range old {
+		if _, ok := current[name]; !ok {
+			dm.recordNodeEvent(corev1.EventTypeWarning, "RaidMissing", "raid %s of %s not found", name, o.VGName)
+		}
+	}
+
+	dm.raidLock.Lock()
+	dm.raidArrays = current
+	dm.raidLock.Unlock()
+
+	if rescan {
+		dm.TriggerDiskScan()
+	}
+}
+
+// carina创建的raid阵列，按名称排序
+func (dm *DeviceManager) RaidArrayList() []types.RaidArray {
+	dm.raidLock.RLock()
+	defer dm.raidLock.RUnlock()
+	result := []types.RaidArray{}
+	for _, a := range dm.raidArrays {
+		result = append(result, *a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// 磁盘所属的carina raid阵列
+func (dm *DeviceManager) raidMember(device string) (*types.RaidArray, bool) {
+	dm.raidLock.RLock()
+	defer dm.raidLock.RUnlock()
+	for _, a := range dm.raidArrays {
+		for _, m := range a.Members {
+			if m.Device == device && !strings.Contains(m.State, "faulty") {
+				return a, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// 设备是否为carina创建的raid阵列，/dev/md/<name>与/dev/mdN视为同一设备
+func (dm *DeviceManager) isRaidArray(device string) bool {
+	target, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		target = device
+	}
+	dm.raidLock.RLock()
+	defer dm.raidLock.RUnlock()
+	for _, a := range dm.raidArrays {
+		if a.Device == device || a.Device == target {
+			return true
+		}
+		if p, err := filepath.EvalSymlinks(a.Device); err == nil && p == target {
+			return true
+		}
+	}
+	return false
+}
+
+func (dm *DeviceManager) RaidTask() {
+	ticker1 := time.NewTicker(raidCheckInterval)
+	go func(t *time.Ticker) {
+		defer ticker1.Stop()
+		// 服务启动先检查一次
+		dm.RaidCheck()
+		for {
+			select {
+			case <-t.C:
+				dm.RaidCheck()
+			case <-dm.stopChan:
+				log.Info("stop raid check...")
+				return
+			}
+		}
+	}(ticker1)
+}
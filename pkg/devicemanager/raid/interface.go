@@ -0,0 +1,32 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package raid
+
+import (
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+)
+
+type Raid interface {
+	// 创建md raid阵列，返回阵列设备路径
+	Create(name, level string, devices []string) (string, error)
+	// 节点上所有md raid阵列
+	List() ([]types.RaidArray, error)
+	Detail(dev string) (*types.RaidArray, error)
+	// 向阵列中加入磁盘，阵列降级时自动开始重建
+	Add(dev, member string) error
+	// 移除阵列中故障及已经消失的磁盘
+	RemoveFailed(dev string) error
+}
@@ -0,0 +1,108 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package raid
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 解析/proc/mdstat，返回所有阵列名称，如md127
+func parseMdstat(content string) []string {
+	result := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != ":" || !strings.HasPrefix(fields[0], "md") {
+			continue
+		}
+		result = append(result, fields[0])
+	}
+	return result
+}
+
+// 解析mdadm --detail输出
+func parseMdadmDetail(dev, output string) (*types.RaidArray, error) {
+	a := &types.RaidArray{
+		Device:     dev,
+		Members:    []types.RaidMember{},
+		UpdateTime: time.Now(),
+	}
+	members := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "Number") && strings.Contains(line, "RaidDevice") {
+			members = true
+			continue
+		}
+		if members {
+			// Number Major Minor RaidDevice State... Device
+			fields := strings.Fields(line)
+			if len(fields) < 6 || !strings.HasPrefix(fields[len(fields)-1], "/dev/") {
+				continue
+			}
+			a.Members = append(a.Members, types.RaidMember{
+				Device: fields[len(fields)-1],
+				State:  strings.Join(fields[4:len(fields)-1], " "),
+			})
+			continue
+		}
+
+		kv := strings.SplitN(line, " : ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "Raid Level":
+			a.Level = types.NormalizeRaidLevel(value)
+		case "State":
+			a.State = value
+		case "Raid Devices":
+			a.RaidDevices, _ = strconv.Atoi(value)
+		case "Active Devices":
+			a.ActiveDevices, _ = strconv.Atoi(value)
+		case "Working Devices":
+			a.WorkingDevices, _ = strconv.Atoi(value)
+		case "Failed Devices":
+			a.FailedDevices, _ = strconv.Atoi(value)
+		case "Spare Devices":
+			a.SpareDevices, _ = strconv.Atoi(value)
+		case "Rebuild Status", "Resync Status":
+			// 42% complete
+			a.Rebuild, _ = strconv.ParseFloat(strings.TrimSuffix(strings.Fields(value)[0], "%"), 64)
+		case "Name":
+			// node1:carina-hdd-0  (local to host node1)
+			name := strings.Fields(value)[0]
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				name = name[i+1:]
+			}
+			a.Name = name
+		}
+	}
+	if a.Level == "" {
+		return nil, fmt.Errorf("parse mdadm detail of %s failed", dev)
+	}
+	if group := types.RaidGroup(a.Name); group != "" {
+		a.VGName = types.DeviceGroupPrefix + group
+	}
+	return a, nil
+}
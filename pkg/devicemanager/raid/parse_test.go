@@ -0,0 +1,100 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package raid
+
+import (
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"testing"
+)
+
+const mdstat = `Personalities : [raid1] [raid6] [raid5] [raid4]
+md126 : active raid5 sdd[3] sdc[1] sdb[0]
+      20953088 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/3] [UUU]
+
+md127 : active raid1 sdf[2] sde[0]
+      10476544 blocks super 1.2 [2/1] [U_]
+      [====>................]  recovery = 24.3% (2548736/10476544) finish=0.6min speed=212394K/sec
+
+unused devices: <none>
+`
+
+const degradedDetail = `/dev/md127:
+           Version : 1.2
+     Creation Time : Mon Aug  2 10:12:01 2021
+        Raid Level : raid1
+        Array Size : 10476544 (9.99 GiB 10.73 GB)
+     Used Dev Size : 10476544 (9.99 GiB 10.73 GB)
+      Raid Devices : 2
+     Total Devices : 3
+       Persistence : Superblock is persistent
+
+       Update Time : Mon Aug  2 10:30:12 2021
+             State : clean, degraded, recovering
+    Active Devices : 1
+   Working Devices : 2
+    Failed Devices : 1
+     Spare Devices : 1
+
+Consistency Policy : resync
+
+    Rebuild Status : 24% complete
+
+              Name : node1:carina-hdd-0  (local to host node1)
+              UUID : 3b1a2f6e:9c1d4f0e:2b6c5d1a:8e7f9a0b
+            Events : 30
+
+    Number   Major   Minor   RaidDevice State
+       0       8       64        0      active sync   /dev/sde
+       2       8       80        1      spare rebuilding   /dev/sdf
+
+       1       8       96        -      faulty   /dev/sdg
+`
+
+func TestParseMdstat(t *testing.T) {
+	result := parseMdstat(mdstat)
+	if len(result) != 2 || result[0] != "md126" || result[1] != "md127" {
+		t.Errorf("parse mdstat %v", result)
+	}
+	if len(parseMdstat("Personalities : \nunused devices: <none>\n")) != 0 {
+		t.Error("parse empty mdstat")
+	}
+}
+
+func TestParseMdadmDetail(t *testing.T) {
+	a, err := parseMdadmDetail("/dev/md127", degradedDetail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "carina-hdd-0" || a.VGName != "carina-vg-hdd" || a.Level != "1" {
+		t.Errorf("name %s vg %s level %s", a.Name, a.VGName, a.Level)
+	}
+	if a.RaidDevices != 2 || a.ActiveDevices != 1 || a.WorkingDevices != 2 || a.FailedDevices != 1 || a.SpareDevices != 1 {
+		t.Errorf("devices %+v", a)
+	}
+	if a.Rebuild != 24 {
+		t.Errorf("rebuild %f", a.Rebuild)
+	}
+	if a.Health() != types.RaidRebuilding {
+		t.Errorf("health %s", a.Health())
+	}
+	if len(a.Members) != 3 || a.Members[1].Device != "/dev/sdf" || a.Members[1].State != "spare rebuilding" || a.Members[2].State != "faulty" {
+		t.Errorf("members %+v", a.Members)
+	}
+
+	if _, err := parseMdadmDetail("/dev/md0", "mdadm: cannot open /dev/md0: No such file or directory"); err == nil {
+		t.Error("parse invalid output should fail")
+	}
+}
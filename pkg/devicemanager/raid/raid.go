@@ -0,0 +1,81 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package raid
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils/exec"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	mdadmTimeout = 60 * time.Second
+	mdstatFile   = "/proc/mdstat"
+)
+
+type RaidImplement struct {
+	Executor exec.Executor
+}
+
+func (r *RaidImplement) Create(name, level string, devices []string) (string, error) {
+	dev := "/dev/md/" + name
+	args := []string{"--create", dev, "--run", "--metadata=1.2", "--name=" + name,
+		"--level=" + types.NormalizeRaidLevel(level), fmt.Sprintf("--raid-devices=%d", len(devices))}
+	args = append(args, devices...)
+	if _, err := r.Executor.ExecuteCommandWithTimeout(mdadmTimeout, "mdadm", args...); err != nil {
+		return "", err
+	}
+	return dev, nil
+}
+
+func (r *RaidImplement) List() ([]types.RaidArray, error) {
+	content, err := ioutil.ReadFile(mdstatFile)
+	if err != nil {
+		return nil, err
+	}
+	result := []types.RaidArray{}
+	for _, md := range parseMdstat(string(content)) {
+		a, err := r.Detail("/dev/" + md)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+	return result, nil
+}
+
+func (r *RaidImplement) Detail(dev string) (*types.RaidArray, error) {
+	output, err := r.Executor.ExecuteCommandWithTimeout(mdadmTimeout, "mdadm", "--detail", dev)
+	if err != nil {
+		return nil, err
+	}
+	return parseMdadmDetail(dev, output)
+}
+
+func (r *RaidImplement) Add(dev, member string) error {
+	_, err := r.Executor.ExecuteCommandWithTimeout(mdadmTimeout, "mdadm", "--manage", dev, "--add", member)
+	return err
+}
+
+func (r *RaidImplement) RemoveFailed(dev string) error {
+	if _, err := r.Executor.ExecuteCommandWithTimeout(mdadmTimeout, "mdadm", "--manage", dev, "--remove", "failed"); err != nil {
+		return err
+	}
+	_, err := r.Executor.ExecuteCommandWithTimeout(mdadmTimeout, "mdadm", "--manage", dev, "--remove", "detached")
+	return err
+}
@@ -0,0 +1,180 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"strings"
+)
+
+const (
+	// nvme寿命消耗告警阈值
+	percentageUsedWarning = 90
+	// 温度告警阈值，摄氏度
+	temperatureWarning = 70
+
+	// smartctl退出码，命令行错误以及无法打开设备
+	exitCommandLineError = 1 << 0
+	exitDeviceOpenFailed = 1 << 1
+)
+
+// ata属性ID
+const (
+	ataReallocatedSector    = 5
+	ataCurrentPending       = 197
+	ataOfflineUncorrectable = 198
+)
+
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			WhenFailed string `json:"when_failed"`
+			Raw        struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NvmeSmartHealthInformationLog *struct {
+		CriticalWarning uint64 `json:"critical_warning"`
+		Temperature     int64  `json:"temperature"`
+		PercentageUsed  uint64 `json:"percentage_used"`
+		MediaErrors     uint64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+	ScsiGrownDefectList uint64 `json:"scsi_grown_defect_list"`
+	Temperature         struct {
+		Current int64 `json:"current"`
+	} `json:"temperature"`
+}
+
+// 解析smartctl --json输出，并根据各项指标评估磁盘健康状态
+func parseSmartctl(dev, output string) (*types.DiskHealth, error) {
+	out := smartctlOutput{}
+	if err := json.Unmarshal([]byte(output), &out); err != nil {
+		return nil, fmt.Errorf("parse smartctl output of %s failed %s", dev, err.Error())
+	}
+	if out.Smartctl.ExitStatus&(exitCommandLineError|exitDeviceOpenFailed) != 0 {
+		messages := []string{}
+		for _, m := range out.Smartctl.Messages {
+			messages = append(messages, m.String)
+		}
+		return nil, fmt.Errorf("smartctl %s failed: %s", dev, strings.Join(messages, "; "))
+	}
+
+	health := &types.DiskHealth{
+		Device:      dev,
+		Model:       out.ModelName,
+		Serial:      out.SerialNumber,
+		Status:      types.DiskHealthy,
+		Temperature: out.Temperature.Current,
+	}
+
+	warnings := []string{}
+	failures := []string{}
+	reason := ""
+	warn := func(r, msg string) {
+		if reason == "" {
+			reason = r
+		}
+		warnings = append(warnings, msg)
+	}
+	fail := func(r, msg string) {
+		failures = append(failures, msg)
+		if len(failures) == 1 {
+			reason = r
+		}
+	}
+
+	if out.SmartStatus == nil {
+		health.Status = types.DiskUnknown
+		health.Reason = "SmartUnavailable"
+		health.Message = "smart status is not available"
+		return health, nil
+	}
+	health.SmartPassed = out.SmartStatus.Passed
+	if !health.SmartPassed {
+		fail("SmartFailed", "smart overall-health self-assessment failed")
+	}
+
+	for _, attr := range out.AtaSmartAttributes.Table {
+		switch attr.ID {
+		case ataReallocatedSector:
+			health.ReallocatedSectors = attr.Raw.Value
+		case ataCurrentPending, ataOfflineUncorrectable:
+			health.PendingSectors += attr.Raw.Value
+		}
+		if attr.WhenFailed == "now" {
+			fail("AttributeFailed", fmt.Sprintf("attribute %s failing now", attr.Name))
+		}
+	}
+	if out.ScsiGrownDefectList > 0 {
+		health.ReallocatedSectors = out.ScsiGrownDefectList
+	}
+
+	if nvme := out.NvmeSmartHealthInformationLog; nvme != nil {
+		health.MediaErrors = nvme.MediaErrors
+		health.PercentageUsed = nvme.PercentageUsed
+		if health.Temperature == 0 {
+			health.Temperature = nvme.Temperature
+		}
+		if nvme.CriticalWarning != 0 {
+			fail("CriticalWarning", fmt.Sprintf("nvme critical warning 0x%x", nvme.CriticalWarning))
+		}
+		if nvme.PercentageUsed >= 100 {
+			fail("WearOut", fmt.Sprintf("percentage used %d%%", nvme.PercentageUsed))
+		} else if nvme.PercentageUsed >= percentageUsedWarning {
+			warn("WearOut", fmt.Sprintf("percentage used %d%%", nvme.PercentageUsed))
+		}
+	}
+
+	if health.ReallocatedSectors > 0 {
+		warn("ReallocatedSectors", fmt.Sprintf("%d reallocated sectors", health.ReallocatedSectors))
+	}
+	if health.PendingSectors > 0 {
+		warn("PendingSectors", fmt.Sprintf("%d pending sectors", health.PendingSectors))
+	}
+	if health.MediaErrors > 0 {
+		warn("MediaErrors", fmt.Sprintf("%d media errors", health.MediaErrors))
+	}
+	if health.Temperature >= temperatureWarning {
+		warn("HighTemperature", fmt.Sprintf("temperature %d celsius", health.Temperature))
+	}
+
+	if len(failures) > 0 {
+		health.Status = types.DiskFailing
+		health.Message = strings.Join(append(failures, warnings...), ", ")
+	} else if len(warnings) > 0 {
+		health.Status = types.DiskWarning
+		health.Message = strings.Join(warnings, ", ")
+	}
+	health.Reason = reason
+	return health, nil
+}
@@ -0,0 +1,98 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package smart
+
+import (
+	"testing"
+
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+)
+
+func TestParseSmartctl(t *testing.T) {
+	table := []struct {
+		name   string
+		output string
+		status string
+		reason string
+		valid  bool
+	}{
+		{
+			name:   "ata healthy",
+			output: `{"smartctl":{"exit_status":0},"model_name":"ST8000NM","serial_number":"ZA1234","smart_status":{"passed":true},"ata_smart_attributes":{"table":[{"id":5,"name":"Reallocated_Sector_Ct","when_failed":"","raw":{"value":0}}]},"temperature":{"current":35}}`,
+			status: types.DiskHealthy,
+			valid:  true,
+		},
+		{
+			name:   "ata reallocated",
+			output: `{"smartctl":{"exit_status":64},"smart_status":{"passed":true},"ata_smart_attributes":{"table":[{"id":5,"name":"Reallocated_Sector_Ct","when_failed":"","raw":{"value":24}},{"id":197,"name":"Current_Pending_Sector","when_failed":"","raw":{"value":0}}]},"temperature":{"current":40}}`,
+			status: types.DiskWarning,
+			reason: "ReallocatedSectors",
+			valid:  true,
+		},
+		{
+			name:   "ata failed",
+			output: `{"smartctl":{"exit_status":8},"smart_status":{"passed":false},"ata_smart_attributes":{"table":[{"id":5,"name":"Reallocated_Sector_Ct","when_failed":"now","raw":{"value":3000}}]}}`,
+			status: types.DiskFailing,
+			reason: "SmartFailed",
+			valid:  true,
+		},
+		{
+			name:   "nvme media errors",
+			output: `{"smartctl":{"exit_status":0},"smart_status":{"passed":true},"nvme_smart_health_information_log":{"critical_warning":0,"temperature":45,"percentage_used":12,"media_errors":2}}`,
+			status: types.DiskWarning,
+			reason: "MediaErrors",
+			valid:  true,
+		},
+		{
+			name:   "nvme critical warning",
+			output: `{"smartctl":{"exit_status":0},"smart_status":{"passed":true},"nvme_smart_health_information_log":{"critical_warning":4,"temperature":45,"percentage_used":95,"media_errors":0}}`,
+			status: types.DiskFailing,
+			reason: "CriticalWarning",
+			valid:  true,
+		},
+		{
+			name:   "virtual disk",
+			output: `{"smartctl":{"exit_status":4},"model_name":"QEMU HARDDISK"}`,
+			status: types.DiskUnknown,
+			reason: "SmartUnavailable",
+			valid:  true,
+		},
+		{
+			name:   "open failed",
+			output: `{"smartctl":{"exit_status":2,"messages":[{"string":"Smartctl open device: /dev/sdx failed: No such device","severity":"error"}]}}`,
+			valid:  false,
+		},
+		{
+			name:   "not json",
+			output: `smartctl: command not found`,
+			valid:  false,
+		},
+	}
+
+	for _, e := range table {
+		health, err := parseSmartctl("/dev/sdb", e.output)
+		if (err == nil) != e.valid {
+			t.Errorf("%s: parseSmartctl error %v", e.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if health.Status != e.status || health.Reason != e.reason {
+			t.Errorf("%s: parseSmartctl got status %s reason %s, want %s %s", e.name, health.Status, health.Reason, e.status, e.reason)
+		}
+	}
+}
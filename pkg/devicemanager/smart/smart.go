@@ -0,0 +1,42 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package smart
+
+import (
+	"github.com/carina-io/carina/pkg/devicemanager/types"
+	"github.com/carina-io/carina/utils/exec"
+	"time"
+)
+
+// smartctl读取磁盘信息可能较慢，超时则放弃本次检查
+const smartctlTimeout = 60 * time.Second
+
+type SmartImplement struct {
+	Executor exec.Executor
+}
+
+func (s *SmartImplement) DiskHealth(dev string) (*types.DiskHealth, error) {
+	// smartctl的退出码为位掩码，磁盘异常时同样返回非0，需要以输出内容为准
+	output, err := s.Executor.ExecuteCommandWithTimeout(smartctlTimeout, "smartctl", "--json", "--all", dev)
+	health, perr := parseSmartctl(dev, output)
+	if perr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, perr
+	}
+	return health, nil
+}
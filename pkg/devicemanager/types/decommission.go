@@ -0,0 +1,41 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import "time"
+
+// 磁盘下线阶段
+const (
+	DecommissionPending   = "Pending"
+	DecommissionMoving    = "Moving"
+	DecommissionReducing  = "Reducing"
+	DecommissionCompleted = "Completed"
+	DecommissionFailed    = "Failed"
+)
+
+// 磁盘下线状态，持久化到本地文件，服务重启后继续执行
+type Decommission struct {
+	Device string `json:"device"`
+	VGName string `json:"vgName"`
+	// 用于识别更换后的新磁盘
+	Serial string `json:"serial"`
+	Phase  string `json:"phase"`
+	// 数据迁移进度百分比
+	Progress   float64   `json:"progress"`
+	Message    string    `json:"message"`
+	StartTime  time.Time `json:"startTime"`
+	UpdateTime time.Time `json:"updateTime"`
+}
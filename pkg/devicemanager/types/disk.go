@@ -15,6 +15,8 @@
 */
 package types
 
+import "time"
+
 const (
 	KEYWORD = "carina-"
 
@@ -30,6 +32,11 @@ const (
 	LVMType = "lvm"
 	// MultiPath is for multipath devices
 	MultiPath = "mpath"
+	// RaidType is for md raid devices, such as raid1
+	RaidType = "raid"
+
+	// TransportNVMe is the transport type of nvme devices
+	TransportNVMe = "nvme"
 )
 
 type LocalDisk struct {
@@ -53,4 +60,38 @@ type LocalDisk struct {
 	Used uint64 `json:"used"`
 	// parent Name
 	ParentName string `json:"parentName"`
+	// disk model
+	Model string `json:"model"`
+	// disk serial number
+	Serial string `json:"serial"`
+	// Transport is the device transport type, eg. nvme sata sas
+	Transport string `json:"transport"`
+	// multipath设备的WWID，设备名称变化后仍能对应到同一块磁盘
+	WWID string `json:"wwid,omitempty"`
+	// multipath设备当前可用的路径设备
+	Paths []string `json:"paths,omitempty"`
+}
+
+// 不满足条件的磁盘及原因
+type DiskRejection struct {
+	Device string    `json:"device"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// 磁盘是否可被carina使用
+const (
+	DiskEligible   = "Eligible"
+	DiskIneligible = "Ineligible"
+	DiskInUse      = "InUse"
+)
+
+// 磁盘清单，包括磁盘所属vg卷组、健康状态以及磁盘发现规则的检查结果
+type DiskInventory struct {
+	LocalDisk
+	VGName string `json:"vgName"`
+	Health string `json:"health"`
+	// Eligible Ineligible InUse
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason"`
 }
@@ -0,0 +1,170 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import (
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"regexp"
+	"strings"
+)
+
+// 设备组vg卷组名称前缀
+const DeviceGroupPrefix = KEYWORD + "vg-"
+
+var diskGroupNameRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// 磁盘分组，磁盘满足所有配置的条件才会加入该分组
+// 未配置的条件表示不限制
+type DiskGroup struct {
+	// 分组名称，对应的vg卷组为carina-vg-<name>
+	Name string `json:"name"`
+	// 设备路径，支持正则表达式
+	DiskSelector []string `json:"diskSelector"`
+	// 磁盘容量范围，如500Gi、2Ti
+	MinSize string `json:"minSize"`
+	MaxSize string `json:"maxSize"`
+	// 磁盘型号、序列号，支持正则表达式
+	Model  []string `json:"model"`
+	Serial []string `json:"serial"`
+	// 传输类型，如nvme、sata、sas
+	Transport []string `json:"transport"`
+	// 1 for hdd, 0 for ssd and nvme
+	Rotational string `json:"rotational"`
+	// 配置后磁盘先组建md raid阵列再加入vg卷组，支持0、1、5、6、10
+	RaidLevel string `json:"raidLevel"`
+	// 每个阵列的磁盘数量，默认为该级别最少需要的磁盘数量
+	RaidDevices int `json:"raidDevices"`
+}
+
+// VGName 返回分组对应的vg卷组名称
+func (g *DiskGroup) VGName() string {
+	return DeviceGroupPrefix + g.Name
+}
+
+// Validate 检查分组配置是否合法
+func (g *DiskGroup) Validate() error {
+	if !diskGroupNameRegex.MatchString(g.Name) {
+		return fmt.Errorf("invalid disk group name %q, must consist of lower case alphanumeric characters or '-'", g.Name)
+	}
+	for _, s := range [][]string{g.DiskSelector, g.Model, g.Serial} {
+		for _, p := range s {
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("disk group %s regex %s error %v", g.Name, p, err)
+			}
+		}
+	}
+	minSize, err := parseSize(g.MinSize)
+	if err != nil {
+		return fmt.Errorf("disk group %s minSize %s error %v", g.Name, g.MinSize, err)
+	}
+	maxSize, err := parseSize(g.MaxSize)
+	if err != nil {
+		return fmt.Errorf("disk group %s maxSize %s error %v", g.Name, g.MaxSize, err)
+	}
+	if maxSize > 0 && minSize > maxSize {
+		return fmt.Errorf("disk group %s minSize %s greater than maxSize %s", g.Name, g.MinSize, g.MaxSize)
+	}
+	if g.Rotational != "" && g.Rotational != "0" && g.Rotational != "1" {
+		return fmt.Errorf("disk group %s rotational %s, should be 0 or 1", g.Name, g.Rotational)
+	}
+	if g.RaidLevel != "" {
+		minDevices, ok := raidMinDevices[NormalizeRaidLevel(g.RaidLevel)]
+		if !ok {
+			return fmt.Errorf("disk group %s raid level %s, should be 0, 1, 5, 6 or 10", g.Name, g.RaidLevel)
+		}
+		if g.RaidDevices != 0 && g.RaidDevices < minDevices {
+			return fmt.Errorf("disk group %s raid%s needs at least %d devices", g.Name, NormalizeRaidLevel(g.RaidLevel), minDevices)
+		}
+	}
+	return nil
+}
+
+// RaidDeviceCount 每个raid阵列的磁盘数量，未配置raid时返回0
+func (g *DiskGroup) RaidDeviceCount() int {
+	if g.RaidLevel == "" {
+		return 0
+	}
+	if g.RaidDevices > 0 {
+		return g.RaidDevices
+	}
+	return raidMinDevices[NormalizeRaidLevel(g.RaidLevel)]
+}
+
+// Match 判断磁盘是否满足分组条件
+func (g *DiskGroup) Match(d *LocalDisk) bool {
+	if d == nil {
+		return false
+	}
+	if !matchAny(g.DiskSelector, d.Name) || !matchAny(g.Model, d.Model) || !matchAny(g.Serial, d.Serial) {
+		return false
+	}
+	if len(g.Transport) > 0 {
+		transport := false
+		for _, t := range g.Transport {
+			if strings.EqualFold(t, d.Transport) {
+				transport = true
+				break
+			}
+		}
+		if !transport {
+			return false
+		}
+	}
+	if g.Rotational != "" && g.Rotational != d.Rotational {
+		return false
+	}
+	minSize, err := parseSize(g.MinSize)
+	if err != nil || d.Size < minSize {
+		return false
+	}
+	maxSize, err := parseSize(g.MaxSize)
+	if err != nil || (maxSize > 0 && d.Size > maxSize) {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		if r.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSize(size string) (uint64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, err
+	}
+	if q.Sign() < 0 {
+		return 0, errors.New("size must not be negative")
+	}
+	return uint64(q.Value()), nil
+}
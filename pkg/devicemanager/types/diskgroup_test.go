@@ -0,0 +1,70 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import (
+	"testing"
+)
+
+func TestDiskGroupMatch(t *testing.T) {
+	nvme := &LocalDisk{Name: "/dev/nvme0n1", Size: 1 << 40, Rotational: "0", Transport: "nvme", Model: "INTEL SSDPE2KX010T8"}
+	sata := &LocalDisk{Name: "/dev/sdb", Size: 480 << 30, Rotational: "0", Transport: "sata", Model: "Samsung SSD 860"}
+	hdd := &LocalDisk{Name: "/dev/sdc", Size: 8 << 40, Rotational: "1", Transport: "sas", Serial: "ZA1234"}
+
+	table := []struct {
+		group  DiskGroup
+		disk   *LocalDisk
+		result bool
+	}{
+		{DiskGroup{Name: "ssd", Rotational: "0"}, nvme, true},
+		{DiskGroup{Name: "hdd", Rotational: "1"}, nvme, false},
+		{DiskGroup{Name: "fast", Transport: []string{"NVMe"}}, nvme, true},
+		{DiskGroup{Name: "fast", Transport: []string{"nvme"}}, sata, false},
+		{DiskGroup{Name: "small", MaxSize: "500Gi"}, sata, true},
+		{DiskGroup{Name: "small", MaxSize: "500Gi"}, nvme, false},
+		{DiskGroup{Name: "big", MinSize: "4Ti", DiskSelector: []string{"sd[c-z]"}}, hdd, true},
+		{DiskGroup{Name: "big", MinSize: "4Ti", DiskSelector: []string{"sd[c-z]"}}, sata, false},
+		{DiskGroup{Name: "samsung", Model: []string{"^Samsung"}}, sata, true},
+		{DiskGroup{Name: "serial", Serial: []string{"^ZA"}}, hdd, true},
+		{DiskGroup{Name: "serial", Serial: []string{"^ZA"}}, sata, false},
+	}
+
+	for _, e := range table {
+		if e.group.Match(e.disk) != e.result {
+			t.Errorf("DiskGroup(%+v).Match(%s) != %t", e.group, e.disk.Name, e.result)
+		}
+	}
+}
+
+func TestDiskGroupValidate(t *testing.T) {
+	table := []struct {
+		group DiskGroup
+		valid bool
+	}{
+		{DiskGroup{Name: "ssd", Rotational: "0"}, true},
+		{DiskGroup{Name: "Fast_Disk"}, false},
+		{DiskGroup{Name: "big", MinSize: "2Ti", MaxSize: "1Ti"}, false},
+		{DiskGroup{Name: "big", MinSize: "two"}, false},
+		{DiskGroup{Name: "bad", DiskSelector: []string{"sd[b"}}, false},
+		{DiskGroup{Name: "rota", Rotational: "true"}, false},
+	}
+
+	for _, e := range table {
+		if err := e.group.Validate(); (err == nil) != e.valid {
+			t.Errorf("DiskGroup(%+v).Validate() = %v", e.group, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import "time"
+
+// 磁盘健康状态
+const (
+	DiskHealthy = "Healthy"
+	DiskWarning = "Warning"
+	DiskFailing = "Failing"
+	DiskUnknown = "Unknown"
+)
+
+// 磁盘SMART健康信息，同时作为磁盘的健康状况(condition)对外展示
+type DiskHealth struct {
+	// 磁盘设备，分区取其所在磁盘
+	Device string `json:"device"`
+	// 磁盘上pv所在的vg卷组
+	DeviceGroups []string `json:"deviceGroups"`
+	Model        string   `json:"model"`
+	Serial       string   `json:"serial"`
+	// Healthy Warning Failing Unknown
+	Status string `json:"status"`
+	// 状态原因，如SmartFailed、MediaErrors
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	// SMART整体评估结果
+	SmartPassed bool `json:"smartPassed"`
+	// ata重映射扇区数，scsi为grown defect数
+	ReallocatedSectors uint64 `json:"reallocatedSectors"`
+	// ata待映射及离线不可修复扇区数
+	PendingSectors uint64 `json:"pendingSectors"`
+	// nvme介质错误数
+	MediaErrors uint64 `json:"mediaErrors"`
+	// nvme寿命消耗百分比
+	PercentageUsed uint64 `json:"percentageUsed"`
+	// 摄氏度
+	Temperature int64 `json:"temperature"`
+	// 最近一次检查时间及状态变化时间
+	LastCheckTime      time.Time `json:"lastCheckTime"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
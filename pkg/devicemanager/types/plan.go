@@ -0,0 +1,38 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import "time"
+
+// 磁盘变更
+type DiskChange struct {
+	Device string `json:"device"`
+	VGName string `json:"vgName"`
+	Reason string `json:"reason"`
+}
+
+// 磁盘变更计划，每次磁盘扫描生成
+type DiskPlan struct {
+	// 根据待移除的磁盘生成，审批时需要指定
+	ID     string       `json:"id"`
+	Add    []DiskChange `json:"add"`
+	Remove []DiskChange `json:"remove"`
+	// 预演模式下不执行任何变更
+	DryRun bool `json:"dryRun"`
+	// 移除磁盘是否已审批
+	Approved   bool      `json:"approved"`
+	CreateTime time.Time `json:"createTime"`
+}
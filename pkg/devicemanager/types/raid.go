@@ -0,0 +1,104 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 阵列名称前缀，完整名称为carina-<设备组>-<序号>
+const RaidNamePrefix = KEYWORD
+
+// raid阵列健康状态
+const (
+	RaidHealthy    = "Healthy"
+	RaidRebuilding = "Rebuilding"
+	RaidDegraded   = "Degraded"
+	RaidFailed     = "Failed"
+)
+
+// 各raid级别最少需要的磁盘数量
+var raidMinDevices = map[string]int{
+	"0":  2,
+	"1":  2,
+	"5":  3,
+	"6":  4,
+	"10": 4,
+}
+
+// raid阵列成员
+type RaidMember struct {
+	Device string `json:"device"`
+	// active sync, spare rebuilding, faulty等
+	State string `json:"state"`
+}
+
+// md raid阵列
+type RaidArray struct {
+	Name   string `json:"name"`
+	Device string `json:"device"`
+	VGName string `json:"vgName"`
+	Level  string `json:"level"`
+	// mdadm输出的原始状态，如clean, degraded, recovering
+	State          string       `json:"state"`
+	RaidDevices    int          `json:"raidDevices"`
+	ActiveDevices  int          `json:"activeDevices"`
+	WorkingDevices int          `json:"workingDevices"`
+	FailedDevices  int          `json:"failedDevices"`
+	SpareDevices   int          `json:"spareDevices"`
+	Rebuild        float64      `json:"rebuild"`
+	Members        []RaidMember `json:"members"`
+	UpdateTime     time.Time    `json:"updateTime"`
+}
+
+// Health 根据阵列状态评估健康状况
+func (a *RaidArray) Health() string {
+	state := strings.ToLower(a.State)
+	switch {
+	case strings.Contains(state, "failed") || strings.Contains(state, "inactive"):
+		return RaidFailed
+	case strings.Contains(state, "recovering") || strings.Contains(state, "resyncing") || strings.Contains(state, "reshaping"):
+		return RaidRebuilding
+	case strings.Contains(state, "degraded") || a.ActiveDevices < a.RaidDevices:
+		return RaidDegraded
+	}
+	return RaidHealthy
+}
+
+// RaidName 设备组中第index个阵列的名称
+func RaidName(group string, index int) string {
+	return RaidNamePrefix + group + "-" + strconv.Itoa(index)
+}
+
+// RaidGroup 根据阵列名称获取所属设备组，非carina创建的阵列返回空
+func RaidGroup(name string) string {
+	if !strings.HasPrefix(name, RaidNamePrefix) {
+		return ""
+	}
+	name = strings.TrimPrefix(name, RaidNamePrefix)
+	i := strings.LastIndex(name, "-")
+	if i <= 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// NormalizeRaidLevel 支持1以及raid1两种写法
+func NormalizeRaidLevel(level string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(level)), "raid")
+}
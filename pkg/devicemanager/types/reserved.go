@@ -0,0 +1,49 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"strconv"
+	"strings"
+)
+
+// 设备组预留容量，支持绝对值如10Gi，或者设备组总容量的百分比如5%
+type ReservedSpace string
+
+// Bytes 根据设备组总容量计算预留的字节数
+func (r ReservedSpace) Bytes(total uint64) (uint64, error) {
+	value := strings.TrimSpace(string(r))
+	if value == "" {
+		return 0, fmt.Errorf("reserved space is empty")
+	}
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, fmt.Errorf("invalid reserved space %q, percent must be in 0-100", value)
+		}
+		return uint64(float64(total) * percent / 100), nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid reserved space %q: %s", value, err.Error())
+	}
+	if q.Sign() < 0 {
+		return 0, fmt.Errorf("invalid reserved space %q, cannot be negative", value)
+	}
+	return uint64(q.Value()), nil
+}
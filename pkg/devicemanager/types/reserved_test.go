@@ -0,0 +1,51 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package types
+
+import (
+	"testing"
+)
+
+func TestReservedSpaceBytes(t *testing.T) {
+	table := []struct {
+		reserved ReservedSpace
+		total    uint64
+		result   uint64
+		err      bool
+	}{
+		{"10Gi", 1 << 40, 10 << 30, false},
+		{"0", 1 << 40, 0, false},
+		{"1073741824", 1 << 40, 1 << 30, false},
+		{"5%", 400 << 30, 20 << 30, false},
+		{" 0.5% ", 1000, 5, false},
+		{"100%", 1 << 40, 1 << 40, false},
+		{"101%", 1 << 40, 0, true},
+		{"-1Gi", 1 << 40, 0, true},
+		{"ten", 1 << 40, 0, true},
+		{"", 1 << 40, 0, true},
+	}
+
+	for _, e := range table {
+		result, err := e.reserved.Bytes(e.total)
+		if (err != nil) != e.err {
+			t.Errorf("ReservedSpace(%q).Bytes(%d) error %v", e.reserved, e.total, err)
+			continue
+		}
+		if result != e.result {
+			t.Errorf("ReservedSpace(%q).Bytes(%d) = %d, want %d", e.reserved, e.total, result, e.result)
+		}
+	}
+}
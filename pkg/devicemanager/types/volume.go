@@ -26,6 +26,13 @@ type VgGroup struct {
 	VGSize    uint64    `json:"vgSize"`
 	VGFree    uint64    `json:"vgFree"`
 	PVS       []*PVInfo `json:"pvs"`
+	// 以下字段只有lvm支持json格式报告时才有
+	VGUUID         string `json:"vgUuid,omitempty"`
+	ExtentSize     uint64 `json:"extentSize,omitempty"`
+	ExtentCount    uint64 `json:"extentCount,omitempty"`
+	FreeCount      uint64 `json:"freeCount,omitempty"`
+	MissingPVCount uint64 `json:"missingPvCount,omitempty"`
+	VGTags         string `json:"vgTags,omitempty"`
 }
 
 // pv详细信息
@@ -36,6 +43,11 @@ type PVInfo struct {
 	PVAttr string `json:"pvAttr"`
 	PVSize uint64 `json:"pvSize"`
 	PVFree uint64 `json:"pvFree"`
+	// 以下字段只有lvm支持json格式报告时才有
+	PVUUID       string `json:"pvUuid,omitempty"`
+	PECount      uint64 `json:"peCount,omitempty"`
+	PEAllocCount uint64 `json:"peAllocCount,omitempty"`
+	PVTags       string `json:"pvTags,omitempty"`
 }
 
 // lv详细信息
@@ -54,4 +66,13 @@ type LvInfo struct {
 	DataPercent   float64 `json:"dataPercent"`
 	LVAttr        string  `json:"lvAttr"`
 	LVActive      string  `json:"lvActive"`
+	// 以下字段只有lvm支持json格式报告时才有
+	MetadataPercent float64 `json:"metadataPercent,omitempty"`
+	LVUUID          string  `json:"lvUuid,omitempty"`
+	// 如partial、refresh needed、mismatches exist，为空表示正常
+	HealthStatus string `json:"healthStatus,omitempty"`
+	// 段类型，如linear、striped、thin-pool，多个段时以逗号分隔
+	SegType string `json:"segType,omitempty"`
+	// lv所在的pv
+	Devices []string `json:"devices,omitempty"`
 }
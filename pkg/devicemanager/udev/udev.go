@@ -0,0 +1,216 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package udev
+
+import (
+	"bytes"
+	"errors"
+	"github.com/carina-io/carina/utils/log"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+	ActionChange = "change"
+
+	// kernel uevent multicast group
+	kernelEventGroup = 1
+	bufferSize       = 64 * 1024
+
+	// dm-multipath设备的dm uuid前缀
+	mpathUUIDPrefix = "mpath-"
+	sysBlock        = "/sys/block"
+)
+
+// 内核uevent事件
+// add@/devices/pci0000:00/0000:00:05.0/virtio2/block/vdb\0ACTION=add\0DEVPATH=...\0SUBSYSTEM=block\0DEVNAME=vdb\0DEVTYPE=disk\0SEQNUM=2161
+type UEvent struct {
+	Action    string
+	DevPath   string
+	Subsystem string
+	DevName   string
+	DevType   string
+	Env       map[string]string
+}
+
+// 块设备事件，DevName为设备路径如/dev/sdb
+type DeviceEvent struct {
+	Action  string
+	DevName string
+}
+
+type Monitor struct {
+	fd int
+	// 已知的multipath设备，key为dm-N，value为mapper名称，移除事件发生时sysfs中已无法读取
+	mpaths map[string]string
+}
+
+// NewMonitor 监听netlink socket上的内核uevent事件，需要在host network下运行
+func NewMonitor() (*Monitor, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+	err = unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Pid:    0,
+		Groups: kernelEventGroup,
+	})
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	// 设置读超时，以便能够响应停止信号
+	tv := unix.Timeval{Sec: 1}
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	return &Monitor{fd: fd, mpaths: map[string]string{}}, nil
+}
+
+// Run 持续读取块设备事件，直到stopChan关闭
+func (m *Monitor) Run(events chan<- DeviceEvent, stopChan <-chan struct{}) {
+	defer unix.Close(m.fd)
+	buf := make([]byte, bufferSize)
+	for {
+		select {
+		case <-stopChan:
+			log.Info("stop udev monitor...")
+			return
+		default:
+		}
+
+		n, _, err := unix.Recvfrom(m.fd, buf, 0)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EWOULDBLOCK || err == unix.EINTR {
+				continue
+			}
+			log.Errorf("read uevent failed %s", err.Error())
+			continue
+		}
+		e, err := ParseUEvent(buf[:n])
+		if err != nil {
+			continue
+		}
+		m.fillMultipath(e)
+		if !isBlockDeviceEvent(e) {
+			continue
+		}
+		select {
+		case events <- DeviceEvent{Action: e.Action, DevName: deviceName(e)}:
+		case <-stopChan:
+			log.Info("stop udev monitor...")
+			return
+		}
+	}
+}
+
+// ParseUEvent 解析内核uevent消息
+func ParseUEvent(msg []byte) (*UEvent, error) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) < 2 || !bytes.Contains(fields[0], []byte("@")) {
+		return nil, errors.New("invalid uevent message")
+	}
+	e := &UEvent{Env: map[string]string{}}
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(string(f), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		e.Env[kv[0]] = kv[1]
+		switch kv[0] {
+		case "ACTION":
+			e.Action = kv[1]
+		case "DEVPATH":
+			e.DevPath = kv[1]
+		case "SUBSYSTEM":
+			e.Subsystem = kv[1]
+		case "DEVNAME":
+			e.DevName = kv[1]
+		case "DEVTYPE":
+			e.DevType = kv[1]
+		}
+	}
+	if e.Action == "" {
+		return nil, errors.New("uevent without action")
+	}
+	return e, nil
+}
+
+// 内核dm设备事件中没有DM_UUID及DM_NAME，从sysfs中补充，multipath设备移除时使用之前记录的名称
+func (m *Monitor) fillMultipath(e *UEvent) {
+	if !strings.HasPrefix(e.DevName, "dm-") {
+		return
+	}
+	if e.Action == ActionRemove {
+		if name, ok := m.mpaths[e.DevName]; ok {
+			e.Env["DM_UUID"] = mpathUUIDPrefix
+			e.Env["DM_NAME"] = name
+			delete(m.mpaths, e.DevName)
+		}
+		return
+	}
+	if _, ok := e.Env["DM_UUID"]; !ok {
+		uuid, _ := ioutil.ReadFile(filepath.Join(sysBlock, e.DevName, "dm", "uuid"))
+		e.Env["DM_UUID"] = strings.TrimSpace(string(uuid))
+	}
+	if _, ok := e.Env["DM_NAME"]; !ok {
+		name, _ := ioutil.ReadFile(filepath.Join(sysBlock, e.DevName, "dm", "name"))
+		e.Env["DM_NAME"] = strings.TrimSpace(string(name))
+	}
+	if isMultipath(e) && e.Env["DM_NAME"] != "" {
+		m.mpaths[e.DevName] = e.Env["DM_NAME"]
+	}
+}
+
+func isMultipath(e *UEvent) bool {
+	return strings.HasPrefix(e.Env["DM_UUID"], mpathUUIDPrefix)
+}
+
+// multipath设备使用/dev/mapper下的名称，与磁盘发现结果一致
+func deviceName(e *UEvent) string {
+	if isMultipath(e) && e.Env["DM_NAME"] != "" {
+		return "/dev/mapper/" + e.Env["DM_NAME"]
+	}
+	return "/dev/" + e.DevName
+}
+
+// 只关心物理块设备及multipath设备的新增、移除及变更，lvm自身创建的dm设备会产生大量事件需要忽略
+func isBlockDeviceEvent(e *UEvent) bool {
+	if e.Subsystem != "block" || e.DevName == "" {
+		return false
+	}
+	if e.DevType != "disk" && e.DevType != "partition" {
+		return false
+	}
+	if e.Action != ActionAdd && e.Action != ActionRemove && e.Action != ActionChange {
+		return false
+	}
+	if strings.HasPrefix(e.DevName, "dm-") {
+		return isMultipath(e)
+	}
+	for _, prefix := range []string{"sr", "ram", "zram", "bcache"} {
+		if strings.HasPrefix(e.DevName, prefix) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package udev
+
+import (
+	"strings"
+	"testing"
+)
+
+func uevent(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00"))
+}
+
+func TestParseUEvent(t *testing.T) {
+	table := []struct {
+		msg   []byte
+		valid bool
+		block bool
+	}{
+		{uevent("add@/devices/virtio2/block/vdb", "ACTION=add", "DEVPATH=/devices/virtio2/block/vdb", "SUBSYSTEM=block", "DEVNAME=vdb", "DEVTYPE=disk", "SEQNUM=2161"), true, true},
+		{uevent("remove@/devices/virtio2/block/vdb/vdb1", "ACTION=remove", "SUBSYSTEM=block", "DEVNAME=vdb1", "DEVTYPE=partition"), true, true},
+		{uevent("change@/devices/virtual/block/dm-0", "ACTION=change", "SUBSYSTEM=block", "DEVNAME=dm-0", "DEVTYPE=disk"), true, false},
+		{uevent("change@/devices/virtual/block/dm-1", "ACTION=change", "SUBSYSTEM=block", "DEVNAME=dm-1", "DEVTYPE=disk", "DM_UUID=LVM-abc"), true, false},
+		{uevent("add@/devices/virtual/block/dm-3", "ACTION=add", "SUBSYSTEM=block", "DEVNAME=dm-3", "DEVTYPE=disk", "DM_UUID=mpath-3600c0ff0001e", "DM_NAME=mpatha"), true, true},
+		{uevent("add@/devices/virtual/net/veth0", "ACTION=add", "SUBSYSTEM=net", "INTERFACE=veth0"), true, false},
+		{uevent("bind@/devices/virtio2", "ACTION=bind", "SUBSYSTEM=block", "DEVNAME=vdc", "DEVTYPE=disk"), true, false},
+		{uevent("libudev", "ACTION=add"), false, false},
+		{uevent("add@/devices/virtio2/block/vdb"), false, false},
+	}
+
+	for _, e := range table {
+		ev, err := ParseUEvent(e.msg)
+		if (err == nil) != e.valid {
+			t.Errorf("ParseUEvent(%q) error %v", e.msg, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if isBlockDeviceEvent(ev) != e.block {
+			t.Errorf("isBlockDeviceEvent(%+v) != %t", ev, e.block)
+		}
+	}
+}
+
+func TestMultipathEvent(t *testing.T) {
+	m := &Monitor{mpaths: map[string]string{}}
+	add, _ := ParseUEvent(uevent("add@/devices/virtual/block/dm-3", "ACTION=add", "SUBSYSTEM=block", "DEVNAME=dm-3", "DEVTYPE=disk", "DM_UUID=mpath-3600c0ff0001e", "DM_NAME=mpatha"))
+	m.fillMultipath(add)
+	if !isBlockDeviceEvent(add) || deviceName(add) != "/dev/mapper/mpatha" {
+		t.Errorf("multipath add event %+v", add)
+	}
+	// 移除时sysfs中已没有dm信息
+	remove, _ := ParseUEvent(uevent("remove@/devices/virtual/block/dm-3", "ACTION=remove", "SUBSYSTEM=block", "DEVNAME=dm-3", "DEVTYPE=disk"))
+	m.fillMultipath(remove)
+	if !isBlockDeviceEvent(remove) || deviceName(remove) != "/dev/mapper/mpatha" {
+		t.Errorf("multipath remove event %+v", remove)
+	}
+	if len(m.mpaths) != 0 {
+		t.Errorf("multipath device not forgotten %v", m.mpaths)
+	}
+}
@@ -23,14 +23,17 @@ const (
 	THIN     = "thin-"
 	SNAP     = "snap-"
 	LVVolume = "volume-"
+	// 以该名称注册的通知服务接收所有vg组的容量变更
+	NoticeAllGroup = "*"
 )
 
 // 本接口负责对外提供方法
 // 处理业务逻辑并调用lvm接口
 type LocalVolume interface {
-	CreateVolume(lvName, vgName string, size, ratio uint64) error
+	// apart为需要分散到不同磁盘的卷，同一磁盘故障不会同时影响这些卷
+	CreateVolume(lvName, vgName string, size, ratio uint64, apart []string) error
 	DeleteVolume(lvName, vgName string) error
-	ResizeVolume(lvName, vgName string, size, ratio uint64) error
+	ResizeVolume(lvName, vgName string, size, ratio uint64, apart []string) error
 	VolumeList(lvName, vgName string) ([]types.LvInfo, error)
 	VolumeInfo(lvName, vgName string) (*types.LvInfo, error)
 
@@ -44,6 +47,8 @@ type LocalVolume interface {
 	// 额外的方法
 	GetCurrentVgStruct() ([]types.VgGroup, error)
 	GetCurrentPvStruct() ([]types.PVInfo, error)
+	// vg卷组中没有apart卷且剩余容量足够size的pv
+	ApartPvs(vgName string, size uint64, apart []string) ([]string, error)
 	AddNewDiskToVg(disk, vgName string) error
 	RemoveDiskInVg(disk, vgName string) error
 
@@ -51,8 +56,11 @@ type LocalVolume interface {
 	RefreshLvmCache()
 	// For Device Plugin
 	NoticeUpdateCapacity(vgName []string)
-	// 注册通知服务，因为多个vg组，每个组需要不同的channel
+	// 注册通知服务，因为多个vg组，每个组需要不同的channel，channel为nil时注销
 	RegisterNoticeServer(vgName string, notice chan struct{})
+	// 设备组降级后不再分配新卷，reason为空时取消降级
+	SetDeviceGroupDegraded(vgName, reason string)
+	DeviceGroupDegraded(vgName string) (string, bool)
 
 	// bcache
 	CreateBcache(dev, cacheDev string, block, bucket string, cacheMode string) (*types.BcacheDeviceInfo, error)
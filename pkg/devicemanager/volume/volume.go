@@ -19,31 +19,39 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/carina-io/carina/pkg/configuration"
 	"github.com/carina-io/carina/pkg/devicemanager/bcache"
 	"github.com/carina-io/carina/pkg/devicemanager/lvmd"
 	"github.com/carina-io/carina/pkg/devicemanager/types"
 	"github.com/carina-io/carina/utils"
 	"github.com/carina-io/carina/utils/log"
 	"github.com/carina-io/carina/utils/mutx"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 const VOLUMEMUTEX = "VolumeMutex"
 
+// 其他任务持有锁时返回，调用方稍后重试
+var errMutexBusy = lvmd.NewError(lvmd.ErrLocked, "get global mutex failed")
+
 type LocalVolumeImplement struct {
 	Lv              lvmd.Lvm2
 	Bcache          bcache.Bcache
 	Mutex           *mutx.GlobalLocks
 	NoticeServerMap map[string]chan struct{}
+	// device plugin随设备组变化注册和注销通知，与发送通知并发
+	noticeLock sync.RWMutex
+	// 降级的设备组及原因
+	degradedGroup sync.Map
 }
 
-func (v *LocalVolumeImplement) CreateVolume(lvName, vgName string, size, ratio uint64) error {
+func (v *LocalVolumeImplement) CreateVolume(lvName, vgName string, size, ratio uint64, apart []string) error {
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 
@@ -54,12 +62,13 @@ func (v *LocalVolumeImplement) CreateVolume(lvName, vgName string, size, ratio u
 	}
 	if vgInfo == nil {
 		log.Error("cannot find device group info")
-		return errors.New("cannot find device group info")
+		return lvmd.NewError(lvmd.ErrNotFound, "cannot find device group %s info", vgName)
 	}
 
-	if vgInfo.VGFree-size < utils.DefaultReservedSpace/2 {
-		log.Warnf("%s don't have enough space, reserved 10 g", vgName)
-		return errors.New("don't have enough space")
+	reserved := configuration.ReservedSpace(vgName, vgInfo.VGSize)
+	if size+reserved > vgInfo.VGFree {
+		log.Warnf("%s don't have enough space, free %d, reserved %d", vgName, vgInfo.VGFree, reserved)
+		return lvmd.NewError(lvmd.ErrNoSpace, "%s don't have enough space", vgName)
 	}
 
 	thinName := THIN + lvName
@@ -75,8 +84,18 @@ func (v *LocalVolumeImplement) CreateVolume(lvName, vgName string, size, ratio u
 
 	thinInfo, _ := v.Lv.LVDisplay(thinName, vgName)
 	if thinInfo == nil {
+		// 与同一反亲和组的卷分散到不同的磁盘
+		pvs := []string{}
+		if len(apart) > 0 {
+			pvs, err = v.ApartPvs(vgName, sizePool, apart)
+			if err != nil {
+				log.Warnf("select disk for %s failed %s", lvName, err.Error())
+				return err
+			}
+			log.Infof("create thin pool %s on %s apart from %s", thinName, strings.Join(pvs, ","), strings.Join(apart, ","))
+		}
 		// 首先创建thin pool
-		if err := v.Lv.CreateThinPool(thinName, vgName, sizePool); err != nil {
+		if err := v.Lv.CreateThinPool(thinName, vgName, sizePool, pvs...); err != nil {
 			log.Errorf("create thin pool failed %s", err.Error())
 			return err
 		}
@@ -90,10 +109,51 @@ func (v *LocalVolumeImplement) CreateVolume(lvName, vgName string, size, ratio u
 	return nil
 }
 
+// vg卷组中没有apart卷的pv，不接受分配的pv(如下线中的磁盘)除外，剩余容量不足时返回ErrNoSpace
+func (v *LocalVolumeImplement) ApartPvs(vgName string, size uint64, apart []string) ([]string, error) {
+	segments, err := v.Lv.PVSegments(vgName)
+	if err != nil {
+		return nil, err
+	}
+	pvs, err := v.Lv.PVS()
+	if err != nil {
+		return nil, err
+	}
+	pools := map[string]bool{}
+	for _, name := range apart {
+		pools[THIN+name] = true
+	}
+
+	result := []string{}
+	free := uint64(0)
+	for _, pv := range pvs {
+		if pv.VGName != vgName || !strings.HasPrefix(pv.PVAttr, "a") {
+			continue
+		}
+		used := false
+		for _, lv := range segments[pv.PVName] {
+			// thin pool的空间分配在隐藏的数据卷与元数据卷上
+			if pools[strings.TrimSuffix(strings.TrimSuffix(lv, "_tdata"), "_tmeta")] {
+				used = true
+				break
+			}
+		}
+		if !used {
+			result = append(result, pv.PVName)
+			free += pv.PVFree
+		}
+	}
+	if free < size {
+		return nil, lvmd.NewError(lvmd.ErrNoSpace, "%s don't have enough space on disks apart from volumes %s", vgName, strings.Join(apart, ","))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
 func (v *LocalVolumeImplement) DeleteVolume(lvName, vgName string) error {
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 	// ToDO: 需要检查pool中是否有快照,存在快照无法删除Volume
@@ -104,7 +164,7 @@ func (v *LocalVolumeImplement) DeleteVolume(lvName, vgName string) error {
 	}
 
 	lvInfo, err := v.Lv.LVDisplay(name, vgName)
-	if err != nil && strings.Contains(err.Error(), "not found") {
+	if errors.Is(err, lvmd.ErrNotFound) {
 		log.Warnf("volume %s/%s not exist", vgName, lvName)
 		return nil
 	}
@@ -126,10 +186,10 @@ func (v *LocalVolumeImplement) DeleteVolume(lvName, vgName string) error {
 	return nil
 }
 
-func (v *LocalVolumeImplement) ResizeVolume(lvName, vgName string, size, ratio uint64) error {
+func (v *LocalVolumeImplement) ResizeVolume(lvName, vgName string, size, ratio uint64, apart []string) error {
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 
@@ -141,7 +201,7 @@ func (v *LocalVolumeImplement) ResizeVolume(lvName, vgName string, size, ratio u
 	}
 	if vgInfo == nil {
 		log.Error("cannot find device group info")
-		return errors.New("cannot find device group info")
+		return lvmd.NewError(lvmd.ErrNotFound, "cannot find device group %s info", vgName)
 	}
 
 	name := LVVolume + lvName
@@ -149,11 +209,11 @@ func (v *LocalVolumeImplement) ResizeVolume(lvName, vgName string, size, ratio u
 	lvInfo, err := v.Lv.LVDisplay(name, vgName)
 	if err != nil {
 		log.Errorf("get volume info failed %s/%s %s", vgName, name, err.Error())
-		return nil
+		return err
 	}
 	if lvInfo == nil {
 		log.Infof("%s/%s volume don't exists", vgName, name)
-		return errors.New("volume don't exists")
+		return lvmd.NewError(lvmd.ErrNotFound, "volume %s/%s don't exists", vgName, name)
 	}
 
 	if lvInfo.LVSize == size {
@@ -161,9 +221,10 @@ func (v *LocalVolumeImplement) ResizeVolume(lvName, vgName string, size, ratio u
 		return nil
 	}
 
-	if vgInfo.VGFree-(size-lvInfo.LVSize) < utils.DefaultReservedSpace/2 {
-		log.Warnf("%s don't have enough space, reserved 10 g", vgName)
-		return errors.New("don't have enough space")
+	reserved := configuration.ReservedSpace(vgName, vgInfo.VGSize)
+	if size > lvInfo.LVSize && size-lvInfo.LVSize+reserved > vgInfo.VGFree {
+		log.Warnf("%s don't have enough space, free %d, reserved %d", vgName, vgInfo.VGFree, reserved)
+		return lvmd.NewError(lvmd.ErrNoSpace, "%s don't have enough space", vgName)
 	}
 
 	// 执行扩容
@@ -176,7 +237,17 @@ func (v *LocalVolumeImplement) ResizeVolume(lvName, vgName string, size, ratio u
 	}
 
 	if thinInfo.LVSize < size {
-		if err := v.Lv.ResizeThinPool(thinName, vgName, sizePool); err != nil {
+		// 扩容的空间同样避开同一反亲和组的卷所在的磁盘
+		pvs := []string{}
+		if len(apart) > 0 {
+			pvs, err = v.ApartPvs(vgName, sizePool-thinInfo.LVSize, apart)
+			if err != nil {
+				log.Warnf("select disk for %s failed %s", lvName, err.Error())
+				return err
+			}
+			log.Infof("resize thin pool %s on %s apart from %s", thinName, strings.Join(pvs, ","), strings.Join(apart, ","))
+		}
+		if err := v.Lv.ResizeThinPool(thinName, vgName, sizePool, pvs...); err != nil {
 			return err
 		}
 	}
@@ -199,7 +270,7 @@ func (v *LocalVolumeImplement) VolumeList(lvName, vgName string) ([]types.LvInfo
 func (v *LocalVolumeImplement) VolumeInfo(lvName, vgName string) (*types.LvInfo, error) {
 	lvs, err := v.VolumeList(lvName, vgName)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list lv :%v", err)
+		return nil, fmt.Errorf("failed to list lv :%w", err)
 	}
 
 	for _, v := range lvs {
@@ -208,14 +279,14 @@ func (v *LocalVolumeImplement) VolumeInfo(lvName, vgName string) (*types.LvInfo,
 		}
 	}
 
-	return nil, errors.New("not found")
+	return nil, lvmd.NewError(lvmd.ErrNotFound, "volume %s/%s not found", vgName, lvName)
 }
 
 func (v *LocalVolumeImplement) CreateSnapshot(snapName, lvName, vgName string) error {
 
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 
@@ -233,11 +304,11 @@ func (v *LocalVolumeImplement) DeleteSnapshot(snapName, vgName string) error {
 
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
-	if err := v.Lv.DeleteSnapshot(snapName, vgName); err != nil {
-		return nil
+	if err := v.Lv.DeleteSnapshot(snapName, vgName); err != nil && !errors.Is(err, lvmd.ErrNotFound) {
+		return err
 	}
 	return nil
 }
@@ -246,7 +317,7 @@ func (v *LocalVolumeImplement) RestoreSnapshot(snapName, vgName string) error {
 
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 	// TODO： 需要检查是否已经umount
@@ -274,7 +345,7 @@ func (v *LocalVolumeImplement) SnapshotList(lvName, vgName string) ([]types.LvIn
 func (v *LocalVolumeImplement) CloneVolume(lvName, vgName, newLvName string) error {
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 	// 获取pool lv，创建一个和一模一样对池子
@@ -344,12 +415,12 @@ func (v *LocalVolumeImplement) GetCurrentPvStruct() ([]types.PVInfo, error) {
 func (v *LocalVolumeImplement) AddNewDiskToVg(disk, vgName string) error {
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 	// 确保PV存在
 	pvInfo, err := v.Lv.PVDisplay(disk)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
+	if err != nil && !errors.Is(err, lvmd.ErrNotFound) {
 		log.Infof("get pv detail failed %s", err.Error())
 		return err
 	}
@@ -367,7 +438,7 @@ func (v *LocalVolumeImplement) AddNewDiskToVg(disk, vgName string) error {
 	}
 	// 检查PV,决定新创建还是扩容
 	vgInfo, err := v.Lv.VGDisplay(vgName)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
+	if err != nil && !errors.Is(err, lvmd.ErrNotFound) {
 		log.Errorf("get vg detail failed %s", err.Error())
 		return err
 	}
@@ -390,13 +461,13 @@ func (v *LocalVolumeImplement) AddNewDiskToVg(disk, vgName string) error {
 func (v *LocalVolumeImplement) RemoveDiskInVg(disk, vgName string) error {
 	if !v.Mutex.TryAcquire(VOLUMEMUTEX) {
 		log.Info("wait other task release mutex, please retry...")
-		return errors.New("get global mutex failed")
+		return errMutexBusy
 	}
 	defer v.Mutex.Release(VOLUMEMUTEX)
 
 	// 确保PV存在
 	pvInfo, err := v.Lv.PVDisplay(disk)
-	if err != nil {
+	if err != nil && !errors.Is(err, lvmd.ErrNotFound) {
 		log.Infof("get pv %s detail failed %s", disk, err.Error())
 		return err
 	}
@@ -425,7 +496,7 @@ func (v *LocalVolumeImplement) RemoveDiskInVg(disk, vgName string) error {
 	}
 	if vgInfo == nil {
 		log.Errorf("vg %s not found", vgName)
-		return errors.New("not found")
+		return lvmd.NewError(lvmd.ErrNotFound, "vg %s not found", vgName)
 	} else {
 		// 当vg卷下只有一个pv时，需要检查是否还存在lv
 		if vgInfo.PVCount == 1 {
@@ -444,10 +515,10 @@ func (v *LocalVolumeImplement) RemoveDiskInVg(disk, vgName string) error {
 				return err
 			}
 		} else {
-			// 移除该Pv,剩余空间不足，则不允许移除
-			if vgInfo.VGSize-vgInfo.VGFree > pvInfo.PVSize {
-				log.Warnf("cannot remove the disk %s because there will not enough space", disk)
-				return errors.New("not enough space")
+			// pv上仍有已分配的空间，需要先迁移数据
+			if pvInfo.PVSize > pvInfo.PVFree {
+				log.Warnf("cannot remove the disk %s because there are still allocated extents, decommission it first", disk)
+				return errors.New("pv still has allocated extents")
 			}
 
 			err = v.Lv.VGReduce(vgName, disk)
@@ -475,8 +546,17 @@ func (v *LocalVolumeImplement) HealthCheck() {
 		case <-ctx.Done():
 			log.Info("volume health check timeout.")
 		default:
-			_ = v.Lv.RemoveUnknownDevice(utils.DeviceVGHDD)
-			_ = v.Lv.RemoveUnknownDevice(utils.DeviceVGSSD)
+			vgs, err := v.Lv.VGS()
+			if err != nil {
+				log.Warnf("volume health check get vg failed %s", err.Error())
+				return
+			}
+			for _, vg := range vgs {
+				if !strings.HasPrefix(vg.VGName, types.KEYWORD) {
+					continue
+				}
+				_ = v.Lv.RemoveUnknownDevice(vg.VGName)
+			}
 			return
 		}
 	}
@@ -508,8 +588,15 @@ func (v *LocalVolumeImplement) NoticeUpdateCapacity(vgName []string) {
 				log.Errorf("send notice server %s panic", strings.Join(vgName, " "))
 			}
 		}()
+		// 发送可能阻塞，复制后发送避免长时间持有锁
+		v.noticeLock.RLock()
+		servers := make(map[string]chan struct{}, len(v.NoticeServerMap))
 		for k, c := range v.NoticeServerMap {
-			if len(vgName) == 0 {
+			servers[k] = c
+		}
+		v.noticeLock.RUnlock()
+		for k, c := range servers {
+			if len(vgName) == 0 || k == NoticeAllGroup {
 				c <- struct{}{}
 			} else if utils.ContainsString(vgName, k) {
 				c <- struct{}{}
@@ -528,9 +615,41 @@ func (v *LocalVolumeImplement) NoticeUpdateCapacity(vgName []string) {
 }
 
 func (v *LocalVolumeImplement) RegisterNoticeServer(vgName string, notice chan struct{}) {
+	v.noticeLock.Lock()
+	defer v.noticeLock.Unlock()
+	if notice == nil {
+		delete(v.NoticeServerMap, vgName)
+		return
+	}
 	v.NoticeServerMap[vgName] = notice
 }
 
+func (v *LocalVolumeImplement) SetDeviceGroupDegraded(vgName, reason string) {
+	old, degraded := v.DeviceGroupDegraded(vgName)
+	if reason == "" {
+		if !degraded {
+			return
+		}
+		v.degradedGroup.Delete(vgName)
+		log.Infof("device group %s recovered", vgName)
+	} else {
+		if degraded && old == reason {
+			return
+		}
+		v.degradedGroup.Store(vgName, reason)
+		log.Warnf("device group %s degraded: %s", vgName, reason)
+	}
+	v.NoticeUpdateCapacity([]string{vgName})
+}
+
+func (v *LocalVolumeImplement) DeviceGroupDegraded(vgName string) (string, bool) {
+	reason, ok := v.degradedGroup.Load(vgName)
+	if !ok {
+		return "", false
+	}
+	return reason.(string), true
+}
+
 // bcache
 func (v *LocalVolumeImplement) CreateBcache(dev, cacheDev string, block, bucket string, cachePolicy string) (*types.BcacheDeviceInfo, error) {
 	err := v.Bcache.CreateBcache(dev, cacheDev, block, bucket)
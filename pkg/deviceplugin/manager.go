@@ -16,6 +16,7 @@
 package deviceplugin
 
 import (
+	"github.com/carina-io/carina/pkg/configuration"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
 	"github.com/carina-io/carina/pkg/deviceplugin/v1beta1"
 	"github.com/carina-io/carina/utils"
@@ -35,14 +36,24 @@ func Run(volumeManager volume.LocalVolume, stopChan <-chan struct{}) {
 	}
 	defer watcher.Close()
 
+	// 磁盘分组变更需要重新注册设备插件
+	configModifyChan := make(chan struct{}, 1)
+	configuration.RegisterListenerChan(configModifyChan)
+
 	plugins := []*CarinaDevicePlugin{}
+	deviceGroups := []string{}
 restart:
 	for _, p := range plugins {
 		_ = p.Stop()
 	}
+	for _, d := range deviceGroups {
+		volumeManager.RegisterNoticeServer(d, nil)
+	}
+	plugins = []*CarinaDevicePlugin{}
 
 	log.Info("Retreiving plugins.")
-	for _, d := range []string{utils.DeviceVGSSD, utils.DeviceVGHDD} {
+	deviceGroups = getDeviceGroups(volumeManager)
+	for _, d := range deviceGroups {
 		c := make(chan struct{}, 5)
 		plugins = append(plugins, NewCarinaDevicePlugin(
 			utils.DeviceCapacityKeyPrefix+d,
@@ -91,6 +102,12 @@ events:
 		case err := <-watcher.Errors:
 			log.Infof("inotify: %s", err)
 
+		case <-configModifyChan:
+			if !utils.SliceEqualSlice(deviceGroups, getDeviceGroups(volumeManager)) {
+				log.Info("device group changed, restarting.")
+				goto restart
+			}
+
 		case <-stopChan:
 			for _, p := range plugins {
 				_ = p.stop
@@ -99,3 +116,22 @@ events:
 		}
 	}
 }
+
+// 设备插件资源包括配置中的磁盘分组以及节点上已经存在的vg卷组
+func getDeviceGroups(volumeManager volume.LocalVolume) []string {
+	deviceGroups := []string{}
+	for _, g := range configuration.DiskGroups() {
+		deviceGroups = append(deviceGroups, g.VGName())
+	}
+	vgs, err := volumeManager.GetCurrentVgStruct()
+	if err != nil {
+		log.Warnf("get current vg struct failed %s", err.Error())
+		return deviceGroups
+	}
+	for _, vg := range vgs {
+		if !utils.ContainsString(deviceGroups, vg.VGName) {
+			deviceGroups = append(deviceGroups, vg.VGName)
+		}
+	}
+	return deviceGroups
+}
@@ -17,10 +17,10 @@ package deviceplugin
 
 import (
 	"fmt"
+	"github.com/carina-io/carina/pkg/configuration"
 	"github.com/carina-io/carina/pkg/devicemanager/types"
 	"github.com/carina-io/carina/pkg/devicemanager/volume"
 	"github.com/carina-io/carina/pkg/deviceplugin/v1beta1"
-	"github.com/carina-io/carina/utils"
 	"github.com/carina-io/carina/utils/log"
 	"net"
 	"os"
@@ -283,8 +283,14 @@ func (dp *CarinaDevicePlugin) getDeviceCapacity() ([]*v1beta1.Device, error) {
 
 	sizeGb := capacity.VGSize>>30 + 1
 	freeGb := uint64(0)
-	if capacity.VGFree > utils.DefaultReservedSpace {
-		freeGb = (capacity.VGFree - utils.DefaultReservedSpace) >> 30
+	reserved := configuration.ReservedSpace(capacity.VGName, capacity.VGSize)
+	if capacity.VGFree > reserved {
+		freeGb = (capacity.VGFree - reserved) >> 30
+	}
+	// 设备组降级后不再分配新卷，可用容量上报为0
+	if reason, ok := dp.volumeManager.DeviceGroupDegraded(capacity.VGName); ok {
+		log.Warnf("device group %s degraded %s, report no allocatable capacity", capacity.VGName, reason)
+		freeGb = 0
 	}
 
 	// Capacity 这个是设备总资源数
@@ -0,0 +1,68 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package main
+
+import (
+	"flag"
+	"github.com/carina-io/carina/scheduler/extender"
+	"github.com/carina-io/carina/scheduler/schedulerplugin/localstorage"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"net/http"
+	"os"
+)
+
+// 以调度器扩展的方式运行，配合默认kube-scheduler使用
+func main() {
+	var kubeconfig, address string
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to kubeconfig file, in-cluster config is used if empty")
+	flag.StringVar(&address, "address", ":8888", "Address the scheduler extender http server listens on")
+	klog.InitFlags(nil)
+	flag.Parse()
+	defer klog.Flush()
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		klog.Errorf("build kube config failed: %v", err)
+		os.Exit(1)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		klog.Errorf("create kube client failed: %v", err)
+		os.Exit(1)
+	}
+
+	// listers需要在informer启动前获取
+	factory := informers.NewSharedInformerFactory(client, 0)
+	ls := localstorage.NewExtender(factory, config)
+	e := extender.New(ls, factory.Core().V1().Nodes().Lister())
+	factory.Start(wait.NeverStop)
+	for informer, synced := range factory.WaitForCacheSync(wait.NeverStop) {
+		if !synced {
+			klog.Errorf("wait for %v cache sync failed", informer)
+			os.Exit(1)
+		}
+	}
+
+	klog.Infof("carina scheduler extender listening on %s", address)
+	if err := http.ListenAndServe(address, e.Handler()); err != nil {
+		klog.Errorf("scheduler extender http server exit: %v", err)
+		os.Exit(1)
+	}
+}
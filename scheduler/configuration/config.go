@@ -21,6 +21,7 @@ import (
 	"os"
 	"github.com/carina-io/carina/scheduler/utils"
 	"strings"
+	"time"
 )
 
 // 配置文件路径
@@ -28,6 +29,9 @@ const (
 	configPath        = "/etc/carina/"
 	SchedulerBinpack  = "binpack"
 	SchedulerSpradout = "spradout"
+	// 节点容量来源
+	CapacityAllocatable = "allocatable"
+	CapacityNodeStorage = "nodestorage"
 )
 
 var GlobalConfig *viper.Viper
@@ -65,4 +69,23 @@ func SchedulerStrategy() string {
 		schedulerStrategy = SchedulerBinpack
 	}
 	return schedulerStrategy
-}
\ No newline at end of file
+}
+
+// 调度预留容量的超时时间(秒)，超时未绑定的预留将被释放，默认300s
+func ReservationTimeout() time.Duration {
+	timeout := GlobalConfig.GetInt64("schedulerReservationTimeout")
+	if timeout <= 0 {
+		timeout = 300
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// 节点容量来源allocatable/nodestorage，默认为allocatable
+// allocatable使用device plugin上报的节点资源，nodestorage使用carina-node上报的设备组容量及LogicVolume
+func CapacitySource() string {
+	source := strings.ToLower(GlobalConfig.GetString("schedulerCapacitySource"))
+	if source == CapacityNodeStorage {
+		return source
+	}
+	return CapacityAllocatable
+}
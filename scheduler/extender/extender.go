@@ -0,0 +1,176 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package extender
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/carina-io/carina/scheduler/schedulerplugin/localstorage"
+	v1 "k8s.io/api/core/v1"
+	lcorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"net/http"
+)
+
+// 以调度器扩展(extender)的方式提供local-storage插件的过滤和评分，与默认kube-scheduler一起部署
+// 只实现filter和prioritize，不负责bind
+type Extender struct {
+	ls *localstorage.LocalStorage
+	// nodeCacheCapable为true时kube-scheduler只传递节点名称
+	nodeLister lcorev1.NodeLister
+}
+
+func New(ls *localstorage.LocalStorage, nodeLister lcorev1.NodeLister) *Extender {
+	return &Extender{
+		ls:         ls,
+		nodeLister: nodeLister,
+	}
+}
+
+// 注册filter、prioritize、监控指标及健康检查接口
+func (e *Extender) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/filter", e.serveFilter)
+	mux.HandleFunc("/prioritize", e.servePrioritize)
+	mux.Handle("/metrics", legacyregistry.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+func (e *Extender) serveFilter(w http.ResponseWriter, r *http.Request) {
+	args, err := decodeArgs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeResult(w, e.Filter(args))
+}
+
+func (e *Extender) servePrioritize(w http.ResponseWriter, r *http.Request) {
+	args, err := decodeArgs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	priorities, err := e.Prioritize(args)
+	if err != nil {
+		// prioritize接口没有错误字段，返回错误时kube-scheduler忽略本扩展的评分
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResult(w, priorities)
+}
+
+// 过滤不满足pod存储请求的节点，返回的节点形式与请求一致
+func (e *Extender) Filter(args *extenderv1.ExtenderArgs) *extenderv1.ExtenderFilterResult {
+	nodes, failedNodes, err := e.nodes(args)
+	if err != nil {
+		return &extenderv1.ExtenderFilterResult{Error: err.Error()}
+	}
+	filtered, failed, err := e.ls.FilterNodes(args.Pod, nodes)
+	if err != nil {
+		return &extenderv1.ExtenderFilterResult{Error: err.Error()}
+	}
+	for name, reason := range failed {
+		failedNodes[name] = reason
+	}
+
+	result := &extenderv1.ExtenderFilterResult{FailedNodes: failedNodes}
+	if args.NodeNames != nil {
+		names := []string{}
+		for _, node := range filtered {
+			names = append(names, node.Name)
+		}
+		result.NodeNames = &names
+		return result
+	}
+	result.Nodes = &v1.NodeList{}
+	for _, node := range filtered {
+		result.Nodes.Items = append(result.Nodes.Items, *node)
+	}
+	return result
+}
+
+// 对节点打分，插件的0-100分映射到扩展的0-10分
+func (e *Extender) Prioritize(args *extenderv1.ExtenderArgs) (*extenderv1.HostPriorityList, error) {
+	nodes, _, err := e.nodes(args)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := e.ls.ScoreNodes(args.Pod, nodes)
+	if err != nil {
+		return nil, err
+	}
+	priorities := make(extenderv1.HostPriorityList, 0, len(scores))
+	for _, score := range scores {
+		priorities = append(priorities, extenderv1.HostPriority{
+			Host:  score.Name,
+			Score: score.Score * extenderv1.MaxExtenderPriority / framework.MaxNodeScore,
+		})
+	}
+	return &priorities, nil
+}
+
+// 请求中的节点列表，只有节点名称时从缓存中读取，缓存中不存在的节点直接过滤
+func (e *Extender) nodes(args *extenderv1.ExtenderArgs) ([]*v1.Node, extenderv1.FailedNodesMap, error) {
+	if args.Pod == nil {
+		return nil, nil, fmt.Errorf("pod is empty")
+	}
+	nodes := []*v1.Node{}
+	failedNodes := extenderv1.FailedNodesMap{}
+	if args.Nodes != nil {
+		for i := range args.Nodes.Items {
+			nodes = append(nodes, &args.Nodes.Items[i])
+		}
+		return nodes, failedNodes, nil
+	}
+	if args.NodeNames == nil {
+		return nil, nil, fmt.Errorf("nodes and nodeNames are both empty")
+	}
+	for _, name := range *args.NodeNames {
+		node, err := e.nodeLister.Get(name)
+		if err != nil {
+			klog.V(3).Infof("get node %s failed: %v", name, err)
+			failedNodes[name] = "node not found in carina scheduler extender cache"
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, failedNodes, nil
+}
+
+func decodeArgs(r *http.Request) (*extenderv1.ExtenderArgs, error) {
+	if r.Method != http.MethodPost {
+		return nil, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	args := &extenderv1.ExtenderArgs{}
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		return nil, fmt.Errorf("decode extender args failed: %v", err)
+	}
+	return args, nil
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		klog.Errorf("encode extender result failed: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package extender
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/carina-io/carina/scheduler/schedulerplugin/localstorage"
+	"github.com/carina-io/carina/scheduler/utils"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestNode(name, hdd string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			v1.ResourceName(utils.DeviceCapacityKeyPrefix + "carina-vg-hdd"): resource.MustParse(hdd),
+		}},
+	}
+}
+
+// 不启动informer，直接向缓存中添加对象
+func newTestExtender(nodes ...*v1.Node) *Extender {
+	sc := "csi-carina-hdd"
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	_ = factory.Storage().V1().StorageClasses().Informer().GetIndexer().Add(&storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: sc},
+		Provisioner: utils.CSIPluginName,
+		Parameters:  map[string]string{utils.DeviceDiskKey: "hdd", utils.PlacementStrategyKey: "spradout"},
+	})
+	_ = factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().Add(&v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1", Namespace: "default"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	})
+	for _, node := range nodes {
+		_ = factory.Core().V1().Nodes().Informer().GetIndexer().Add(node)
+	}
+	return New(localstorage.NewExtender(factory, nil), factory.Core().V1().Nodes().Lister())
+}
+
+func newTestPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "pod-uid"},
+		Spec: v1.PodSpec{Volumes: []v1.Volume{{
+			Name:         "data",
+			VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-1"}},
+		}}},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	a := assert.New(t)
+	e := newTestExtender(newTestNode("node-1", "40"), newTestNode("node-2", "5"))
+
+	nodes := &v1.NodeList{Items: []v1.Node{*newTestNode("node-1", "40"), *newTestNode("node-2", "5")}}
+	result := e.Filter(&extenderv1.ExtenderArgs{Pod: newTestPod(), Nodes: nodes})
+	a.Equal("", result.Error)
+	a.Len(result.Nodes.Items, 1)
+	a.Equal("node-1", result.Nodes.Items[0].Name)
+	a.Equal(extenderv1.FailedNodesMap{"node-2": "node(s) had insufficient carina-vg-hdd, requested 10Gi, available 5Gi"}, result.FailedNodes)
+
+	// nodeCacheCapable时只传递节点名称
+	names := []string{"node-1", "node-2", "node-3"}
+	result = e.Filter(&extenderv1.ExtenderArgs{Pod: newTestPod(), NodeNames: &names})
+	a.Equal([]string{"node-1"}, *result.NodeNames)
+	a.Len(result.FailedNodes, 2)
+
+	result = e.Filter(&extenderv1.ExtenderArgs{Pod: newTestPod()})
+	a.True(result.Error != "")
+}
+
+func TestPrioritize(t *testing.T) {
+	a := assert.New(t)
+	e := newTestExtender()
+	nodes := &v1.NodeList{Items: []v1.Node{*newTestNode("node-1", "40"), *newTestNode("node-2", "200")}}
+	priorities, err := e.Prioritize(&extenderv1.ExtenderArgs{Pod: newTestPod(), Nodes: nodes})
+	a.NoError(err)
+	// spradout策略，剩余容量多的节点得分高，并映射到0-10
+	a.Equal(extenderv1.HostPriorityList{{Host: "node-1", Score: 4}, {Host: "node-2", Score: 10}}, *priorities)
+}
+
+func TestHandler(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(newTestExtender().Handler())
+	defer server.Close()
+
+	nodes := &v1.NodeList{Items: []v1.Node{*newTestNode("node-1", "40")}}
+	body, _ := json.Marshal(&extenderv1.ExtenderArgs{Pod: newTestPod(), Nodes: nodes})
+	resp, err := http.Post(server.URL+"/filter", "application/json", bytes.NewReader(body))
+	a.NoError(err)
+	defer resp.Body.Close()
+	a.Equal(http.StatusOK, resp.StatusCode)
+	result := &extenderv1.ExtenderFilterResult{}
+	a.NoError(json.NewDecoder(resp.Body).Decode(result))
+	a.Len(result.Nodes.Items, 1)
+
+	resp, err = http.Get(server.URL + "/prioritize")
+	a.NoError(err)
+	defer resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+}
@@ -14,6 +14,7 @@ require (
 	k8s.io/client-go v0.20.4
 	k8s.io/component-base v0.20.4
 	k8s.io/klog/v2 v2.5.0
+	k8s.io/kube-scheduler v0.20.4
 	k8s.io/kubernetes v0.0.0-00010101000000-000000000000
 )
 
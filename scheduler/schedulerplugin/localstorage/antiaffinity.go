@@ -0,0 +1,82 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"github.com/carina-io/carina/scheduler/utils"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+	"strings"
+)
+
+// storageclass中的卷反亲和级别，无效的值由carina-controller创建卷时报错，调度时忽略
+func antiAffinityLevel(sc *storagev1.StorageClass) string {
+	level := strings.ToLower(sc.Parameters[utils.VolumeAntiAffinityKey])
+	if level == utils.AntiAffinityNode || level == utils.AntiAffinityDisk {
+		return level
+	}
+	return ""
+}
+
+// pvc所属的反亲和组，与carina-controller保持一致
+// 优先使用pvc标签，否则StatefulSet的pvc(<模板名>-<StatefulSet名>-<序号>)以去掉序号后的名称为组
+func antiAffinityGroup(pvc *v1.PersistentVolumeClaim) string {
+	if group := pvc.Labels[utils.AntiAffinityGroupKey]; group != "" {
+		return pvc.Namespace + "/" + group
+	}
+	index := strings.LastIndex(pvc.Name, "-")
+	if index <= 0 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(pvc.Name[index+1:], 10, 64); err != nil {
+		return ""
+	}
+	return pvc.Namespace + "/" + pvc.Name[:index]
+}
+
+// 已有同一反亲和组卷的节点及其中最严格的级别，包括已预留但尚未创建卷的pod所选的节点
+func (ls *LocalStorage) antiAffinityConflicts(antiAffinity map[string]string, uid types.UID) (map[string]string, error) {
+	conflicts := map[string]string{}
+	if len(antiAffinity) == 0 {
+		return conflicts, nil
+	}
+	mark := func(node, level string) {
+		if node != "" && conflicts[node] != utils.AntiAffinityNode {
+			conflicts[node] = level
+		}
+	}
+	pvs, err := ls.pvLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != utils.CSIPluginName {
+			continue
+		}
+		if level, ok := antiAffinity[pv.Spec.CSI.VolumeAttributes[utils.AntiAffinityGroupKey]]; ok {
+			mark(pv.Spec.CSI.VolumeAttributes[utils.VolumeDeviceNode], level)
+		}
+	}
+	for group, level := range antiAffinity {
+		for _, node := range ls.ledger.groupNodes(group, uid) {
+			mark(node, level)
+		}
+	}
+	return conflicts, nil
+}
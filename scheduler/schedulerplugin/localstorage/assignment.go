@@ -0,0 +1,125 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 单个pod中未设置设备组的pvc数量有限，搜索次数超过该值时认为无法分配
+const maxAssignmentSteps = 10000
+
+// 扣除已指定设备组的请求及缓存设备请求后，节点各设备组剩余的容量(Gb)
+func remainingCapacity(request *storageRequest, capacityMap map[string]int64) map[string]int64 {
+	capacity := map[string]int64{}
+	for key, c := range capacityMap {
+		capacity[key] = c
+	}
+	for key, requestGb := range request.groups {
+		if _, ok := capacity[key]; ok {
+			capacity[key] -= requestGb
+		}
+	}
+	for key, requestGb := range request.cache {
+		if _, ok := capacity[key]; ok {
+			capacity[key] -= requestGb
+		}
+	}
+	return capacity
+}
+
+// 为sc中未设置设备组的pvc整体分配设备组，返回与request.undefined对应的设备组容量名称
+// 按请求容量从大到小回溯搜索，每个pvc优先尝试剩余容量最小的设备组，存在可行的分配时一定能够找到
+func assignUndefined(request *storageRequest, capacityMap map[string]int64) ([]string, bool) {
+	capacity := remainingCapacity(request, capacityMap)
+	groups := []string{}
+	for key := range capacity {
+		groups = append(groups, key)
+	}
+	sort.Strings(groups)
+
+	assignment := make([]string, len(request.undefined))
+	steps := 0
+	var search func(i int) bool
+	search = func(i int) bool {
+		if i == len(request.undefined) {
+			return true
+		}
+		steps++
+		if steps > maxAssignmentSteps {
+			return false
+		}
+		requestGb := request.undefined[i]
+		candidates := []string{}
+		for _, group := range groups {
+			if capacity[group] >= requestGb {
+				candidates = append(candidates, group)
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return capacity[candidates[a]] < capacity[candidates[b]]
+		})
+		// 剩余容量相同的设备组分配结果相同，只需尝试一个
+		tried := map[int64]bool{}
+		for _, group := range candidates {
+			if tried[capacity[group]] {
+				continue
+			}
+			tried[capacity[group]] = true
+			capacity[group] -= requestGb
+			assignment[i] = group
+			if search(i + 1) {
+				return true
+			}
+			capacity[group] += requestGb
+		}
+		return false
+	}
+	if !search(0) {
+		return nil, false
+	}
+	return assignment, true
+}
+
+// pvc(namespace/name) -> 设备组名称，如carina-vg-hdd
+func (r *storageRequest) deviceGroupAssignment(capacityMap map[string]int64) (map[string]string, bool) {
+	assignment, ok := assignUndefined(r, capacityMap)
+	if !ok {
+		return nil, false
+	}
+	result := map[string]string{}
+	for i, key := range assignment {
+		result[r.undefinedPvcs[i]] = deviceGroupName(key)
+	}
+	return result, true
+}
+
+// 无法分配时的提示信息，包括各pvc的请求容量及剩余容量最大的设备组
+func undefinedShortage(request *storageRequest, capacityMap map[string]int64) (string, int64) {
+	sizes := []string{}
+	for _, requestGb := range request.undefined {
+		sizes = append(sizes, fmt.Sprintf("%dGi", requestGb))
+	}
+	var largest int64
+	for _, c := range remainingCapacity(request, capacityMap) {
+		if c > largest {
+			largest = c
+		}
+	}
+	return strings.Join(sizes, ","), largest
+}
@@ -0,0 +1,92 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"context"
+	"github.com/carina-io/carina/scheduler/utils"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+	"time"
+)
+
+func TestAssignUndefined(t *testing.T) {
+	hdd := utils.DeviceCapacityKeyPrefix + "carina-vg-hdd"
+	ssd := utils.DeviceCapacityKeyPrefix + "carina-vg-ssd"
+	table := []struct {
+		request  *storageRequest
+		capacity map[string]int64
+		result   []string
+		ok       bool
+	}{
+		// 逐个按最小满足分配时5G与4G分别占用两个设备组，剩余的两个3G无法分配
+		{request: &storageRequest{undefined: []int64{5, 4, 3, 3}}, capacity: map[string]int64{hdd: 7, ssd: 8}, result: []string{ssd, hdd, hdd, ssd}, ok: true},
+		{request: &storageRequest{undefined: []int64{30, 15, 6}}, capacity: map[string]int64{hdd: 20, ssd: 40}, result: []string{ssd, hdd, ssd}, ok: true},
+		// 先扣除已指定设备组的请求
+		{request: &storageRequest{groups: map[string]int64{ssd: 20}, undefined: []int64{30}}, capacity: map[string]int64{hdd: 20, ssd: 40}, ok: false},
+		{request: &storageRequest{cache: map[string]int64{ssd: 10}, undefined: []int64{30}}, capacity: map[string]int64{hdd: 20, ssd: 40}, result: []string{ssd}, ok: true},
+		{request: &storageRequest{undefined: []int64{20, 20}}, capacity: map[string]int64{hdd: 30}, ok: false},
+	}
+	a := assert.New(t)
+	for _, e := range table {
+		result, ok := assignUndefined(e.request, e.capacity)
+		a.Equal(e.ok, ok)
+		if e.ok {
+			a.Equal(e.result, result)
+		}
+	}
+}
+
+// 只实现PreBind用到的ClientSet
+type testHandle struct {
+	framework.Handle
+	client kubernetes.Interface
+}
+
+func (h *testHandle) ClientSet() kubernetes.Interface {
+	return h.client
+}
+
+func TestPreBind(t *testing.T) {
+	a := assert.New(t)
+	pvc := newTestPvc("pvc-1", "csi-carina", "10Gi")
+	client := fake.NewSimpleClientset(pvc)
+	ls := newTestPlugin(nil, nil)
+	ls.handle = &testHandle{client: client}
+
+	request := &storageRequest{undefined: []int64{10}, undefinedPvcs: []string{"default/pvc-1"}}
+	assignment, ok := request.deviceGroupAssignment(map[string]int64{utils.DeviceCapacityKeyPrefix + "carina-vg-hdd": 20})
+	a.True(ok)
+	ls.ledger.reserve("pod-uid", &reservation{nodeName: "node-1", assignment: assignment, expires: time.Now().Add(time.Minute)})
+
+	pod := newTestPod("pvc-1")
+	// 选定的节点与预留的节点不一致时不写入注解
+	a.True(ls.PreBind(context.Background(), framework.NewCycleState(), pod, "node-2").IsSuccess())
+	got, err := client.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), "pvc-1", metav1.GetOptions{})
+	a.NoError(err)
+	a.Equal("", got.Annotations[utils.AnnAssignedDeviceGroup])
+
+	a.True(ls.PreBind(context.Background(), framework.NewCycleState(), pod, "node-1").IsSuccess())
+	got, err = client.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), "pvc-1", metav1.GetOptions{})
+	a.NoError(err)
+	a.Equal(map[string]string{utils.AnnAssignedDeviceGroup: "carina-vg-hdd", utils.AnnAssignedNode: "node-1"}, got.Annotations)
+	a.Equal(v1.ClaimPending, got.Status.Phase)
+}
@@ -0,0 +1,167 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/scheduler/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"strings"
+)
+
+// carina-controller为每个存储卷创建的LogicVolume对象
+var logicVolumeResource = schema.GroupVersionResource{Group: "carina.storage.io", Version: "v1", Resource: "logicvolumes"}
+
+const (
+	logicVolumeNodeIndex = "nodeName"
+	// carina-node为每个卷创建的thin pool名称前缀，NodeStorage上报设备组中全部thin pool
+	thinPoolPrefix = "thin-"
+	lvFailed       = "Failed"
+)
+
+func (ls *LocalStorage) setCarinaInformers(kubeConfig *rest.Config) {
+	var lvInformer cache.SharedIndexInformer
+	ls.nsLister, lvInformer = newCarinaInformers(kubeConfig)
+	if lvInformer != nil {
+		ls.lvIndexer = lvInformer.GetIndexer()
+		ls.lvSynced = lvInformer.HasSynced
+	}
+}
+
+func logicVolumeNode(obj interface{}) ([]string, error) {
+	lv, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("%+v is not unstructured", obj)
+	}
+	nodeName, _, _ := unstructured.NestedString(lv.Object, "spec", "nodeName")
+	return []string{nodeName}, nil
+}
+
+// 节点各设备组的可用容量(Gb)
+// 容量来源为nodestorage时使用carina-node上报的设备组容量，并扣除尚未计入上报结果的LogicVolume
+// NodeStorage不存在或LogicVolume缓存未同步时使用device plugin上报的allocatable
+func (ls *LocalStorage) nodeCapacity(node *v1.Node) map[string]int64 {
+	if capacityMap, ok := ls.carinaCapacity(node.Name); ok {
+		return capacityMap
+	}
+	capacityMap := map[string]int64{}
+	for key, v := range node.Status.Allocatable {
+		if strings.HasPrefix(string(key), utils.DeviceCapacityKeyPrefix) {
+			capacityMap[string(key)] = v.Value()
+		}
+	}
+	return capacityMap
+}
+
+func (ls *LocalStorage) carinaCapacity(nodeName string) (map[string]int64, bool) {
+	if ls.nsLister == nil || ls.lvIndexer == nil || !ls.lvSynced() {
+		return nil, false
+	}
+	obj, err := ls.nsLister.Get(nodeName)
+	if err != nil {
+		return nil, false
+	}
+	ns, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, false
+	}
+	groups, found, _ := unstructured.NestedSlice(ns.Object, "status", "deviceGroups")
+	if !found {
+		return nil, false
+	}
+
+	// 已扣除预留及降级设备组的可用容量(字节)，以及已计入该容量的thin pool大小
+	allocatable := map[string]int64{}
+	pools := map[string]map[string]int64{}
+	for _, g := range groups {
+		group, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(group, "name")
+		value, _, _ := unstructured.NestedInt64(group, "allocatable")
+		allocatable[name] = value
+		pools[name] = map[string]int64{}
+		thinPools, _, _ := unstructured.NestedSlice(group, "thinPools")
+		for _, t := range thinPools {
+			pool, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			poolName, _, _ := unstructured.NestedString(pool, "name")
+			poolSize, _, _ := unstructured.NestedInt64(pool, "size")
+			pools[name][poolName] = poolSize
+		}
+	}
+	pending, err := ls.pendingVolumes(nodeName, pools)
+	if err != nil {
+		return nil, false
+	}
+	capacityMap := map[string]int64{}
+	for name, free := range allocatable {
+		free -= pending[name]
+		if free < 0 {
+			free = 0
+		}
+		capacityMap[utils.DeviceCapacityKeyPrefix+name] = free >> 30
+	}
+	return capacityMap, true
+}
+
+// 节点上尚未计入NodeStorage的卷容量(字节)，按设备组统计
+// 以NodeStorage上报的thin pool为准，没有对应thin pool的卷整体扣除，thin pool小于请求容量时扣除未完成扩容的部分
+func (ls *LocalStorage) pendingVolumes(nodeName string, pools map[string]map[string]int64) (map[string]int64, error) {
+	objs, err := ls.lvIndexer.ByIndex(logicVolumeNodeIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	pending := map[string]int64{}
+	for _, obj := range objs {
+		lv, ok := obj.(*unstructured.Unstructured)
+		if !ok || lv.GetDeletionTimestamp() != nil {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(lv.Object, "spec", "deviceGroup")
+		status, _, _ := unstructured.NestedString(lv.Object, "status", "status")
+		size := quantityValue(lv.Object, "spec", "size")
+		if status == lvFailed {
+			// 创建失败的卷不占用空间
+			continue
+		}
+		poolSize, ok := pools[group][thinPoolPrefix+lv.GetName()]
+		switch {
+		case !ok:
+			pending[group] += size
+		case size > poolSize:
+			pending[group] += size - poolSize
+		}
+	}
+	return pending, nil
+}
+
+func quantityValue(obj map[string]interface{}, fields ...string) int64 {
+	value, _, _ := unstructured.NestedString(obj, fields...)
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+	return q.Value()
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"github.com/carina-io/carina/scheduler/utils"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+	"time"
+)
+
+func newTestLogicVolume(name, node, size, status string, created time.Time) *unstructured.Unstructured {
+	lv := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "carina.storage.io/v1",
+		"kind":       "LogicVolume",
+		"spec":       map[string]interface{}{"nodeName": node, "deviceGroup": "carina-vg-hdd", "size": size},
+		"status":     map[string]interface{}{"status": status},
+	}}
+	lv.SetName(name)
+	lv.SetCreationTimestamp(metav1.NewTime(created))
+	return lv
+}
+
+func TestNodeCapacity(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now().Truncate(time.Second)
+
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	ns := newTestNodeStorage("node-1",
+		map[string]interface{}{"name": "carina-vg-hdd", "allocatable": int64(100 << 30), "thinPools": []interface{}{
+			map[string]interface{}{"name": "thin-lv-1", "size": int64(50 << 30)},
+			map[string]interface{}{"name": "thin-lv-2", "size": int64(20 << 30)},
+			map[string]interface{}{"name": "thin-lv-4", "size": int64(5 << 30)},
+		}},
+		map[string]interface{}{"name": "carina-vg-ssd", "allocatable": int64(10<<30 + 512<<20)},
+	)
+	_ = unstructured.SetNestedField(ns.Object, now.Format(time.RFC3339), "status", "lastUpdateTime")
+	_ = nsIndexer.Add(ns)
+
+	lvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{logicVolumeNodeIndex: logicVolumeNode})
+	// 已计入NodeStorage
+	_ = lvIndexer.Add(newTestLogicVolume("lv-1", "node-1", "50Gi", "Success", now.Add(-time.Hour)))
+	// 扩容中，thin pool尚未扩容
+	expanding := newTestLogicVolume("lv-2", "node-1", "30Gi", "Success", now.Add(-time.Hour))
+	_ = unstructured.SetNestedField(expanding.Object, "20Gi", "status", "currentSize")
+	_ = lvIndexer.Add(expanding)
+	// 创建中
+	_ = lvIndexer.Add(newTestLogicVolume("lv-3", "node-1", "20Gi", "", now))
+	// 卷创建5s后NodeStorage更新并已计入
+	_ = lvIndexer.Add(newTestLogicVolume("lv-4", "node-1", "5Gi", "Success", now.Add(-5*time.Second)))
+	_ = lvIndexer.Add(newTestLogicVolume("lv-5", "node-1", "40Gi", lvFailed, now))
+	_ = lvIndexer.Add(newTestLogicVolume("lv-6", "node-2", "40Gi", "", now))
+	// NodeStorage在卷创建5s后更新，但状态未变化跳过了写入，卷未计入
+	_ = lvIndexer.Add(newTestLogicVolume("lv-7", "node-1", "8Gi", "Success", now.Add(-5*time.Second)))
+
+	synced := false
+	ls := &LocalStorage{
+		nsLister:  cache.NewGenericLister(nsIndexer, nodeStorageResource.GroupResource()),
+		lvIndexer: lvIndexer,
+		lvSynced:  func() bool { return synced },
+	}
+	hdd := utils.DeviceCapacityKeyPrefix + "carina-vg-hdd"
+	ssd := utils.DeviceCapacityKeyPrefix + "carina-vg-ssd"
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status:     v1.NodeStatus{Allocatable: v1.ResourceList{v1.ResourceName(hdd): resource.MustParse("80")}},
+	}
+
+	// LogicVolume缓存未同步
+	a.Equal(map[string]int64{hdd: 80}, ls.nodeCapacity(node))
+	synced = true
+	a.Equal(map[string]int64{hdd: 62, ssd: 10}, ls.nodeCapacity(node))
+	// NodeStorage不存在
+	node.Name = "node-2"
+	a.Equal(map[string]int64{hdd: 80}, ls.nodeCapacity(node))
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// 调度器扩展(extender)模式下使用，与调度框架插件共用过滤和评分逻辑
+// 扩展模式没有Reserve阶段，不会预留容量，kubeConfig为nil时IO负载不参与评分，容量使用节点allocatable
+func NewExtender(factory informers.SharedInformerFactory, kubeConfig *rest.Config) *LocalStorage {
+	ls := &LocalStorage{
+		scLister:  factory.Storage().V1().StorageClasses().Lister(),
+		pvcLister: factory.Core().V1().PersistentVolumeClaims().Lister(),
+		pvLister:  factory.Core().V1().PersistentVolumes().Lister(),
+		ledger:    newReservationLedger(),
+	}
+	ls.setCarinaInformers(kubeConfig)
+	return ls
+}
+
+// 过滤节点，返回满足pod存储请求的节点及其他节点不满足的原因
+func (ls *LocalStorage) FilterNodes(pod *v1.Pod, nodes []*v1.Node) ([]*v1.Node, map[string]string, error) {
+	request, err := ls.getStorageRequest(pod)
+	if err != nil {
+		klog.V(3).ErrorS(err, "get pvc sc failed", "pod", pod.Name)
+		return nil, nil, err
+	}
+	filtered := []*v1.Node{}
+	failed := map[string]string{}
+	for _, node := range nodes {
+		status := ls.filterNode(request, pod, node)
+		if !status.IsSuccess() {
+			failed[node.Name] = status.Message()
+			continue
+		}
+		filtered = append(filtered, node)
+	}
+	return filtered, failed, nil
+}
+
+// 对节点打分，分数已按最高分等比映射到0-100
+func (ls *LocalStorage) ScoreNodes(pod *v1.Pod, nodes []*v1.Node) (framework.NodeScoreList, error) {
+	request, err := ls.getStorageRequest(pod)
+	if err != nil {
+		klog.V(3).ErrorS(err, "get pvc sc failed", "pod", pod.Name)
+		return nil, err
+	}
+	var volumes nodeVolumes
+	if request.policy.volumeWeight > 0 {
+		volumes, err = ls.nodeVolumes()
+		if err != nil {
+			return nil, err
+		}
+	}
+	scores := make(framework.NodeScoreList, 0, len(nodes))
+	for _, node := range nodes {
+		nodeVolumes := int64(unknownMetric)
+		if volumes != nil {
+			nodeVolumes = volumes[node.Name]
+		}
+		score := ls.scoreNode(request, pod, node, nodeVolumes)
+		klog.V(3).Infof("score pod: %v, node: %v score %v", pod.Name, node.Name, score)
+		scores = append(scores, framework.NodeScore{Name: node.Name, Score: score})
+	}
+	normalizeScore(scores)
+	return scores, nil
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"github.com/carina-io/carina/scheduler/configuration"
+	"github.com/carina-io/carina/scheduler/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// carina-node维护的NodeStorage对象，与节点同名
+var nodeStorageResource = schema.GroupVersionResource{Group: "carina.storage.io", Version: "v1", Resource: "nodestorages"}
+
+// 调度器模块不依赖carina的api定义，通过dynamic informer读取NodeStorage及LogicVolume对象
+// 无法创建时返回nil，IO负载不参与评分，容量使用节点allocatable
+// 容量来源配置为nodestorage时才监听LogicVolume，修改配置后需要重启调度器
+func newCarinaInformers(kubeConfig *rest.Config) (cache.GenericLister, cache.SharedIndexInformer) {
+	if kubeConfig == nil {
+		return nil, nil
+	}
+	client, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		klog.Errorf("create dynamic client failed: %v", err)
+		return nil, nil
+	}
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, 0)
+	nsLister := factory.ForResource(nodeStorageResource).Lister()
+	var lvInformer cache.SharedIndexInformer
+	if configuration.CapacitySource() == configuration.CapacityNodeStorage {
+		lvInformer = factory.ForResource(logicVolumeResource).Informer()
+		if err := lvInformer.AddIndexers(cache.Indexers{logicVolumeNodeIndex: logicVolumeNode}); err != nil {
+			klog.Errorf("add logicvolume indexer failed: %v", err)
+			lvInformer = nil
+		}
+	}
+	factory.Start(wait.NeverStop)
+	return nsLister, lvInformer
+}
+
+// 节点上pod请求的设备组中最繁忙的磁盘利用率(0-100)，keys为设备组容量名称
+// 未开启IO采样或者NodeStorage不存在时返回unknownMetric
+func (ls *LocalStorage) ioLoad(nodeName string, keys []string) int64 {
+	if ls.nsLister == nil {
+		return unknownMetric
+	}
+	obj, err := ls.nsLister.Get(nodeName)
+	if err != nil {
+		return unknownMetric
+	}
+	ns, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return unknownMetric
+	}
+	groups, _, _ := unstructured.NestedSlice(ns.Object, "status", "deviceGroups")
+	wanted := map[string]bool{}
+	for _, key := range keys {
+		wanted[strings.TrimPrefix(key, utils.DeviceCapacityKeyPrefix)] = true
+	}
+
+	load := int64(unknownMetric)
+	for _, g := range groups {
+		group, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(group, "name")
+		if !wanted[name] {
+			continue
+		}
+		value, found, _ := unstructured.NestedFieldNoCopy(group, "ioStats", "utilization")
+		if !found {
+			continue
+		}
+		// json反序列化后整数为int64，小数为float64
+		var utilization int64
+		switch v := value.(type) {
+		case int64:
+			utilization = v
+		case float64:
+			utilization = int64(v + 0.5)
+		default:
+			continue
+		}
+		if utilization > load {
+			load = utilization
+		}
+	}
+	return load
+}
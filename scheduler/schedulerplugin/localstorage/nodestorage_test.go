@@ -0,0 +1,58 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+)
+
+func newTestNodeStorage(name string, groups ...map[string]interface{}) *unstructured.Unstructured {
+	deviceGroups := []interface{}{}
+	for _, g := range groups {
+		deviceGroups = append(deviceGroups, g)
+	}
+	ns := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "carina.storage.io/v1",
+		"kind":       "NodeStorage",
+		"status":     map[string]interface{}{"deviceGroups": deviceGroups},
+	}}
+	ns.SetName(name)
+	return ns
+}
+
+func TestIOLoad(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	_ = indexer.Add(newTestNodeStorage("node-1",
+		map[string]interface{}{"name": "carina-vg-hdd", "ioStats": map[string]interface{}{"utilization": 35.6}},
+		map[string]interface{}{"name": "carina-vg-ssd", "ioStats": map[string]interface{}{"utilization": int64(80)}},
+	))
+	_ = indexer.Add(newTestNodeStorage("node-2", map[string]interface{}{"name": "carina-vg-hdd"}))
+	ls := &LocalStorage{nsLister: cache.NewGenericLister(indexer, nodeStorageResource.GroupResource())}
+
+	hdd := "carina.storage.io/carina-vg-hdd"
+	ssd := "carina.storage.io/carina-vg-ssd"
+	a := assert.New(t)
+	a.Equal(int64(36), ls.ioLoad("node-1", []string{hdd}))
+	a.Equal(int64(80), ls.ioLoad("node-1", []string{hdd, ssd}))
+	// 未开启IO采样
+	a.Equal(int64(unknownMetric), ls.ioLoad("node-2", []string{hdd}))
+	// NodeStorage不存在
+	a.Equal(int64(unknownMetric), ls.ioLoad("node-3", []string{hdd}))
+	a.Equal(int64(unknownMetric), (&LocalStorage{}).ioLoad("node-1", []string{hdd}))
+}
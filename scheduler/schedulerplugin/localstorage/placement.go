@@ -0,0 +1,132 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/scheduler/configuration"
+	"github.com/carina-io/carina/scheduler/utils"
+	"strconv"
+	"strings"
+)
+
+// 节点指标未知时的取值，该项不参与评分
+const unknownMetric = -1
+
+// 卷放置策略，由storageclass参数指定，与carina-controller的节点选择保持一致
+type placementPolicy struct {
+	// binpack|spradout
+	strategy string
+	// 剩余容量、节点卷数量、磁盘IO负载的评分权重
+	capacityWeight int64
+	volumeWeight   int64
+	ioLoadWeight   int64
+}
+
+// 未设置storageclass参数时只按剩余容量评分
+func defaultPlacementPolicy() placementPolicy {
+	return placementPolicy{
+		strategy:       configuration.SchedulerStrategy(),
+		capacityWeight: 1,
+	}
+}
+
+// 解析storageclass中的放置策略参数
+func parsePlacementPolicy(parameters map[string]string) (placementPolicy, error) {
+	policy := defaultPlacementPolicy()
+	if strategy, ok := parameters[utils.PlacementStrategyKey]; ok && strategy != "" {
+		switch strings.ToLower(strategy) {
+		case configuration.SchedulerBinpack:
+			policy.strategy = configuration.SchedulerBinpack
+		case configuration.SchedulerSpradout, "spreadout":
+			policy.strategy = configuration.SchedulerSpradout
+		default:
+			return policy, fmt.Errorf("%s %s, should be binpack or spradout", utils.PlacementStrategyKey, strategy)
+		}
+	}
+
+	weights, ok := parameters[utils.PlacementWeightsKey]
+	if !ok || weights == "" {
+		return policy, nil
+	}
+	policy.capacityWeight = 0
+	for _, item := range strings.Split(weights, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return policy, fmt.Errorf("%s %s, should be like capacity=1,volume=1,ioload=1", utils.PlacementWeightsKey, weights)
+		}
+		weight, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil || weight < 0 {
+			return policy, fmt.Errorf("%s %s, weight should be a non-negative integer", utils.PlacementWeightsKey, weights)
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "capacity":
+			policy.capacityWeight = weight
+		case "volume":
+			policy.volumeWeight = weight
+		case "ioload":
+			policy.ioLoadWeight = weight
+		default:
+			return policy, fmt.Errorf("%s %s, unknown factor %s", utils.PlacementWeightsKey, weights, kv[0])
+		}
+	}
+	if policy.capacityWeight+policy.volumeWeight+policy.ioLoadWeight == 0 {
+		return policy, fmt.Errorf("%s %s, at least one weight should be greater than 0", utils.PlacementWeightsKey, weights)
+	}
+	return policy, nil
+}
+
+// 按权重合并各项分数，结果范围0-100
+// volumes为节点上的卷数量，ioLoad为磁盘IO使用率(0-100)，未知时为-1不参与计算
+func (p placementPolicy) score(capacityScore, volumes, ioLoad int64) int64 {
+	weighted := p.capacityWeight * capacityScore
+	total := p.capacityWeight
+	if volumes != unknownMetric {
+		weighted += p.volumeWeight * volumeScore(volumes, p.strategy)
+		total += p.volumeWeight
+	}
+	if ioLoad != unknownMetric {
+		weighted += p.ioLoadWeight * ioLoadScore(ioLoad)
+		total += p.ioLoadWeight
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted / total
+}
+
+// 节点卷数量的分数，spradout倾向卷少的节点，binpack相反
+func volumeScore(volumes int64, strategy string) int64 {
+	if volumes > 8 {
+		volumes = 8
+	}
+	score := 5 - volumes/2
+	if strategy == configuration.SchedulerBinpack {
+		score = 6 - score
+	}
+	return score * 20
+}
+
+// IO负载越低分数越高，与策略无关
+func ioLoadScore(ioLoad int64) int64 {
+	if ioLoad > 100 {
+		ioLoad = 100
+	}
+	if ioLoad < 0 {
+		ioLoad = 0
+	}
+	return 100 - ioLoad
+}
@@ -0,0 +1,67 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"github.com/carina-io/carina/scheduler/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePlacementPolicy(t *testing.T) {
+	table := []struct {
+		parameters map[string]string
+		policy     placementPolicy
+		err        bool
+	}{
+		{parameters: map[string]string{utils.PlacementStrategyKey: "binpack"}, policy: placementPolicy{strategy: "binpack", capacityWeight: 1}},
+		{parameters: map[string]string{utils.PlacementStrategyKey: "Spreadout", utils.PlacementWeightsKey: "capacity=2, volume=1"}, policy: placementPolicy{strategy: "spradout", capacityWeight: 2, volumeWeight: 1}},
+		{parameters: map[string]string{utils.PlacementStrategyKey: "random"}, err: true},
+		{parameters: map[string]string{utils.PlacementWeightsKey: "capacity=-1"}, err: true},
+		{parameters: map[string]string{utils.PlacementWeightsKey: "capacity=0"}, err: true},
+	}
+	a := assert.New(t)
+	for _, e := range table {
+		policy, err := parsePlacementPolicy(e.parameters)
+		if e.err {
+			a.Error(err)
+			continue
+		}
+		a.NoError(err)
+		a.Equal(e.policy, policy)
+	}
+}
+
+func TestPlacementScore(t *testing.T) {
+	table := []struct {
+		policy   placementPolicy
+		capacity int64
+		volumes  int64
+		ioLoad   int64
+		result   int64
+	}{
+		{policy: placementPolicy{strategy: "spradout", capacityWeight: 1}, capacity: 60, volumes: 4, ioLoad: 50, result: 60},
+		{policy: placementPolicy{strategy: "spradout", capacityWeight: 1, volumeWeight: 1}, capacity: 60, volumes: 0, ioLoad: unknownMetric, result: 80},
+		{policy: placementPolicy{strategy: "binpack", capacityWeight: 1, volumeWeight: 1}, capacity: 60, volumes: 0, ioLoad: unknownMetric, result: 40},
+		{policy: placementPolicy{strategy: "spradout", capacityWeight: 1, ioLoadWeight: 3}, capacity: 100, volumes: unknownMetric, ioLoad: 80, result: 40},
+		// 指标未知时不参与计算
+		{policy: placementPolicy{strategy: "spradout", capacityWeight: 1, volumeWeight: 1, ioLoadWeight: 1}, capacity: 60, volumes: unknownMetric, ioLoad: unknownMetric, result: 60},
+	}
+	a := assert.New(t)
+	for _, e := range table {
+		a.Equal(e.result, e.policy.score(e.capacity, e.volumes, e.ioLoad))
+	}
+}
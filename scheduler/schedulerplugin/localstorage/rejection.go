@@ -0,0 +1,65 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"fmt"
+	"github.com/carina-io/carina/scheduler/utils"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strings"
+)
+
+// 节点被过滤的原因，作为监控指标的reason标签
+const (
+	reasonNodeMismatch      = "pv_node_mismatch"
+	reasonAntiAffinity      = "anti_affinity"
+	reasonInsufficient      = "insufficient_capacity"
+	reasonCacheInsufficient = "insufficient_cache_capacity"
+)
+
+// 按原因及设备组统计被过滤的节点次数，由kube-scheduler或调度器扩展的/metrics接口暴露
+var filterRejections = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Subsystem:      "carina_scheduler",
+		Name:           "filter_rejections_total",
+		Help:           "Number of nodes rejected by the local-storage filter, by reason and device group.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"reason", "device_group"},
+)
+
+func init() {
+	legacyregistry.MustRegister(filterRejections)
+}
+
+// 记录过滤原因，返回显示在调度事件中的信息
+// 信息中不包含节点名称，kube-scheduler会按相同的信息汇总节点数量
+func reject(reason, group, format string, args ...interface{}) string {
+	filterRejections.WithLabelValues(reason, group).Inc()
+	return fmt.Sprintf(format, args...)
+}
+
+// 存储无法满足时抢占其他pod也无济于事
+func unschedulable(reasons ...string) *framework.Status {
+	return framework.NewStatus(framework.UnschedulableAndUnresolvable, reasons...)
+}
+
+// 容量名称转换为设备组名称，如carina.storage.io/carina-vg-hdd转换为carina-vg-hdd
+func deviceGroupName(key string) string {
+	return strings.TrimPrefix(key, utils.DeviceCapacityKeyPrefix)
+}
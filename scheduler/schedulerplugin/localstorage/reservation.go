@@ -0,0 +1,160 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 已通过调度但尚未创建存储卷的请求
+// 节点allocatable由device plugin异步更新，在此期间需要扣除这部分容量，避免多个pod同时选中同一块空间
+type reservation struct {
+	nodeName string
+	// 设备组 -> 请求容量(Gb)
+	requests map[string]int64
+	// pod使用的未绑定pvc，namespace/name
+	pvcs []string
+	// pvc所属的卷反亲和组
+	groups []string
+	// sc中未设置设备组的pvc -> 设备组名称
+	assignment map[string]string
+	expires    time.Time
+}
+
+type reservationLedger struct {
+	lock         sync.Mutex
+	reservations map[types.UID]*reservation
+}
+
+func newReservationLedger() *reservationLedger {
+	return &reservationLedger{
+		reservations: map[types.UID]*reservation{},
+	}
+}
+
+func (l *reservationLedger) reserve(uid types.UID, r *reservation) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.reservations[uid] = r
+}
+
+func (l *reservationLedger) release(uid types.UID) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.reservations, uid)
+}
+
+// 清理超时的记录以及pvc已全部绑定的记录
+func (l *reservationLedger) prune(now time.Time, bound func(pvc string) bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for uid, r := range l.reservations {
+		if now.After(r.expires) {
+			delete(l.reservations, uid)
+			continue
+		}
+		released := true
+		for _, pvc := range r.pvcs {
+			if !bound(pvc) {
+				released = false
+				break
+			}
+		}
+		if released {
+			delete(l.reservations, uid)
+		}
+	}
+}
+
+// 节点上各设备组已预留的容量，不包含当前pod自身的预留
+func (l *reservationLedger) reserved(nodeName string, exclude types.UID) map[string]int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	result := map[string]int64{}
+	for uid, r := range l.reservations {
+		if uid == exclude || r.nodeName != nodeName {
+			continue
+		}
+		for group, request := range r.requests {
+			result[group] += request
+		}
+	}
+	return result
+}
+
+// 已预留的pod中包含反亲和组卷的节点，不包含当前pod自身的预留
+func (l *reservationLedger) groupNodes(group string, exclude types.UID) []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	nodes := []string{}
+	for uid, r := range l.reservations {
+		if uid == exclude {
+			continue
+		}
+		for _, g := range r.groups {
+			if g == group {
+				nodes = append(nodes, r.nodeName)
+				break
+			}
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// pod在选定节点上的设备组分配
+func (l *reservationLedger) assignment(uid types.UID, nodeName string) map[string]string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	r, ok := l.reservations[uid]
+	if !ok || r.nodeName != nodeName {
+		return nil
+	}
+	result := map[string]string{}
+	for pvc, group := range r.assignment {
+		result[pvc] = group
+	}
+	return result
+}
+
+func (l *reservationLedger) size() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.reservations)
+}
+
+// 在所有设备组中找到最低满足请求的设备组
+func minimumGroup(capacityMap map[string]int64, value int64) string {
+	groups := []string{}
+	for group, capacity := range capacityMap {
+		if capacity >= value {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if capacityMap[groups[i]] == capacityMap[groups[j]] {
+			return groups[i] < groups[j]
+		}
+		return capacityMap[groups[i]] < capacityMap[groups[j]]
+	})
+	return groups[0]
+}
@@ -0,0 +1,76 @@
+/*
+   Copyright @ 2021 bocloud <fushaosong@beyondcent.com>.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package localstorage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"testing"
+	"time"
+)
+
+func TestReservationLedger(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	ledger := newReservationLedger()
+	ledger.reserve(types.UID("pod-1"), &reservation{
+		nodeName: "node-1",
+		requests: map[string]int64{"carina.storage.io/carina-vg-hdd": 10},
+		pvcs:     []string{"default/pvc-1"},
+		expires:  now.Add(time.Minute),
+	})
+	ledger.reserve(types.UID("pod-2"), &reservation{
+		nodeName: "node-1",
+		requests: map[string]int64{"carina.storage.io/carina-vg-hdd": 5, "carina.storage.io/carina-vg-ssd": 3},
+		pvcs:     []string{"default/pvc-2", "default/pvc-3"},
+		expires:  now.Add(time.Minute),
+	})
+	ledger.reserve(types.UID("pod-3"), &reservation{
+		nodeName: "node-2",
+		requests: map[string]int64{"carina.storage.io/carina-vg-hdd": 7},
+		pvcs:     []string{"default/pvc-4"},
+		expires:  now.Add(-time.Second),
+	})
+
+	a.Equal(map[string]int64{"carina.storage.io/carina-vg-hdd": 15, "carina.storage.io/carina-vg-ssd": 3}, ledger.reserved("node-1", ""))
+	a.Equal(map[string]int64{"carina.storage.io/carina-vg-hdd": 5, "carina.storage.io/carina-vg-ssd": 3}, ledger.reserved("node-1", types.UID("pod-1")))
+
+	// pod-3超时，pod-1的pvc已绑定，pod-2只绑定了部分pvc
+	bound := map[string]bool{"default/pvc-1": true, "default/pvc-2": true}
+	ledger.prune(now, func(pvc string) bool { return bound[pvc] })
+	a.Equal(1, ledger.size())
+	a.Equal(map[string]int64{}, ledger.reserved("node-2", ""))
+
+	ledger.release(types.UID("pod-2"))
+	a.Equal(map[string]int64{}, ledger.reserved("node-1", ""))
+}
+
+func TestMinimumGroup(t *testing.T) {
+	table := []struct {
+		capacity map[string]int64
+		value    int64
+		result   string
+	}{
+		{capacity: map[string]int64{"hdd": 20, "ssd": 40}, value: 15, result: "hdd"},
+		{capacity: map[string]int64{"hdd": 20, "ssd": 40}, value: 30, result: "ssd"},
+		{capacity: map[string]int64{"hdd": 20, "ssd": 20}, value: 20, result: "hdd"},
+		{capacity: map[string]int64{"hdd": 20, "ssd": 40}, value: 50, result: ""},
+	}
+	a := assert.New(t)
+	for _, e := range table {
+		a.Equal(e.result, minimumGroup(e.capacity, e.value))
+	}
+}
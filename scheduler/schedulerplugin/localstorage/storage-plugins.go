@@ -17,147 +17,191 @@ package localstorage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/carina-io/carina/scheduler/configuration"
 	"github.com/carina-io/carina/scheduler/utils"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	lcorev1 "k8s.io/client-go/listers/core/v1"
 	lstoragev1 "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"sort"
 	"strconv"
-	"strings"
+	"time"
 )
 
 // 插件名称
 const Name = "local-storage"
 const undefined = "undefined"
 
+// CycleState中保存pod存储请求及节点卷数量的key
+const preFilterStateKey framework.StateKey = "PreFilter" + Name
+const preScoreStateKey framework.StateKey = "PreScore" + Name
+
 type LocalStorage struct {
 	handle    framework.Handle
 	scLister  lstoragev1.StorageClassLister
 	pvcLister lcorev1.PersistentVolumeClaimLister
 	pvLister  lcorev1.PersistentVolumeLister
+	// 已预留但尚未体现在节点allocatable中的容量
+	ledger *reservationLedger
+	// NodeStorage对象，用于读取设备组IO负载及容量
+	nsLister cache.GenericLister
+	// 按节点索引的LogicVolume，容量来源为nodestorage时使用
+	lvIndexer cache.Indexer
+	lvSynced  cache.InformerSynced
 }
 
+var _ framework.PreFilterPlugin = &LocalStorage{}
 var _ framework.FilterPlugin = &LocalStorage{}
+var _ framework.PreScorePlugin = &LocalStorage{}
 var _ framework.ScorePlugin = &LocalStorage{}
+var _ framework.ScoreExtensions = &LocalStorage{}
+var _ framework.ReservePlugin = &LocalStorage{}
+var _ framework.PreBindPlugin = &LocalStorage{}
 
 //type PluginFactory = func(configuration *runtime.Unknown, f FrameworkHandle) (Plugin, error)
 func New(_ runtime.Object, handle framework.Handle) (framework.Plugin, error) {
 	scLister := handle.SharedInformerFactory().Storage().V1().StorageClasses().Lister()
 	pvcLister := handle.SharedInformerFactory().Core().V1().PersistentVolumeClaims().Lister()
 	pvLister := handle.SharedInformerFactory().Core().V1().PersistentVolumes().Lister()
-	return &LocalStorage{
+	ls := &LocalStorage{
 		handle:    handle,
 		pvcLister: pvcLister,
 		scLister:  scLister,
 		pvLister:  pvLister,
-	}, nil
+		ledger:    newReservationLedger(),
+	}
+	ls.setCarinaInformers(handle.KubeConfig())
+	return ls, nil
 }
 
 func (ls *LocalStorage) Name() string {
 	return Name
 }
 
-// 过滤掉不符合当前 Pod 运行条件的Node（相当于旧版本的 predicate）
-func (ls *LocalStorage) Filter(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod, node *framework.NodeInfo) *framework.Status {
+// 计算pod的存储请求并保存在CycleState中，Filter和Score阶段只需进行容量计算
+func (ls *LocalStorage) PreFilter(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod) *framework.Status {
+	request, err := ls.getStorageRequest(pod)
+	if err != nil {
+		klog.V(3).ErrorS(err, "get pvc sc failed", "pod", pod.Name)
+		return framework.NewStatus(framework.Error, fmt.Sprintf("get pv/sc resource error: %v", err))
+	}
+	cycleState.Write(preFilterStateKey, request)
+	return framework.NewStatus(framework.Success, "")
+}
 
-	klog.V(3).Infof("filter pod: %v, node: %v", pod.Name, node.Node().Name)
+// PreFilterExtensions returns prefilter extensions, pod add and remove.
+func (ls *LocalStorage) PreFilterExtensions() framework.PreFilterExtensions {
+	return nil
+}
 
-	pvcMap, nodeName, cacheDeviceRequest, err := ls.getLocalStoragePvc(pod)
+// 过滤掉不符合当前 Pod 运行条件的Node（相当于旧版本的 predicate）
+func (ls *LocalStorage) Filter(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod, node *framework.NodeInfo) *framework.Status {
+	request, err := ls.readStorageRequest(cycleState, pod)
 	if err != nil {
-		klog.V(3).ErrorS(err, "get pvc sc failed pod: %v, node: %v", pod.Name, node.Node().Name)
-		return framework.NewStatus(framework.Error, "get pv/sc resource error")
+		klog.V(3).ErrorS(err, "get pvc sc failed", "pod", pod.Name, "node", node.Node().Name)
+		return framework.NewStatus(framework.Error, fmt.Sprintf("get pv/sc resource error: %v", err))
 	}
-	if nodeName != "" && nodeName != node.Node().Name {
-		klog.V(3).Infof("mismatch pod: %v, node: %v", pod.Name, node.Node().Name)
-		return framework.NewStatus(framework.UnschedulableAndUnresolvable, "pv node mismatch")
+	return ls.filterNode(request, pod, node.Node())
+}
+
+// 检查节点能否满足pod的存储请求，调度框架插件与调度器扩展共用
+// 不满足时返回所有原因，包括设备组、请求容量及可用容量，显示在pod的FailedScheduling事件中
+func (ls *LocalStorage) filterNode(request *storageRequest, pod *v1.Pod, node *v1.Node) *framework.Status {
+	klog.V(3).Infof("filter pod: %v, node: %v", pod.Name, node.Name)
+
+	if request.nodeName != "" && request.nodeName != node.Name {
+		klog.V(3).Infof("mismatch pod: %v, node: %v", pod.Name, node.Name)
+		return unschedulable(reject(reasonNodeMismatch, "", "node(s) didn't match the node %s of bound carina volumes", request.nodeName))
 	}
-	if len(pvcMap) == 0 {
+	if request.empty() {
 		return framework.NewStatus(framework.Success, "")
 	}
-
-	capacityMap := map[string]int64{}
-	total := int64(0)
-	for key, v := range node.Node().Status.Allocatable {
-		if strings.HasPrefix(string(key), utils.DeviceCapacityKeyPrefix) {
-			capacityMap[string(key)] = v.Value()
-			total += v.Value()
-		}
+	if request.conflicts[node.Name] == utils.AntiAffinityNode {
+		klog.V(3).Infof("mismatch pod: %v, node: %v, anti-affinity", pod.Name, node.Name)
+		return unschedulable(reject(reasonAntiAffinity, "", "node(s) had carina volumes of the same anti-affinity group"))
 	}
 
-	// 检查节点容量是否充足
-	for key, pvs := range pvcMap {
-		sort.Slice(pvs, func(i, j int) bool {
-			return pvs[i].Spec.Resources.Requests.Storage().Value() > pvs[j].Spec.Resources.Requests.Storage().Value()
-		})
+	capacityMap := ls.availableCapacity(node, pod.UID)
+	reasons := []string{}
 
-		// 对于sc中未设置Device组处理比较复杂,需要判断在多个Device组的情况下，pv是否能够分配
-		// 如carina-vg-hdd 20G carina-vg-ssd 40G, pv1.reques
-		// t30 pv2.request.15 pv3.request 6G
-		// 我们这里不能采取最优分配算法，应该采用贪婪算法，因为我们CSI控制器对PV的创建是逐个进行的，它没有全局视图
-		// 即便如此，由于创建PV是由csi-provisioner发起的，请求顺序不确有可能导致pv不合理分配，所以建议sc设置Device组
-		// 正因为如此，按照最小满足开始过滤.
-		if key == undefined {
-			capacityList := []int64{}
-			for _, c := range capacityMap {
-				capacityList = append(capacityList, c)
-			}
-			for _, pv := range pvs {
-				requestBytes := pv.Spec.Resources.Requests.Storage().Value()
-				requestGb := (requestBytes-1)>>30 + 1
-				capacityList = minimumValueMinus(capacityList, requestGb)
-				if len(capacityList) == 0 {
-					klog.V(3).Infof("mismatch pod: %v, node: %v", pod.Name, node.Node().Name)
-					return framework.NewStatus(framework.UnschedulableAndUnresolvable, "node storage resource insufficient")
-				}
-			}
-		} else {
-			requestTotalBytes := int64(0)
-			for _, pv := range pvs {
-				requestTotalBytes += pv.Spec.Resources.Requests.Storage().Value()
-			}
-			requestTotalGb := (requestTotalBytes-1)>>30 + 1
-			// add cache device request
-			if value, ok := cacheDeviceRequest[key]; ok {
-				requestTotalGb += (value-1)>>30 + 1
-			}
-			if requestTotalGb > capacityMap[key] {
-				klog.V(3).Infof("mismatch pod: %v, node: %v, request: %d, capacity: %d", pod.Name, node.Node().Name, requestTotalGb, capacityMap[key])
-				return framework.NewStatus(framework.UnschedulableAndUnresolvable, "node storage resource insufficient")
-			}
+	// 检查节点容量是否充足
+	// 对于sc中未设置Device组处理比较复杂,需要判断在多个Device组的情况下，pv是否能够分配
+	// 如carina-vg-hdd 20G carina-vg-ssd 40G, pv1.request 30G pv2.request 15G pv3.request 6G
+	// CSI控制器对PV的创建是逐个进行的，它没有全局视图，请求顺序不确定也可能导致pv不合理分配
+	// 因此由调度器计算出整体的分配方案，在PreBind阶段写入pvc注解，carina-controller创建卷时按照该方案选择设备组
+	if len(request.undefined) > 0 {
+		if _, ok := assignUndefined(request, capacityMap); !ok {
+			sizes, largest := undefinedShortage(request, capacityMap)
+			klog.V(3).Infof("mismatch pod: %v, node: %v, request: %s, largest capacity: %d", pod.Name, node.Name, sizes, largest)
+			reasons = append(reasons, reject(reasonInsufficient, undefined, "node(s) could not fit volumes of %s without device group, largest available %dGi", sizes, largest))
+		}
+	}
+	for key, requestTotalGb := range request.groups {
+		// add cache device request
+		requestTotalGb += request.cache[key]
+		if requestTotalGb > capacityMap[key] {
+			klog.V(3).Infof("mismatch pod: %v, node: %v, request: %d, capacity: %d", pod.Name, node.Name, requestTotalGb, capacityMap[key])
+			group := deviceGroupName(key)
+			reasons = append(reasons, reject(reasonInsufficient, group, "node(s) had insufficient %s, requested %dGi, available %dGi", group, requestTotalGb, capacityMap[key]))
 		}
 	}
 
 	// check cache device request
-	for key, value := range cacheDeviceRequest {
-		requestTotalGb := (value-1)>>30 + 1
+	for key, requestTotalGb := range request.cache {
+		if _, ok := request.groups[key]; ok {
+			// 已与数据盘请求合并检查
+			continue
+		}
 		if requestTotalGb > capacityMap[key] {
-			klog.V(3).Infof("mismatch pod: %v, node: %v, request: %d, capacity: %d", pod.Name, node.Node().Name, requestTotalGb, capacityMap[key])
-			return framework.NewStatus(framework.UnschedulableAndUnresolvable, "node cache storage resource insufficient")
+			klog.V(3).Infof("mismatch pod: %v, node: %v, request: %d, capacity: %d", pod.Name, node.Name, requestTotalGb, capacityMap[key])
+			group := deviceGroupName(key)
+			reasons = append(reasons, reject(reasonCacheInsufficient, group, "node(s) had insufficient cache %s, requested %dGi, available %dGi", group, requestTotalGb, capacityMap[key]))
 		}
 	}
+	if len(reasons) > 0 {
+		sort.Strings(reasons)
+		return unschedulable(reasons...)
+	}
 
-	klog.V(3).Infof("filter success pod: %v, node: %v", pod.Name, node.Node().Name)
+	klog.V(3).Infof("filter success pod: %v, node: %v", pod.Name, node.Name)
+	return framework.NewStatus(framework.Success, "")
+}
+
+// PreFilter未启用时在此计算存储请求，保证Score阶段可以从CycleState读取
+// 放置策略需要节点卷数量时，在此一次性统计所有节点
+func (ls *LocalStorage) PreScore(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod, nodes []*v1.Node) *framework.Status {
+	request, err := ls.readStorageRequest(cycleState, pod)
+	if err != nil {
+		return framework.NewStatus(framework.Error, fmt.Sprintf("get pv/sc resource error: %v", err))
+	}
+	cycleState.Write(preFilterStateKey, request)
+	if request.policy.volumeWeight > 0 {
+		volumes, err := ls.nodeVolumes()
+		if err != nil {
+			return framework.NewStatus(framework.Error, fmt.Sprintf("list pv error: %v", err))
+		}
+		cycleState.Write(preScoreStateKey, volumes)
+	}
 	return framework.NewStatus(framework.Success, "")
 }
 
 // 对节点进行打分（相当于旧版本的 priorities）
 func (ls *LocalStorage) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
 	klog.V(3).Infof("score pod: %v, node: %v", pod.Name, nodeName)
-	pvcMap, node, _, _ := ls.getLocalStoragePvc(pod)
-	if node == nodeName {
-		return 10, framework.NewStatus(framework.Success)
-	}
-
-	if len(pvcMap) == 0 {
-		return 5, framework.NewStatus(framework.Success, "")
+	request, err := ls.readStorageRequest(state, pod)
+	if err != nil {
+		return 0, framework.NewStatus(framework.Error, fmt.Sprintf("get pv/sc resource error: %v", err))
 	}
-
 	// Get Node Info
 	// 节点信息快照在执行调度时创建，并在在整个调度周期内不变
 	nodeInfo, err := ls.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
@@ -165,60 +209,317 @@ func (ls *LocalStorage) Score(ctx context.Context, state *framework.CycleState,
 		return 0, framework.NewStatus(framework.Error, fmt.Sprintf("getting node %q from Snapshot: %v", nodeName, err))
 	}
 
-	capacityMap := map[string]int64{}
-	total := int64(0)
-	for key, v := range nodeInfo.Node().Status.Allocatable {
-		if strings.HasPrefix(string(key), utils.DeviceCapacityKeyPrefix) {
-			capacityMap[string(key)] = v.Value()
-			total += v.Value()
+	volumes := int64(unknownMetric)
+	if data, err := state.Read(preScoreStateKey); err == nil {
+		if nodeVolumes, ok := data.(nodeVolumes); ok {
+			volumes = nodeVolumes[nodeName]
 		}
 	}
-	var score int64
+	score := ls.scoreNode(request, pod, nodeInfo.Node(), volumes)
+	klog.V(3).Infof("score pod: %v, node: %v score %v", pod.Name, nodeName, score)
+	return score, framework.NewStatus(framework.Success)
+}
+
+// 计算节点分数，调度框架插件与调度器扩展共用，volumes为节点上carina卷的数量
+func (ls *LocalStorage) scoreNode(request *storageRequest, pod *v1.Pod, node *v1.Node, volumes int64) int64 {
+	if request.nodeName == node.Name {
+		return framework.MaxNodeScore
+	}
+	if request.empty() {
+		return framework.MaxNodeScore / 2
+	}
+
+	capacityMap := ls.availableCapacity(node, pod.UID)
 	// 计算节点分数
 	// 影响磁盘分数的有磁盘容量,磁盘上现有pv数量,磁盘IO
 	// 在此我们以磁盘容量作为标准，同时配合配置文件中磁盘选择策略
-	for key, pvs := range pvcMap {
-		sort.Slice(pvs, func(i, j int) bool {
-			return pvs[i].Spec.Resources.Requests.Storage().Value() > pvs[j].Spec.Resources.Requests.Storage().Value()
+	capacityScore := scoreRequest(request, capacityMap, request.policy.strategy)
+	ioLoad := int64(unknownMetric)
+	if request.policy.ioLoadWeight > 0 {
+		demand, _ := groupDemand(request, capacityMap)
+		keys := []string{}
+		for key := range demand {
+			keys = append(keys, key)
+		}
+		ioLoad = ls.ioLoad(node.Name, keys)
+	}
+	score := request.policy.score(capacityScore, volumes, ioLoad)
+	// 节点上已有同组的卷时需要分配到其他磁盘，磁盘不足会导致创建卷失败后重新调度，因此降低评分
+	if request.conflicts[node.Name] == utils.AntiAffinityDisk {
+		score = score / 2
+	}
+	return score
+}
+
+// ScoreExtensions of the Score plugin.
+func (ls *LocalStorage) ScoreExtensions() framework.ScoreExtensions {
+	return ls
+}
+
+// 将各节点分数按最高分等比映射到0-100
+func (ls *LocalStorage) NormalizeScore(ctx context.Context, state *framework.CycleState, pod *v1.Pod, scores framework.NodeScoreList) *framework.Status {
+	normalizeScore(scores)
+	return framework.NewStatus(framework.Success, "")
+}
+
+// 调度周期结束前预留pod请求的容量，直到pvc绑定或超时
+func (ls *LocalStorage) Reserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
+	request, err := ls.readStorageRequest(state, pod)
+	if err != nil {
+		return framework.NewStatus(framework.Error, fmt.Sprintf("get pv/sc resource error: %v", err))
+	}
+	if request.empty() {
+		return framework.NewStatus(framework.Success, "")
+	}
+
+	nodeInfo, err := ls.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
+	if err != nil {
+		return framework.NewStatus(framework.Error, fmt.Sprintf("getting node %q from Snapshot: %v", nodeName, err))
+	}
+	capacityMap := ls.availableCapacity(nodeInfo.Node(), pod.UID)
+
+	requests, unassigned := groupDemand(request, capacityMap)
+	if unassigned > 0 {
+		klog.V(3).Infof("reserve pod: %v, node: %v, no device group for request %dGi", pod.Name, nodeName, unassigned)
+	}
+	r := &reservation{
+		nodeName: nodeName,
+		requests: requests,
+		pvcs:     request.pvcs,
+		expires:  time.Now().Add(configuration.ReservationTimeout()),
+	}
+	for group := range request.antiAffinity {
+		r.groups = append(r.groups, group)
+	}
+	// 无法整体分配时不写入注解，由carina-controller逐个选择设备组
+	r.assignment, _ = request.deviceGroupAssignment(capacityMap)
+
+	ls.ledger.reserve(pod.UID, r)
+	klog.V(3).Infof("reserve pod: %v, node: %v, requests: %v, reservations: %d", pod.Name, nodeName, r.requests, ls.ledger.size())
+	return framework.NewStatus(framework.Success, "")
+}
+
+// 调度或绑定失败时释放预留的容量
+func (ls *LocalStorage) Unreserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) {
+	klog.V(3).Infof("unreserve pod: %v, node: %v", pod.Name, nodeName)
+	ls.ledger.release(pod.UID)
+}
+
+// 将sc中未设置设备组的pvc的设备组分配写入pvc注解
+// 需要在VolumeBinding设置selected-node注解之前执行，否则carina-controller可能在注解写入前创建卷
+func (ls *LocalStorage) PreBind(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
+	for pvc, group := range ls.ledger.assignment(pod.UID, nodeName) {
+		namespace, name, err := cache.SplitMetaNamespaceKey(pvc)
+		if err != nil {
+			return framework.NewStatus(framework.Error, err.Error())
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{
+					utils.AnnAssignedDeviceGroup: group,
+					utils.AnnAssignedNode:        nodeName,
+				},
+			},
 		})
-		if key == undefined {
-			capacityList := []int64{}
-			for _, c := range capacityMap {
-				capacityList = append(capacityList, c)
-			}
-			for _, pv := range pvs {
-				requestBytes := pv.Spec.Resources.Requests.Storage().Value()
-				requestGb := (requestBytes-1)>>30 + 1
-				capacityList = minimumValueMinus(capacityList, requestGb)
-				if len(capacityList) > 0 {
-					score += 1
-				}
-			}
-		} else {
-			requestTotalBytes := int64(0)
-			for _, pv := range pvs {
-				requestTotalBytes += pv.Spec.Resources.Requests.Storage().Value()
-			}
-			requestTotalGb := (requestTotalBytes-1)>>30 + 1
-			ratio := int64(capacityMap[key] / requestTotalGb)
+		if err != nil {
+			return framework.NewStatus(framework.Error, err.Error())
+		}
+		_, err = ls.handle.ClientSet().CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return framework.NewStatus(framework.Error, fmt.Sprintf("patch pvc %s device group error: %v", pvc, err))
+		}
+		klog.V(3).Infof("prebind pod: %v, node: %v, pvc: %v, device group: %v", pod.Name, nodeName, pvc, group)
+	}
+	return framework.NewStatus(framework.Success, "")
+}
+
+// 节点各设备组可用容量(Gb)，扣除其他pod已预留的部分
+func (ls *LocalStorage) availableCapacity(node *v1.Node, uid types.UID) map[string]int64 {
+	ls.ledger.prune(time.Now(), ls.pvcBound)
+
+	capacityMap := ls.nodeCapacity(node)
+	for key, reserved := range ls.ledger.reserved(node.Name, uid) {
+		if _, ok := capacityMap[key]; !ok {
+			continue
+		}
+		capacityMap[key] -= reserved
+		if capacityMap[key] < 0 {
+			capacityMap[key] = 0
+		}
+	}
+	return capacityMap
+}
+
+// pvc已绑定说明存储卷已创建，容量会体现在节点allocatable中
+func (ls *LocalStorage) pvcBound(key string) bool {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return true
+	}
+	pvc, err := ls.pvcLister.PersistentVolumeClaims(namespace).Get(name)
+	if err != nil {
+		// pvc已删除
+		return apierrors.IsNotFound(err)
+	}
+	return pvc.Status.Phase == v1.ClaimBound
+}
+
+// pod对carina存储的请求，在一个调度周期内不变
+type storageRequest struct {
+	// pod已绑定的pv所在节点
+	nodeName string
+	// 未绑定的pvc，namespace/name
+	pvcs []string
+	// 设备组 -> 请求总容量(Gb)
+	groups map[string]int64
+	// sc中未设置设备组的pvc请求容量(Gb)，从大到小排序
+	undefined []int64
+	// 与undefined一一对应的pvc，namespace/name
+	undefinedPvcs []string
+	// 缓存设备组 -> 请求容量(Gb)
+	cache map[string]int64
+	// 放置策略，pod使用多个storageclass时以请求容量最大的pvc为准
+	policy placementPolicy
+	// 卷反亲和组 -> 级别node|disk
+	antiAffinity map[string]string
+	// 已有同一反亲和组卷的节点 -> 级别，node级别的节点被过滤，disk级别的节点降低评分
+	conflicts map[string]string
+}
 
-			if configuration.SchedulerStrategy() == configuration.SchedulerSpradout {
-				score = reasonableScore(ratio)
+// 只读数据，不需要深拷贝
+func (r *storageRequest) Clone() framework.StateData {
+	return r
+}
+
+// 各节点上carina卷的数量
+type nodeVolumes map[string]int64
+
+func (v nodeVolumes) Clone() framework.StateData {
+	return v
+}
+
+func (r *storageRequest) empty() bool {
+	return len(r.groups) == 0 && len(r.undefined) == 0
+}
+
+func (ls *LocalStorage) readStorageRequest(cycleState *framework.CycleState, pod *v1.Pod) (*storageRequest, error) {
+	data, err := cycleState.Read(preFilterStateKey)
+	if err != nil {
+		// 未启用PreFilter
+		return ls.getStorageRequest(pod)
+	}
+	request, ok := data.(*storageRequest)
+	if !ok {
+		return nil, fmt.Errorf("%+v convert to localstorage.storageRequest error", data)
+	}
+	return request, nil
+}
+
+func (ls *LocalStorage) getStorageRequest(pod *v1.Pod) (*storageRequest, error) {
+	pvcMap, nodeName, cacheDeviceRequest, err := ls.getLocalStoragePvc(pod)
+	if err != nil {
+		return nil, err
+	}
+	request := &storageRequest{
+		nodeName:     nodeName,
+		groups:       map[string]int64{},
+		cache:        map[string]int64{},
+		policy:       defaultPlacementPolicy(),
+		antiAffinity: map[string]string{},
+	}
+	var dominant *v1.PersistentVolumeClaim
+	dominantBytes := int64(0)
+	type undefinedPvc struct {
+		name      string
+		requestGb int64
+	}
+	undefinedPvcs := []undefinedPvc{}
+	for key, pvs := range pvcMap {
+		requestTotalBytes := int64(0)
+		for _, pv := range pvs {
+			request.pvcs = append(request.pvcs, pv.Namespace+"/"+pv.Name)
+			if err := ls.addAntiAffinity(request, pv); err != nil {
+				return nil, err
+			}
+			requestBytes := pv.Spec.Resources.Requests.Storage().Value()
+			if dominant == nil || requestBytes > dominantBytes || (requestBytes == dominantBytes && pv.Name < dominant.Name) {
+				dominant, dominantBytes = pv, requestBytes
 			}
-			if configuration.SchedulerStrategy() == configuration.SchedulerBinpack {
-				score = 6 - reasonableScore(ratio)
+			if key == undefined {
+				undefinedPvcs = append(undefinedPvcs, undefinedPvc{name: pv.Namespace + "/" + pv.Name, requestGb: (requestBytes-1)>>30 + 1})
 			}
+			requestTotalBytes += requestBytes
+		}
+		if key != undefined {
+			request.groups[key] = (requestTotalBytes-1)>>30 + 1
 		}
 	}
-	klog.V(3).Infof("score pod: %v, node: %v score %v", pod.Name, nodeName, score)
-	return score, framework.NewStatus(framework.Success)
+	sort.Slice(undefinedPvcs, func(i, j int) bool {
+		if undefinedPvcs[i].requestGb == undefinedPvcs[j].requestGb {
+			return undefinedPvcs[i].name < undefinedPvcs[j].name
+		}
+		return undefinedPvcs[i].requestGb > undefinedPvcs[j].requestGb
+	})
+	for _, pvc := range undefinedPvcs {
+		request.undefined = append(request.undefined, pvc.requestGb)
+		request.undefinedPvcs = append(request.undefinedPvcs, pvc.name)
+	}
+	for key, value := range cacheDeviceRequest {
+		request.cache[key] = (value-1)>>30 + 1
+	}
+	if dominant != nil {
+		sc, err := ls.scLister.Get(*dominant.Spec.StorageClassName)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := parsePlacementPolicy(sc.Parameters)
+		if err != nil {
+			// 参数错误时由carina-controller创建卷时报错，调度使用默认策略
+			klog.V(3).Infof("invalid placement policy of storageclass %s: %v", sc.Name, err)
+		} else {
+			request.policy = policy
+		}
+	}
+	request.conflicts, err = ls.antiAffinityConflicts(request.antiAffinity, pod.UID)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
 }
 
-// ScoreExtensions of the Score plugin.
-func (ls *LocalStorage) ScoreExtensions() framework.ScoreExtensions {
+// 记录storageclass设置了卷反亲和的pvc所属的反亲和组
+func (ls *LocalStorage) addAntiAffinity(request *storageRequest, pvc *v1.PersistentVolumeClaim) error {
+	sc, err := ls.scLister.Get(*pvc.Spec.StorageClassName)
+	if err != nil {
+		return err
+	}
+	level := antiAffinityLevel(sc)
+	group := antiAffinityGroup(pvc)
+	if level == "" || group == "" {
+		return nil
+	}
+	if request.antiAffinity[group] != utils.AntiAffinityNode {
+		request.antiAffinity[group] = level
+	}
 	return nil
 }
 
+// 统计各节点上carina卷的数量
+func (ls *LocalStorage) nodeVolumes() (nodeVolumes, error) {
+	pvs, err := ls.pvLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	volumes := nodeVolumes{}
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != utils.CSIPluginName {
+			continue
+		}
+		volumes[pv.Spec.CSI.VolumeAttributes[utils.VolumeDeviceNode]]++
+	}
+	return volumes, nil
+}
+
 func (ls *LocalStorage) getLocalStoragePvc(pod *v1.Pod) (map[string][]*v1.PersistentVolumeClaim, string, map[string]int64, error) {
 	nodeName := ""
 	localPvc := map[string][]*v1.PersistentVolumeClaim{}
@@ -292,24 +593,77 @@ func (ls *LocalStorage) getLocalStoragePvc(pod *v1.Pod) (map[string][]*v1.Persis
 	return localPvc, nodeName, cacheDeviceRequest, nil
 }
 
-// 在所有容量列表中，找到最低满足的值，并减去请求容量
-// 循环便能判断该节点是否可满足所有pvc请求容量
-func minimumValueMinus(array []int64, value int64) []int64 {
-	sort.Slice(array, func(i, j int) bool {
-		return array[i] < array[j]
-	})
-	index := -1
-	for i, a := range array {
-		if a >= value {
-			index = i
-			break
+// pod在节点各设备组的请求容量(Gb)，包括缓存设备请求
+// sc中未设置设备组的pvc与Filter相同，按照整体分配方案计入设备组
+// 无法整体分配时按照最小满足的设备组逐个分配，无法分配的容量单独返回
+func groupDemand(request *storageRequest, capacityMap map[string]int64) (map[string]int64, int64) {
+	demand := map[string]int64{}
+	unassigned := int64(0)
+	if assignment, ok := assignUndefined(request, capacityMap); ok {
+		for i, group := range assignment {
+			demand[group] += request.undefined[i]
+		}
+	} else {
+		capacity := map[string]int64{}
+		for key, c := range capacityMap {
+			capacity[key] = c
+		}
+		for _, requestGb := range request.undefined {
+			group := minimumGroup(capacity, requestGb)
+			if group == "" {
+				unassigned += requestGb
+				continue
+			}
+			capacity[group] -= requestGb
+			demand[group] += requestGb
+		}
+	}
+	for key, requestTotalGb := range request.groups {
+		demand[key] += requestTotalGb
+	}
+	for key, requestTotalGb := range request.cache {
+		demand[key] += requestTotalGb
+	}
+	return demand, unassigned
+}
+
+// 按请求容量加权合并各设备组的分数，结果范围0-100
+// 每个设备组按照剩余容量与请求容量的比值打分，spradout倾向剩余容量多的节点，binpack相反
+func scoreRequest(request *storageRequest, capacityMap map[string]int64, strategy string) int64 {
+	demand, unassigned := groupDemand(request, capacityMap)
+	weighted := int64(0)
+	total := unassigned
+	for key, requestGb := range demand {
+		total += requestGb
+		if capacityMap[key] < requestGb {
+			continue
+		}
+		score := reasonableScore(capacityMap[key] / requestGb)
+		if strategy == configuration.SchedulerBinpack {
+			score = 6 - score
 		}
+		weighted += score * requestGb
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted * framework.MaxNodeScore / (5 * total)
+}
+
+// 以最高分为基准等比缩放到0-100
+func normalizeScore(scores framework.NodeScoreList) {
+	var highest int64
+	for _, score := range scores {
+		if score.Score > highest {
+			highest = score.Score
+		}
+	}
+	if highest == 0 {
+		return
 	}
-	if index < 0 {
-		return []int64{}
+	for i := range scores {
+		scores[i].Score = scores[i].Score * framework.MaxNodeScore / highest
 	}
-	array[index] = array[index] - value
-	return array
 }
 
 // 分值范围为0-10，在此降低pv分值比例限制为1-5分
@@ -16,26 +16,22 @@
 package localstorage
 
 import (
+	"context"
+	"github.com/carina-io/carina/scheduler/utils"
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	lcorev1 "k8s.io/client-go/listers/core/v1"
+	lstoragev1 "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"testing"
+	"time"
 )
 
-func TestMinimumValueMinus(t *testing.T) {
-	table := []struct{
-		array []int64
-		value int64
-		result []int64
-	}{
-		{array:[]int64{3,4,5,2,5,23,1}, value: 3, result: []int64{1, 2, 0, 4, 5, 5, 23}},
-		{array:[]int64{3,4,5,2,5,23,1}, value: 33, result: []int64{}},
-	}
-
-	a := assert.New(t)
-	for _, e := range table {
-		a.Equal(minimumValueMinus(e.array, e.value), e.result)
-	}
-}
-
 func TestReasonableScore(t *testing.T) {
 	table := []struct{
 		ration int64
@@ -47,4 +43,211 @@ func TestReasonableScore(t *testing.T) {
 	for _, e := range table {
 		a.Equal(reasonableScore(e.ration), e.result)
 	}
-}
\ No newline at end of file
+}
+
+func newTestPlugin(scs []*storagev1.StorageClass, pvcs []*v1.PersistentVolumeClaim, pvs ...*v1.PersistentVolume) *LocalStorage {
+	scIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, sc := range scs {
+		_ = scIndexer.Add(sc)
+	}
+	pvcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pvc := range pvcs {
+		_ = pvcIndexer.Add(pvc)
+	}
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pv := range pvs {
+		_ = pvIndexer.Add(pv)
+	}
+	return &LocalStorage{
+		scLister:  lstoragev1.NewStorageClassLister(scIndexer),
+		pvcLister: lcorev1.NewPersistentVolumeClaimLister(pvcIndexer),
+		pvLister:  lcorev1.NewPersistentVolumeLister(pvIndexer),
+		ledger:    newReservationLedger(),
+	}
+}
+
+func newTestStorageClass(name, group string) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: utils.CSIPluginName,
+		Parameters:  map[string]string{},
+	}
+	if group != "" {
+		sc.Parameters[utils.DeviceDiskKey] = group
+	}
+	return sc
+}
+
+func newTestPvc(name, sc, size string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+}
+
+func newTestPod(pvcs ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "pod-uid"}}
+	for _, pvc := range pvcs {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name:         pvc,
+			VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvc}},
+		})
+	}
+	return pod
+}
+
+func newTestNodeInfo(name string, capacity map[string]string) *framework.NodeInfo {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: v1.NodeStatus{Allocatable: v1.ResourceList{}}}
+	for group, c := range capacity {
+		node.Status.Allocatable[v1.ResourceName(utils.DeviceCapacityKeyPrefix+"carina-vg-"+group)] = resource.MustParse(c)
+	}
+	nodeInfo := framework.NewNodeInfo()
+	_ = nodeInfo.SetNode(node)
+	return nodeInfo
+}
+
+func TestPreFilter(t *testing.T) {
+	a := assert.New(t)
+	spreadout := newTestStorageClass("csi-carina-hdd", "hdd")
+	spreadout.Parameters[utils.PlacementStrategyKey] = "spradout"
+	spreadout.Parameters[utils.PlacementWeightsKey] = "capacity=2,volume=1"
+	ls := newTestPlugin(
+		[]*storagev1.StorageClass{spreadout, newTestStorageClass("csi-carina", "")},
+		[]*v1.PersistentVolumeClaim{
+			newTestPvc("pvc-1", "csi-carina-hdd", "10Gi"),
+			newTestPvc("pvc-2", "csi-carina-hdd", "5Gi"),
+			newTestPvc("pvc-3", "csi-carina", "3Gi"),
+			newTestPvc("pvc-4", "csi-carina", "8Gi"),
+		})
+	pod := newTestPod("pvc-1", "pvc-2", "pvc-3", "pvc-4")
+
+	state := framework.NewCycleState()
+	a.True(ls.PreFilter(context.Background(), state, pod).IsSuccess())
+	data, err := state.Read(preFilterStateKey)
+	a.NoError(err)
+	request := data.(*storageRequest)
+	a.Equal(map[string]int64{utils.DeviceCapacityKeyPrefix + "carina-vg-hdd": 15}, request.groups)
+	a.Equal([]int64{8, 3}, request.undefined)
+	a.Equal([]string{"default/pvc-4", "default/pvc-3"}, request.undefinedPvcs)
+	a.Len(request.pvcs, 4)
+	// 以请求容量最大的pvc-1所属storageclass为准
+	a.Equal(placementPolicy{strategy: "spradout", capacityWeight: 2, volumeWeight: 1}, request.policy)
+
+	a.True(ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-1", map[string]string{"hdd": "20", "ssd": "10"})).IsSuccess())
+	a.Equal(framework.UnschedulableAndUnresolvable, ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-2", map[string]string{"hdd": "10", "ssd": "10"})).Code())
+}
+
+func newTestPv(name, node string, attributes map[string]string) *v1.PersistentVolume {
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	pv.Spec.CSI = &v1.CSIPersistentVolumeSource{Driver: utils.CSIPluginName, VolumeAttributes: map[string]string{utils.VolumeDeviceNode: node}}
+	for k, v := range attributes {
+		pv.Spec.CSI.VolumeAttributes[k] = v
+	}
+	return pv
+}
+
+func TestAntiAffinity(t *testing.T) {
+	a := assert.New(t)
+	nodeLevel := newTestStorageClass("csi-carina-db", "hdd")
+	nodeLevel.Parameters[utils.VolumeAntiAffinityKey] = "node"
+	diskLevel := newTestStorageClass("csi-carina-cache", "hdd")
+	diskLevel.Parameters[utils.VolumeAntiAffinityKey] = "disk"
+	labeled := newTestPvc("cache-a", "csi-carina-cache", "5Gi")
+	labeled.Labels = map[string]string{utils.AntiAffinityGroupKey: "cache"}
+	ls := newTestPlugin(
+		[]*storagev1.StorageClass{nodeLevel, diskLevel},
+		[]*v1.PersistentVolumeClaim{newTestPvc("data-mysql-2", "csi-carina-db", "10Gi"), labeled},
+		newTestPv("pv-1", "node-1", map[string]string{utils.AntiAffinityGroupKey: "default/data-mysql"}),
+		newTestPv("pv-2", "node-2", map[string]string{utils.AntiAffinityGroupKey: "default/cache"}),
+		newTestPv("pv-3", "node-3", map[string]string{utils.AntiAffinityGroupKey: "default/data-redis"}),
+	)
+	// 其他pod已预留node-4
+	ls.ledger.reserve("other", &reservation{nodeName: "node-4", groups: []string{"default/data-mysql"}, expires: time.Now().Add(time.Minute)})
+
+	pod := newTestPod("data-mysql-2", "cache-a")
+	state := framework.NewCycleState()
+	a.True(ls.PreFilter(context.Background(), state, pod).IsSuccess())
+	request, err := ls.readStorageRequest(state, pod)
+	a.NoError(err)
+	a.Equal(map[string]string{"default/data-mysql": "node", "default/cache": "disk"}, request.antiAffinity)
+	a.Equal(map[string]string{"node-1": "node", "node-2": "disk", "node-4": "node"}, request.conflicts)
+
+	capacity := map[string]string{"hdd": "100"}
+	a.Equal(framework.UnschedulableAndUnresolvable, ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-1", capacity)).Code())
+	a.Equal(framework.UnschedulableAndUnresolvable, ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-4", capacity)).Code())
+	a.True(ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-2", capacity)).IsSuccess())
+	a.True(ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-3", capacity)).IsSuccess())
+}
+
+func TestFilterReasons(t *testing.T) {
+	a := assert.New(t)
+	bcache := newTestStorageClass("csi-carina-bcache", "")
+	bcache.Parameters[utils.VolumeBackendDiskType] = "hdd"
+	bcache.Parameters[utils.VolumeCacheDiskType] = "ssd"
+	bcache.Parameters[utils.VolumeCacheDiskRatio] = "50"
+	ls := newTestPlugin(
+		[]*storagev1.StorageClass{bcache, newTestStorageClass("csi-carina", "")},
+		[]*v1.PersistentVolumeClaim{
+			newTestPvc("pvc-1", "csi-carina-bcache", "20Gi"),
+			newTestPvc("pvc-2", "csi-carina", "30Gi"),
+		})
+	pod := newTestPod("pvc-1", "pvc-2")
+	state := framework.NewCycleState()
+	a.True(ls.PreFilter(context.Background(), state, pod).IsSuccess())
+
+	counter := filterRejections.WithLabelValues(reasonCacheInsufficient, "carina-vg-ssd")
+	rejected, err := testutil.GetCounterMetricValue(counter)
+	a.NoError(err)
+	status := ls.Filter(context.Background(), state, pod, newTestNodeInfo("node-1", map[string]string{"hdd": "10", "ssd": "5"}))
+	a.Equal(framework.UnschedulableAndUnresolvable, status.Code())
+	a.Equal([]string{
+		"node(s) could not fit volumes of 30Gi without device group, largest available 0Gi",
+		"node(s) had insufficient cache carina-vg-ssd, requested 10Gi, available 5Gi",
+		"node(s) had insufficient carina-vg-hdd, requested 20Gi, available 10Gi",
+	}, status.Reasons())
+	value, err := testutil.GetCounterMetricValue(counter)
+	a.NoError(err)
+	a.Equal(rejected+1, value)
+}
+
+func TestScoreRequest(t *testing.T) {
+	hdd := utils.DeviceCapacityKeyPrefix + "carina-vg-hdd"
+	ssd := utils.DeviceCapacityKeyPrefix + "carina-vg-ssd"
+	table := []struct {
+		request  *storageRequest
+		capacity map[string]int64
+		strategy string
+		result   int64
+	}{
+		// 单个设备组，剩余容量为请求的4倍
+		{request: &storageRequest{groups: map[string]int64{hdd: 10}}, capacity: map[string]int64{hdd: 40}, strategy: "spradout", result: 40},
+		{request: &storageRequest{groups: map[string]int64{hdd: 10}}, capacity: map[string]int64{hdd: 40}, strategy: "binpack", result: 80},
+		// 数据盘与缓存盘按请求容量加权
+		{request: &storageRequest{groups: map[string]int64{hdd: 30}, cache: map[string]int64{ssd: 10}}, capacity: map[string]int64{hdd: 300, ssd: 10}, strategy: "spradout", result: 80},
+		// 未设置设备组的pvc分配到最小满足的设备组
+		{request: &storageRequest{undefined: []int64{20}}, capacity: map[string]int64{hdd: 30, ssd: 500}, strategy: "spradout", result: 20},
+		// 无法分配的请求按0分计算
+		{request: &storageRequest{undefined: []int64{20, 20}}, capacity: map[string]int64{hdd: 30}, strategy: "spradout", result: 10},
+	}
+	a := assert.New(t)
+	for _, e := range table {
+		a.Equal(e.result, scoreRequest(e.request, e.capacity, e.strategy))
+	}
+}
+
+func TestNormalizeScore(t *testing.T) {
+	a := assert.New(t)
+	scores := framework.NodeScoreList{{Name: "node-1", Score: 40}, {Name: "node-2", Score: 80}, {Name: "node-3", Score: 0}}
+	normalizeScore(scores)
+	a.Equal(framework.NodeScoreList{{Name: "node-1", Score: 50}, {Name: "node-2", Score: 100}, {Name: "node-3", Score: 0}}, scores)
+
+	scores = framework.NodeScoreList{{Name: "node-1", Score: 0}}
+	normalizeScore(scores)
+	a.Equal(framework.NodeScoreList{{Name: "node-1", Score: 0}}, scores)
+}
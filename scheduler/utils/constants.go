@@ -30,4 +30,20 @@ const (
 	VolumeCacheDiskType   = "carina.storage.io/cache-disk-type"
 	// value: 1-100 Cache Capacity Ratio
 	VolumeCacheDiskRatio = "carina.storage.io/cache-disk-ratio"
+
+	// value: binpack|spradout 卷放置策略，未设置时使用配置文件中的schedulerStrategy
+	PlacementStrategyKey = "carina.storage.io/scheduler-strategy"
+	// value: capacity=1,volume=1,ioload=1 剩余容量、节点卷数量、磁盘IO负载的评分权重
+	PlacementWeightsKey = "carina.storage.io/scheduler-weights"
+	// value: node|disk 同一反亲和组的卷分散到不同节点，或者同一节点的不同磁盘
+	VolumeAntiAffinityKey = "carina.storage.io/volume-anti-affinity"
+	// pvc标签指定卷所属的反亲和组，未设置时StatefulSet的pvc以去掉序号后的名称为组
+	// 同时记录在LogicVolume注解及pv VolumeAttributes中，值为namespace/group
+	AntiAffinityGroupKey = "carina.storage.io/anti-affinity-group"
+	AntiAffinityNode     = "node"
+	AntiAffinityDisk     = "disk"
+	// sc中未设置设备组时，调度器为pod的pvc整体计算的设备组分配，写在pvc注解中
+	// 仅当pvc选定的节点与AnnAssignedNode一致时有效
+	AnnAssignedDeviceGroup = "carina.storage.io/assigned-device-group"
+	AnnAssignedNode        = "carina.storage.io/assigned-node"
 )
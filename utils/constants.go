@@ -33,7 +33,7 @@ const (
 	ResizeRequestedAtKey = "carina.storage.io/resize-requested-at"
 
 	// storage class
-	// DeviceDiskKey is the key used in CSI volume create requests to specify a DeviceDiskKey support carina-vg-ssd carina-vg-hdd
+	// DeviceDiskKey is the key used in CSI volume create requests to specify a DeviceDiskKey support carina-vg-nvme carina-vg-ssd carina-vg-hdd
 	DeviceDiskKey = "carina.storage.io/disk-type"
 	// k8s default key Device FileSystem eg. xfs ext4
 	DeviceFileSystem = "csi.storage.k8s.io/fstype"
@@ -44,6 +44,21 @@ const (
 	VolumeCacheDiskRatio = "carina.storage.io/cache-disk-ratio"
 	// value: writethrough|writeback|writearound
 	VolumeCachePolicy = "carina.storage.io/cache-policy"
+	// value: binpack|spradout 卷放置策略，未设置时使用配置文件中的schedulerStrategy
+	PlacementStrategyKey = "carina.storage.io/scheduler-strategy"
+	// value: capacity=1,volume=1,ioload=1 剩余容量、节点卷数量、磁盘IO负载的评分权重
+	PlacementWeightsKey = "carina.storage.io/scheduler-weights"
+	// value: node|disk 同一反亲和组的卷分散到不同节点，或者同一节点的不同磁盘
+	VolumeAntiAffinityKey = "carina.storage.io/volume-anti-affinity"
+	// pvc标签指定卷所属的反亲和组，未设置时StatefulSet的pvc以去掉序号后的名称为组
+	// 同时记录在LogicVolume注解及pv VolumeAttributes中，值为namespace/group
+	AntiAffinityGroupKey = "carina.storage.io/anti-affinity-group"
+	AntiAffinityNode     = "node"
+	AntiAffinityDisk     = "disk"
+	// sc中未设置设备组时，调度器为pod的pvc整体计算的设备组分配，写在pvc注解中
+	// 仅当pvc选定的节点与AnnAssignedNode一致时有效
+	AnnAssignedDeviceGroup = "carina.storage.io/assigned-device-group"
+	AnnAssignedNode        = "carina.storage.io/assigned-node"
 
 	// pvc
 	// default size in GiB for volumes (PVC or inline ephemeral volumes) w/o capacity requests.
@@ -75,6 +90,11 @@ const (
 	DeviceVGSSD = "carina-vg-ssd"
 	DeviceVGHDD = "carina-vg-hdd"
 
+	// node annotation, value is the disk plan id to approve disk removal
+	DiskRemovalApproveKey = "carina.storage.io/approve-disk-removal"
+	// node annotation, comma separated disks to decommission, eg. /dev/sdb,/dev/sdc
+	DiskDecommissionKey = "carina.storage.io/decommission-disks"
+
 	// custom schedule
 	CarinaSchedule = "carina-scheduler"
 )
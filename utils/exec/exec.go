@@ -46,6 +46,43 @@ type Executor interface {
 type CommandExecutor struct {
 }
 
+// ExecError 命令执行失败时的退出码及标准错误输出，便于调用方判断失败原因
+type ExecError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *ExecError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s failed, exit code %d: %s", e.Command, e.ExitCode, e.Stderr)
+	}
+	return fmt.Sprintf("%s failed: %v", e.Command, e.Err)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+func newExecError(command string, arg []string, stderr string, err error) error {
+	if err == nil {
+		return nil
+	}
+	exitCode := -1
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	}
+	return &ExecError{
+		Command:  command,
+		Args:     arg,
+		ExitCode: exitCode,
+		Stderr:   strings.TrimSpace(stderr),
+		Err:      err,
+	}
+}
+
 // ExecuteCommand starts a process and wait for its completion
 func (c *CommandExecutor) ExecuteCommand(command string, arg ...string) error {
 	return c.ExecuteCommandWithEnv([]string{}, command, arg...)
@@ -55,13 +92,13 @@ func (c *CommandExecutor) ExecuteCommand(command string, arg ...string) error {
 func (*CommandExecutor) ExecuteCommandWithEnv(env []string, command string, arg ...string) error {
 	cmd, stdout, stderr, err := startCommand(env, command, arg...)
 	if err != nil {
-		return err
+		return newExecError(command, arg, "", err)
 	}
 
-	logOutput(stdout, stderr)
+	errOutput := logOutput(stdout, stderr)
 
 	if err := cmd.Wait(); err != nil {
-		return err
+		return newExecError(command, arg, errOutput, err)
 	}
 
 	return nil
@@ -99,7 +136,7 @@ func (*CommandExecutor) ExecuteCommandWithTimeout(timeout time.Duration, command
 				} else {
 					e = fmt.Errorf("timeout waiting for the command %s to return", command)
 				}
-				return strings.TrimSpace(b.String()), e
+				return strings.TrimSpace(b.String()), newExecError(command, arg, b.String(), e)
 			}
 
 			log.Infof("timeout waiting for process %s to return. Sending interrupt signal to the process", command)
@@ -110,10 +147,10 @@ func (*CommandExecutor) ExecuteCommandWithTimeout(timeout time.Duration, command
 			interruptSent = true
 		case err := <-done:
 			if err != nil {
-				return strings.TrimSpace(b.String()), err
+				return strings.TrimSpace(b.String()), newExecError(command, arg, b.String(), err)
 			}
 			if interruptSent {
-				return strings.TrimSpace(b.String()), fmt.Errorf("timeout waiting for the command %s to return", command)
+				return strings.TrimSpace(b.String()), newExecError(command, arg, b.String(), fmt.Errorf("timeout waiting for the command %s to return", command))
 			}
 			return strings.TrimSpace(b.String()), nil
 		}
@@ -125,7 +162,8 @@ func (*CommandExecutor) ExecuteCommandWithOutput(command string, arg ...string)
 	logCommand(command, arg...)
 	// #nosec G204 Rook controls the input to the exec arguments
 	cmd := exec.Command(command, arg...)
-	return runCommandWithOutput(cmd, false)
+	output, err := runCommandWithOutput(cmd, false)
+	return output, newExecError(command, arg, assertErrorType(err), err)
 }
 
 // ExecuteCommandWithCombinedOutput executes a command with combined output
@@ -133,7 +171,8 @@ func (*CommandExecutor) ExecuteCommandWithCombinedOutput(command string, arg ...
 	logCommand(command, arg...)
 	// #nosec G204 Rook controls the input to the exec arguments
 	cmd := exec.Command(command, arg...)
-	return runCommandWithOutput(cmd, true)
+	output, err := runCommandWithOutput(cmd, true)
+	return output, newExecError(command, arg, output, err)
 }
 
 // ExecuteCommandWithOutputFileTimeout Same as ExecuteCommandWithOutputFile but with a timeout limit.
@@ -257,22 +296,29 @@ func startCommand(env []string, command string, arg ...string) (*exec.Cmd, io.Re
 }
 
 // read from reader line by line and write it to the log
-func logFromReader(reader io.ReadCloser) {
+func logFromReader(reader io.ReadCloser) string {
 	in := bufio.NewScanner(reader)
-	lastLine := ""
+	lines := []string{}
 	for in.Scan() {
-		lastLine = in.Text()
+		lastLine := in.Text()
 		log.Debug(lastLine)
+		lines = append(lines, lastLine)
 	}
+	return strings.Join(lines, "\n")
 }
 
-func logOutput(stdout, stderr io.ReadCloser) {
+// 记录命令输出，返回标准错误输出
+func logOutput(stdout, stderr io.ReadCloser) string {
 	if stdout == nil || stderr == nil {
 		log.Warnf("failed to collect stdout and stderr")
-		return
+		return ""
 	}
-	go logFromReader(stderr)
+	errOutput := make(chan string, 1)
+	go func() {
+		errOutput <- logFromReader(stderr)
+	}()
 	logFromReader(stdout)
+	return <-errOutput
 }
 
 func runCommandWithOutput(cmd *exec.Cmd, combinedOutput bool) (string, error) {